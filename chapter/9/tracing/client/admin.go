@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// startAdminServer serves operational endpoints on -admin-addr, if it is set:
+//
+//	/log/level: GET returns the log level, PUT with a body like {"level":"debug"} changes it.
+//	/healthz: the last span export and successful request, with a 503 status if either is a problem.
+//	/livez: the last request and span export, with a 503 status if the load loop is stuck.
+func startAdminServer() (func(context.Context), error) {
+	if *adminAddr == "" {
+		return func(context.Context) {}, nil
+	}
+
+	// Listen before returning, so an address that is in use fails at startup.
+	lis, err := net.Listen("tcp", *adminAddr)
+	if err != nil {
+		return nil, fmt.Errorf("-admin-addr: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/log/level", logAtomicLevel)
+	mux.Handle("/healthz", clientHealth)
+	mux.Handle("/livez", livenessHandler(clientHealth))
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			logger.Error("admin server stopped", zap.Error(err))
+		}
+	}()
+	logger.Info("serving admin endpoints", zap.String("addr", lis.Addr().String()))
+
+	return func(doneCtx context.Context) {
+		if err := srv.Shutdown(doneCtx); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
+}
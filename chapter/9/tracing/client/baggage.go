@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// baseBaggage is the baggage every request carries, from -baggage and -request-origin. It is set in Main.
+var baseBaggage baggage.Baggage
+
+// runID tells this run of the client apart in the session.id of requests' baggage.
+var runID = fmt.Sprintf("%08x", rand.Uint32())
+
+// correlationIDs is whether requests carry a new correlation.id in their baggage, for the correlationid
+// propagator to send as X-Correlation-ID. It is set in Main.
+var correlationIDs bool
+
+// meshRequestIDs is whether requests carry a new request ID, for the mesh propagator to send as
+// X-Request-Id. The client is where requests enter the mesh, so it does what the sidecar of an ingress
+// would. It is set in Main.
+var meshRequestIDs bool
+
+// initBaggage sets baseBaggage from -baggage and -request-origin, and correlationIDs and meshRequestIDs
+// from -propagators.
+func initBaggage() error {
+	for _, name := range strings.Split(*propagatorNames, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "correlationid":
+			correlationIDs = true
+		case "mesh":
+			meshRequestIDs = true
+		}
+	}
+	var err error
+	baseBaggage, err = parseBaggage(*baggageMembers, *requestOrigin)
+	return err
+}
+
+// parseBaggage parses members, W3C baggage like "tenant=acme,plan=free", and adds a request.origin of
+// origin unless members has one.
+func parseBaggage(members, origin string) (baggage.Baggage, error) {
+	b, err := baggage.Parse(members)
+	if err != nil {
+		return baggage.Baggage{}, fmt.Errorf("-baggage=%s is not a valid value: %w", members, err)
+	}
+	if origin == "" || b.Member("request.origin").Key() != "" {
+		return b, nil
+	}
+	m, err := baggage.NewMember("request.origin", origin)
+	if err != nil {
+		return baggage.Baggage{}, fmt.Errorf("-request-origin=%s is not a valid value: %w", origin, err)
+	}
+	return b.SetMember(m)
+}
+
+// withBaggage returns ctx with the baggage of a new request: baseBaggage and, with -baggage-users, the
+// user.id of a user picked at random and the session.id of that user in this run. The propagator sends
+// it in the baggage header, so the server can put the same business context on its spans. With the
+// correlationid propagator, it also has a new correlation.id, and with the mesh propagator, ctx has a new
+// request ID.
+func withBaggage(ctx context.Context) context.Context {
+	b := baseBaggage
+	if *baggageUsers > 0 {
+		user := fmt.Sprintf("user-%d", rand.Intn(*baggageUsers)+1)
+		b = setDefaultMember(b, "user.id", user)
+		b = setDefaultMember(b, "session.id", user+"-"+runID)
+	}
+	if correlationIDs {
+		if id, err := newUUID(); err == nil {
+			b = setDefaultMember(b, propagators.CorrelationIDKey, id)
+		}
+	}
+	if meshRequestIDs {
+		if id, err := newUUID(); err == nil {
+			ctx = propagators.ContextWithRequestID(ctx, id)
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// setDefaultMember returns b with the member key=value, unless b already has key.
+func setDefaultMember(b baggage.Baggage, key, value string) baggage.Baggage {
+	if b.Member(key).Key() != "" {
+		return b
+	}
+	m, err := baggage.NewMember(key, value)
+	if err != nil {
+		return b
+	}
+	if withMember, err := b.SetMember(m); err == nil {
+		return withMember
+	}
+	return b
+}
+
+// baggageAttributes returns the members of the baggage in ctx as span attributes, so the client's spans
+// have the same business context as the server's.
+func baggageAttributes(ctx context.Context) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, m := range baggage.FromContext(ctx).Members() {
+		attrs = append(attrs, attribute.String(m.Key(), m.Value()))
+	}
+	return attrs
+}
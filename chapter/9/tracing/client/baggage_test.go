@@ -0,0 +1,68 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestParseBaggage(t *testing.T) {
+	tests := []struct {
+		desc       string
+		members    string
+		origin     string
+		wantOrigin string
+		wantLen    int
+		wantErr    bool
+	}{
+		{
+			desc:       "Only the origin",
+			origin:     "demo-client",
+			wantOrigin: "demo-client",
+			wantLen:    1,
+		},
+		{
+			desc:       "Members and origin",
+			members:    "tenant=acme,plan=free",
+			origin:     "demo-client",
+			wantOrigin: "demo-client",
+			wantLen:    3,
+		},
+		{
+			desc:       "Members set the origin",
+			members:    "request.origin=checkout",
+			origin:     "demo-client",
+			wantOrigin: "checkout",
+			wantLen:    1,
+		},
+		{
+			desc:    "No origin",
+			members: "tenant=acme",
+			wantLen: 1,
+		},
+		{
+			desc:    "Invalid members",
+			members: "tenant",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := parseBaggage(test.members, test.origin)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseBaggage(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseBaggage(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if got := b.Member("request.origin").Value(); got != test.wantOrigin {
+			t.Errorf("TestParseBaggage(%s): got request.origin %q, want %q", test.desc, got, test.wantOrigin)
+		}
+		if b.Len() != test.wantLen {
+			t.Errorf("TestParseBaggage(%s): got %d members, want %d", test.desc, b.Len(), test.wantLen)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// bodyTemplate is the -request-body template. It is nil if requests have no body.
+var bodyTemplate *template.Template
+
+// requestSeq is the number of request bodies rendered, available to templates as {{seq}}.
+var requestSeq int64
+
+// bodyFuncs are the functions request body templates can use, like:
+//
+//	{"id": "{{uuid}}", "sent": "{{now}}", "n": {{seq}}, "size": {{randInt 1 100}}}
+var bodyFuncs = template.FuncMap{
+	"uuid": newUUID,
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	},
+	"seq": func() int64 {
+		return atomic.AddInt64(&requestSeq, 1)
+	},
+	"randInt": func(min, max int64) (int64, error) {
+		if max <= min {
+			return 0, fmt.Errorf("randInt: max must be greater than min")
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(max-min))
+		if err != nil {
+			return 0, err
+		}
+		return min + n.Int64(), nil
+	},
+}
+
+// initRequestBody parses the -request-body template, which is read from a file if it starts with '@'.
+func initRequestBody() error {
+	text := *requestBody
+	if text == "" {
+		return nil
+	}
+	if text[0] == '@' {
+		b, err := os.ReadFile(text[1:])
+		if err != nil {
+			return fmt.Errorf("-request-body: %w", err)
+		}
+		text = string(b)
+	}
+
+	t, err := parseBody(text)
+	if err != nil {
+		return fmt.Errorf("-request-body: %w", err)
+	}
+	bodyTemplate = t
+	return nil
+}
+
+// parseBody parses a request body template, which can use bodyFuncs.
+func parseBody(text string) (*template.Template, error) {
+	return template.New("body").Funcs(bodyFuncs).Parse(text)
+}
+
+// renderBody returns the body rendered from t, or nil if t is nil.
+func renderBody(t *template.Template) ([]byte, error) {
+	if t == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("failed to render request body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
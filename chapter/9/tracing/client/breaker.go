@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// breakerState is the state of a circuitBreaker.
+type breakerState int
+
+const (
+	// breakerClosed lets requests through.
+	breakerClosed breakerState = iota
+	// breakerHalfOpen lets one request through to find out if the server has recovered.
+	breakerHalfOpen
+	// breakerOpen fails requests without sending them.
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerClosed:
+		return "closed"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+	return "open"
+}
+
+// errBreakerOpen is returned for requests the circuit breaker doesn't let through.
+var errBreakerOpen = errors.New("circuit breaker is open")
+
+// breaker is the circuit breaker requests go through. It is nil if -circuit-breaker-failures isn't set.
+var breaker *circuitBreaker
+
+// circuitBreaker stops sending requests after a number of them fail in a row, so a struggling server
+// gets time to recover. After a cooldown, one request is let through: if it succeeds, requests are sent
+// again, otherwise the breaker opens for another cooldown. It is safe for concurrent use.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+	// transitions counts state changes, by the state changed to. It is nil in tests.
+	transitions *metric.Int64Counter
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// probing is set when the request that decides if a half-open breaker closes is in flight.
+	probing bool
+}
+
+// newCircuitBreaker returns a circuitBreaker that opens after threshold failures in a row, for cooldown.
+// Its state and state changes are reported as the demo_client/circuit_breaker_state and
+// demo_client/circuit_breaker_transitions metrics.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	b := &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+
+	meter := metric.Must(global.Meter(meterName))
+	transitions := meter.NewInt64Counter(
+		"demo_client/circuit_breaker_transitions",
+		metric.WithDescription("The number of times the circuit breaker changed state, by the state it changed to"),
+	)
+	b.transitions = &transitions
+	meter.NewInt64GaugeObserver(
+		"demo_client/circuit_breaker_state",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			result.Observe(int64(b.state))
+		},
+		metric.WithDescription("The state of the circuit breaker: 0 closed, 1 half-open, 2 open"),
+	)
+	return b
+}
+
+// allow returns errBreakerOpen if a request shouldn't be sent. Otherwise the request must be sent and its
+// outcome passed to record. State changes are added as events to the span in ctx.
+func (b *circuitBreaker) allow(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return errBreakerOpen
+		}
+		b.setState(ctx, breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return errBreakerOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record records the outcome of a request allow let through.
+func (b *circuitBreaker) record(ctx context.Context, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if failed {
+			b.open(ctx)
+		} else {
+			b.failures = 0
+			b.setState(ctx, breakerClosed)
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerClosed && b.failures >= b.threshold {
+		b.open(ctx)
+	}
+}
+
+// open opens the breaker for a cooldown. b.mu must be held.
+func (b *circuitBreaker) open(ctx context.Context) {
+	b.openedAt = b.now()
+	b.setState(ctx, breakerOpen)
+}
+
+// setState changes the breaker's state, recording the change on the span in ctx and in the transitions
+// metric. b.mu must be held.
+func (b *circuitBreaker) setState(ctx context.Context, to breakerState) {
+	from := b.state
+	b.state = to
+	trace.SpanFromContext(ctx).AddEvent(
+		"circuit breaker state changed",
+		trace.WithAttributes(
+			attribute.String("circuit_breaker.from", from.String()),
+			attribute.String("circuit_breaker.to", to.String()),
+		),
+	)
+	if b.transitions != nil {
+		b.transitions.Add(ctx, 1, attribute.String("state", to.String()))
+	}
+}
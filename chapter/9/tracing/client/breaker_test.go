@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	b := &circuitBreaker{threshold: 3, cooldown: 10 * time.Second, now: func() time.Time { return now }}
+
+	// Failures that aren't in a row don't open the breaker.
+	for _, failed := range []bool{true, true, false, true, true} {
+		if err := b.allow(ctx); err != nil {
+			t.Fatalf("TestCircuitBreaker: got err == %s, want err == nil", err)
+		}
+		b.record(ctx, failed)
+	}
+	if b.state != breakerClosed {
+		t.Fatalf("TestCircuitBreaker: got state %s, want closed", b.state)
+	}
+
+	b.record(ctx, true)
+	if b.state != breakerOpen {
+		t.Fatalf("TestCircuitBreaker: got state %s after 3 failures, want open", b.state)
+	}
+	if err := b.allow(ctx); err != errBreakerOpen {
+		t.Fatalf("TestCircuitBreaker: got err == %v while open, want errBreakerOpen", err)
+	}
+
+	// After the cooldown one request is let through, and fails.
+	now = now.Add(10 * time.Second)
+	if err := b.allow(ctx); err != nil {
+		t.Fatalf("TestCircuitBreaker: got err == %s after cooldown, want err == nil", err)
+	}
+	if err := b.allow(ctx); err != errBreakerOpen {
+		t.Fatalf("TestCircuitBreaker: got err == %v with a probe in flight, want errBreakerOpen", err)
+	}
+	b.record(ctx, true)
+	if b.state != breakerOpen {
+		t.Fatalf("TestCircuitBreaker: got state %s after failed probe, want open", b.state)
+	}
+
+	// The next probe succeeds and closes the breaker.
+	now = now.Add(10 * time.Second)
+	if err := b.allow(ctx); err != nil {
+		t.Fatalf("TestCircuitBreaker: got err == %s after cooldown, want err == nil", err)
+	}
+	b.record(ctx, false)
+	if b.state != breakerClosed {
+		t.Fatalf("TestCircuitBreaker: got state %s after successful probe, want closed", b.state)
+	}
+}
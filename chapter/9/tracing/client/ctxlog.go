@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ctxFieldKey is the key of the field Ctx returns. The field is never encoded.
+const ctxFieldKey = "_ctx"
+
+// Ctx returns a field that carries ctx to the logger, which adds the span and trace IDs of the span in
+// ctx to the entry. Pass it with an entry, like logger.Info("msg", Ctx(ctx)), or to With() to add the
+// IDs to every entry of a logger.
+func Ctx(ctx context.Context) zap.Field {
+	return zap.Field{Key: ctxFieldKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// contextFromFields returns the context carried by a field from Ctx, or nil if there isn't one, and
+// fields without it.
+func contextFromFields(fields []zapcore.Field) (context.Context, []zapcore.Field) {
+	for i, f := range fields {
+		if f.Key != ctxFieldKey || f.Type != zapcore.SkipType {
+			continue
+		}
+		ctx, ok := f.Interface.(context.Context)
+		if !ok {
+			continue
+		}
+		rest := make([]zapcore.Field, 0, len(fields)-1)
+		rest = append(rest, fields[:i]...)
+		rest = append(rest, fields[i+1:]...)
+		return ctx, rest
+	}
+	return nil, fields
+}
+
+// spanContextFromFields returns the span context carried by a field from Ctx, and fields without it.
+func spanContextFromFields(fields []zapcore.Field) (trace.SpanContext, []zapcore.Field) {
+	ctx, fields := contextFromFields(fields)
+	if ctx == nil {
+		return trace.SpanContext{}, fields
+	}
+	return trace.SpanContextFromContext(ctx), fields
+}
+
+// traceCore is a zapcore.Core that replaces a field from Ctx with the span_id and trace_id fields
+// WithCorrelation adds, so callers only need the context to correlate their logs with traces.
+//
+// With -log-span-events, warn and error entries are also added as events to the span, so trace viewers
+// show them with the operation that logged them.
+type traceCore struct {
+	zapcore.Core
+	// ctx is the context from a Ctx field passed to With.
+	ctx context.Context
+	// fields are the fields passed to With, which are added to span events.
+	fields []zapcore.Field
+}
+
+// withTraceIDs returns fields with the IDs of the span in ctx added.
+func withTraceIDs(ctx context.Context, fields []zapcore.Field) []zapcore.Field {
+	if ctx == nil {
+		return fields
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return fields
+	}
+	return append(
+		fields,
+		zap.String("span_id", convertTraceID(sc.SpanID().String())),
+		zap.String("trace_id", convertTraceID(sc.TraceID().String())),
+	)
+}
+
+// With implements zapcore.Core.With.
+func (c traceCore) With(fields []zapcore.Field) zapcore.Core {
+	ctx, fields := contextFromFields(fields)
+	all := append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	if ctx == nil {
+		// The IDs of c.ctx were added by the With call that set it.
+		return traceCore{Core: c.Core.With(fields), ctx: c.ctx, fields: all}
+	}
+	return traceCore{Core: c.Core.With(withTraceIDs(ctx, fields)), ctx: ctx, fields: all}
+}
+
+// Check implements zapcore.Core.Check.
+func (c traceCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core.Write.
+func (c traceCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ctx, fields := contextFromFields(fields)
+	if *logSpanEvents && ent.Level >= zapcore.WarnLevel {
+		spanCtx := ctx
+		if spanCtx == nil {
+			spanCtx = c.ctx
+		}
+		if spanCtx != nil {
+			addLogEvent(trace.SpanFromContext(spanCtx), ent, c.fields, fields)
+		}
+	}
+	return c.Core.Write(ent, withTraceIDs(ctx, fields))
+}
+
+// addLogEvent adds ent to span as an event named "log", with its level, message and fields as attributes.
+// Fields are added as attributes of the same type when OpenTelemetry has one, and as strings otherwise.
+func addLogEvent(span trace.Span, ent zapcore.Entry, fieldLists ...[]zapcore.Field) {
+	if !span.IsRecording() {
+		return
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, fields := range fieldLists {
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(enc.Fields)+2)
+	attrs = append(
+		attrs,
+		attribute.String("log.severity", ent.Level.CapitalString()),
+		attribute.String("log.message", ent.Message),
+	)
+	for k, v := range enc.Fields {
+		switch x := v.(type) {
+		case string:
+			attrs = append(attrs, attribute.String(k, x))
+		case bool:
+			attrs = append(attrs, attribute.Bool(k, x))
+		case int64:
+			attrs = append(attrs, attribute.Int64(k, x))
+		case float64:
+			attrs = append(attrs, attribute.Float64(k, x))
+		default:
+			attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+		}
+	}
+	span.AddEvent("log", trace.WithTimestamp(ent.Time), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTraceCore(t *testing.T) {
+	// The IDs are logged as the decimal value of their low 64 bits, see convertTraceID.
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{15: 42}, SpanID: trace.SpanID{7: 7}})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	tests := []struct {
+		desc   string
+		with   []zap.Field
+		fields []zap.Field
+		want   map[string]interface{}
+	}{
+		{
+			desc:   "No context",
+			fields: []zap.Field{zap.String("url", "/hello")},
+			want:   map[string]interface{}{"url": "/hello"},
+		},
+		{
+			desc:   "Ctx with the entry",
+			fields: []zap.Field{Ctx(ctx), zap.String("url", "/hello")},
+			want:   map[string]interface{}{"url": "/hello", "span_id": "7", "trace_id": "42"},
+		},
+		{
+			desc:   "Ctx passed to With",
+			with:   []zap.Field{Ctx(ctx)},
+			fields: []zap.Field{zap.String("url", "/hello")},
+			want:   map[string]interface{}{"url": "/hello", "span_id": "7", "trace_id": "42"},
+		},
+		{
+			desc:   "Ctx without a span",
+			fields: []zap.Field{Ctx(context.Background())},
+			want:   map[string]interface{}{},
+		},
+	}
+
+	for _, test := range tests {
+		core, logs := observer.New(zapcore.DebugLevel)
+		l := zap.New(traceCore{Core: core})
+		if test.with != nil {
+			l = l.With(test.with...)
+		}
+		l.Info("sent request", test.fields...)
+
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Errorf("TestTraceCore(%s): got %d entries, want 1", test.desc, len(entries))
+			continue
+		}
+		if diff := pretty.Compare(test.want, entries[0].ContextMap()); diff != "" {
+			t.Errorf("TestTraceCore(%s): fields -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestTraceCoreSpanEvents(t *testing.T) {
+	old := *logSpanEvents
+	defer func() { *logSpanEvents = old }()
+	*logSpanEvents = true
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("ctxlog_test")
+	ctx, span := tracer.Start(context.Background(), "Request")
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := zap.New(traceCore{Core: core}).With(zap.String("worker", "1"))
+	l.Info("sent request", Ctx(ctx))
+	l.Warn("slow response", Ctx(ctx), zap.Int("attempt", 2))
+	l.With(Ctx(ctx)).Error("request failed")
+	span.End()
+
+	// Every entry is still logged.
+	if logs.Len() != 3 {
+		t.Errorf("TestTraceCoreSpanEvents: got %d entries, want 3", logs.Len())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("TestTraceCoreSpanEvents: got %d spans, want 1", len(spans))
+	}
+	var got []map[attribute.Key]interface{}
+	for _, e := range spans[0].Events() {
+		if e.Name != "log" {
+			t.Errorf("TestTraceCoreSpanEvents: got event %q, want %q", e.Name, "log")
+		}
+		attrs := map[attribute.Key]interface{}{}
+		for _, kv := range e.Attributes {
+			attrs[kv.Key] = kv.Value.AsInterface()
+		}
+		got = append(got, attrs)
+	}
+	// Only warn and error entries become events, with the fields of both With and the entry.
+	want := []map[attribute.Key]interface{}{
+		{"log.severity": "WARN", "log.message": "slow response", "worker": "1", "attempt": int64(2)},
+		{"log.severity": "ERROR", "log.message": "request failed", "worker": "1"},
+	}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestTraceCoreSpanEvents: events -want/+got:\n%s", diff)
+	}
+}
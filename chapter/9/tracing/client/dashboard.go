@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// dashboard is the -tui terminal UI, or nil without it.
+var dashboard *terminalDashboard
+
+// The dashboard shows the requests of the last dashboardWindow and the last dashboardTraces traces,
+// redrawn every dashboardRefresh.
+const (
+	dashboardWindow  = 10 * time.Second
+	dashboardTraces  = 8
+	dashboardRefresh = 500 * time.Millisecond
+)
+
+// terminalDashboard shows the rate, errors and latency of recent requests, the depth of the export
+// queues, and links to the last traces, for watching the demo live in workshops. It replaces the log on
+// stderr while it runs.
+type terminalDashboard struct {
+	stats  *rollingStats
+	traces *recentTraces
+}
+
+func newTerminalDashboard() *terminalDashboard {
+	return &terminalDashboard{
+		stats:  newRollingStats(time.Now(), dashboardWindow),
+		traces: &recentTraces{max: dashboardTraces},
+	}
+}
+
+// start shows the dashboard until the returned func is called. Quitting it with 'q' or Ctrl-C calls
+// stop, which stops the client like SIGINT.
+func (d *terminalDashboard) start(stop func()) func() {
+	p := tea.NewProgram(dashboardModel{dashboard: d, stop: stop}, tea.WithAltScreen())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := p.Run(); err != nil {
+			logger.Error("terminal dashboard failed", zap.Error(err))
+			stop()
+		}
+	}()
+	// The terminal is restored once the program has quit.
+	return func() {
+		p.Quit()
+		<-done
+	}
+}
+
+// dashboardModel is the tea.Model of the dashboard.
+type dashboardModel struct {
+	dashboard *terminalDashboard
+	stop      func()
+	now       time.Time
+}
+
+// refreshMsg asks for the dashboard to be redrawn.
+type refreshMsg time.Time
+
+func refresh() tea.Cmd {
+	return tea.Tick(dashboardRefresh, func(t time.Time) tea.Msg { return refreshMsg(t) })
+}
+
+// Init implements tea.Model.Init.
+func (m dashboardModel) Init() tea.Cmd {
+	return refresh()
+}
+
+// Update implements tea.Model.Update.
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			m.stop()
+			return m, tea.Quit
+		}
+	case refreshMsg:
+		m.now = time.Time(msg)
+		return m, refresh()
+	}
+	return m, nil
+}
+
+// View implements tea.Model.View.
+func (m dashboardModel) View() string {
+	now := m.now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s := m.dashboard.stats.snapshot(now)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, the last %s  (q to quit)\n\n", serviceName, dashboardWindow)
+	fmt.Fprintf(&b, "  requests     %d sent, %d failed since start\n", s.totalSent, s.totalFailed)
+	fmt.Fprintf(&b, "  rate         %.1f req/s\n", s.qps)
+	fmt.Fprintf(&b, "  error rate   %.1f%%\n", 100*s.errorRate)
+	fmt.Fprintf(&b, "  latency      p50 %s   p95 %s   p99 %s\n", s.p50, s.p95, s.p99)
+	for _, q := range exportQueues {
+		fmt.Fprintf(&b, "  export queue %d spans, %d dropped (%s)\n", atomic.LoadInt64(&q.spans), atomic.LoadInt64(&q.dropped), q.name)
+	}
+
+	b.WriteString("\nlast traces\n")
+	for _, t := range m.dashboard.traces.list() {
+		status := "ok"
+		if t.failed {
+			status = "error"
+		}
+		fmt.Fprintf(&b, "  %-5s %8s  %-20s %s\n", status, t.duration.Round(time.Millisecond), t.name, traceLink(t.id))
+	}
+	return b.String()
+}
+
+// traceLink returns the -trace-url of the trace id.
+func traceLink(id trace.TraceID) string {
+	return strings.ReplaceAll(*traceURL, "{traceID}", id.String())
+}
+
+// rollingStats keeps the requests that ended in the last window, for rates and percentiles that follow
+// changes in load, unlike loadStats, which totals the whole run.
+type rollingStats struct {
+	start  time.Time
+	window time.Duration
+
+	mu          sync.Mutex
+	totalSent   int
+	totalFailed int
+	// results are in the order requests ended.
+	results []requestResult
+}
+
+// requestResult is a request recorded by rollingStats.
+type requestResult struct {
+	end     time.Time
+	latency time.Duration
+	failed  bool
+}
+
+func newRollingStats(start time.Time, window time.Duration) *rollingStats {
+	return &rollingStats{start: start, window: window}
+}
+
+// record adds a request that ended at end and took latency.
+func (r *rollingStats) record(end time.Time, latency time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.totalSent++
+	if failed {
+		r.totalFailed++
+	}
+	r.results = append(r.results, requestResult{end: end, latency: latency, failed: failed})
+}
+
+// rollingSnapshot is the rate, errors and latency of the requests in a rollingStats window.
+type rollingSnapshot struct {
+	totalSent, totalFailed int
+	qps, errorRate         float64
+	p50, p95, p99          time.Duration
+}
+
+// snapshot returns the stats of the requests that ended in the window before now, and forgets older ones.
+func (r *rollingStats) snapshot(now time.Time) rollingSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := now.Add(-r.window)
+	i := sort.Search(len(r.results), func(i int) bool { return r.results[i].end.After(cutoff) })
+	r.results = append(r.results[:0], r.results[i:]...)
+
+	s := rollingSnapshot{totalSent: r.totalSent, totalFailed: r.totalFailed}
+	if len(r.results) == 0 {
+		return s
+	}
+	// Until a whole window has passed, the rate is over the time since the start.
+	window := r.window
+	if elapsed := now.Sub(r.start); elapsed < window {
+		window = elapsed
+	}
+	failed := 0
+	sorted := make([]time.Duration, len(r.results))
+	for i, res := range r.results {
+		sorted[i] = res.latency
+		if res.failed {
+			failed++
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	s.qps = float64(len(r.results)) / window.Seconds()
+	s.errorRate = float64(failed) / float64(len(r.results))
+	s.p50, s.p95, s.p99 = percentile(sorted, 0.50), percentile(sorted, 0.95), percentile(sorted, 0.99)
+	return s
+}
+
+// recentTraces is a sdktrace.SpanProcessor that keeps the last sampled traces the client started, from
+// their root spans, newest first.
+type recentTraces struct {
+	max int
+
+	mu     sync.Mutex
+	traces []recentTrace
+}
+
+// recentTrace is a trace kept by recentTraces.
+type recentTrace struct {
+	id       trace.TraceID
+	name     string
+	duration time.Duration
+	failed   bool
+}
+
+// OnStart implements sdktrace.SpanProcessor.OnStart.
+func (r *recentTraces) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd implements sdktrace.SpanProcessor.OnEnd.
+func (r *recentTraces) OnEnd(s sdktrace.ReadOnlySpan) {
+	// A span with a remote parent, like one from -traceparent, is where the client's part of a trace starts.
+	if !s.SpanContext().IsSampled() || (s.Parent().IsValid() && !s.Parent().IsRemote()) {
+		return
+	}
+	t := recentTrace{
+		id:       s.SpanContext().TraceID(),
+		name:     s.Name(),
+		duration: s.EndTime().Sub(s.StartTime()),
+		failed:   s.Status().Code == codes.Error,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.traces = append([]recentTrace{t}, r.traces...)
+	if len(r.traces) > r.max {
+		r.traces = r.traces[:r.max]
+	}
+}
+
+// list returns the traces, newest first.
+func (r *recentTraces) list() []recentTrace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recentTrace(nil), r.traces...)
+}
+
+// Shutdown implements sdktrace.SpanProcessor.Shutdown.
+func (r *recentTraces) Shutdown(context.Context) error { return nil }
+
+// ForceFlush implements sdktrace.SpanProcessor.ForceFlush.
+func (r *recentTraces) ForceFlush(context.Context) error { return nil }
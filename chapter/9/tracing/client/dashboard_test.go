@@ -0,0 +1,61 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollingStats(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newRollingStats(start, 10*time.Second)
+	// Ten requests a second for 20 seconds, taking 1 to 10ms, with the last of every ten failing.
+	for i := 0; i < 200; i++ {
+		end := start.Add(time.Duration(i+1) * 100 * time.Millisecond)
+		r.record(end, time.Duration(i%10+1)*time.Millisecond, i%10 == 9)
+	}
+
+	tests := []struct {
+		desc          string
+		now           time.Time
+		wantQPS       float64
+		wantErrorRate float64
+		wantP50       time.Duration
+		wantP99       time.Duration
+	}{
+		{
+			desc:          "Full window",
+			now:           start.Add(20 * time.Second),
+			wantQPS:       10,
+			wantErrorRate: 0.1,
+			wantP50:       5 * time.Millisecond,
+			wantP99:       10 * time.Millisecond,
+		},
+		{
+			desc: "Requests have stopped",
+			now:  start.Add(time.Minute),
+		},
+	}
+
+	for _, test := range tests {
+		got := r.snapshot(test.now)
+		if got.totalSent != 200 || got.totalFailed != 20 {
+			t.Errorf("TestRollingStats(%s): got %d sent and %d failed, want 200 and 20", test.desc, got.totalSent, got.totalFailed)
+		}
+		if got.qps != test.wantQPS {
+			t.Errorf("TestRollingStats(%s): got qps %v, want %v", test.desc, got.qps, test.wantQPS)
+		}
+		if got.errorRate != test.wantErrorRate {
+			t.Errorf("TestRollingStats(%s): got error rate %v, want %v", test.desc, got.errorRate, test.wantErrorRate)
+		}
+		if got.p50 != test.wantP50 || got.p99 != test.wantP99 {
+			t.Errorf("TestRollingStats(%s): got p50 %s and p99 %s, want %s and %s", test.desc, got.p50, got.p99, test.wantP50, test.wantP99)
+		}
+	}
+
+	// The rate is over the time since the start until a whole window has passed.
+	r = newRollingStats(start, 10*time.Second)
+	r.record(start.Add(time.Second), time.Millisecond, false)
+	if got := r.snapshot(start.Add(2 * time.Second)); got.qps != 0.5 {
+		t.Errorf("TestRollingStats(Partial window): got qps %v, want 0.5", got.qps)
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expect are assertions about a response, of a scenario step or of every request with the -expect-*
+// flags. A response that fails them fails its request, and the span's status says why.
+type Expect struct {
+	// Status is the expected status code. If 0, any status below 400 is expected.
+	Status int `yaml:"status"`
+	// BodyContains is a string the response body must contain.
+	BodyContains string `yaml:"body_contains"`
+	// JSONPath is the path of a value the response body, which must be JSON, must have, like 'items.0.id'.
+	JSONPath string `yaml:"json_path"`
+	// JSONValue, if set, is the value expected at JSONPath. Values other than strings are compared as
+	// JSON, like 42, true or null.
+	JSONValue string `yaml:"json_value"`
+	// MaxLatency, if set, is the longest the response may take.
+	MaxLatency time.Duration `yaml:"max_latency"`
+}
+
+// expectation are the -expect-* assertions about the responses of single requests. It is nil if no
+// -expect-* flag is set.
+var expectation *Expect
+
+// expectFromFlags returns the Expect set by the -expect-* flags, or nil if none are set.
+func expectFromFlags() (*Expect, error) {
+	e := &Expect{
+		Status:       *expectStatus,
+		BodyContains: *expectBody,
+		MaxLatency:   *expectMaxLatency,
+	}
+	if *expectJSON != "" {
+		// A value is only compared when there is an '='.
+		kv := strings.SplitN(*expectJSON, "=", 2)
+		e.JSONPath = strings.TrimSpace(kv[0])
+		if len(kv) == 2 {
+			e.JSONValue = strings.TrimSpace(kv[1])
+		}
+		if e.JSONPath == "" {
+			return nil, fmt.Errorf("-expect-json=%s is not a valid value", *expectJSON)
+		}
+	}
+	if e.Status != 0 && (e.Status < 100 || e.Status > 599) {
+		return nil, fmt.Errorf("-expect-status=%d is not a valid value", e.Status)
+	}
+	if e.MaxLatency < 0 {
+		return nil, fmt.Errorf("-expect-max-latency cannot be negative")
+	}
+	if *e == (Expect{}) {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// check returns an error if the status, body or latency of a response don't meet e.
+func (e Expect) check(status int, body []byte, latency time.Duration) error {
+	switch {
+	case e.Status != 0 && status != e.Status:
+		return fmt.Errorf("got status %d, expected %d", status, e.Status)
+	case e.Status == 0 && status >= 400:
+		return fmt.Errorf("got status %d", status)
+	case e.BodyContains != "" && !bytes.Contains(body, []byte(e.BodyContains)):
+		return fmt.Errorf("response body does not contain %q", e.BodyContains)
+	case e.MaxLatency > 0 && latency > e.MaxLatency:
+		return fmt.Errorf("response took %s, more than %s", latency, e.MaxLatency)
+	}
+	if e.JSONPath == "" {
+		return nil
+	}
+	v, err := jsonPath(body, e.JSONPath)
+	if err != nil {
+		return err
+	}
+	if got := jsonString(v); e.JSONValue != "" && got != e.JSONValue {
+		return fmt.Errorf("response body has %s at %s, expected %s", got, e.JSONPath, e.JSONValue)
+	}
+	return nil
+}
+
+// jsonPath returns the value at path in the JSON document b. Paths are object keys and array indexes
+// separated by dots, like 'items.0.id', which can also be written '$.items[0].id'.
+func jsonPath(b []byte, path string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("response body is not JSON: %w", err)
+	}
+
+	p := strings.NewReplacer("[", ".", "]", "").Replace(path)
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	if p == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(p, ".") {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = x[key]; !ok {
+				return nil, fmt.Errorf("response body has nothing at %s", path)
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, fmt.Errorf("response body has nothing at %s", path)
+			}
+			v = x[i]
+		default:
+			return nil, fmt.Errorf("response body has nothing at %s", path)
+		}
+	}
+	return v, nil
+}
+
+// jsonString returns v as it is compared with Expect.JSONValue: strings as they are, and other values as JSON.
+func jsonString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
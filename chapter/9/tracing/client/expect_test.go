@@ -0,0 +1,104 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpectCheck(t *testing.T) {
+	body := []byte(`{"status": "ok", "items": [{"id": 7, "ready": true}]}`)
+
+	tests := []struct {
+		desc    string
+		expect  Expect
+		status  int
+		body    []byte
+		latency time.Duration
+		wantErr bool
+	}{
+		{
+			desc:   "Defaults accept a success",
+			status: 200,
+		},
+		{
+			desc:    "Defaults reject a client error",
+			status:  404,
+			wantErr: true,
+		},
+		{
+			desc:    "Wrong status",
+			expect:  Expect{Status: 201},
+			status:  200,
+			wantErr: true,
+		},
+		{
+			desc:    "Missing body substring",
+			expect:  Expect{BodyContains: "Hello"},
+			status:  200,
+			body:    body,
+			wantErr: true,
+		},
+		{
+			desc:    "Too slow",
+			expect:  Expect{MaxLatency: 100 * time.Millisecond},
+			status:  200,
+			latency: 150 * time.Millisecond,
+			wantErr: true,
+		},
+		{
+			desc:    "Fast enough",
+			expect:  Expect{MaxLatency: 100 * time.Millisecond},
+			status:  200,
+			latency: 100 * time.Millisecond,
+		},
+		{
+			desc:   "JSON path exists",
+			expect: Expect{JSONPath: "items.0.id"},
+			status: 200,
+			body:   body,
+		},
+		{
+			desc:   "JSON string value",
+			expect: Expect{JSONPath: "$.status", JSONValue: "ok"},
+			status: 200,
+			body:   body,
+		},
+		{
+			desc:   "JSON number and bool values with brackets",
+			expect: Expect{JSONPath: "$.items[0].ready", JSONValue: "true"},
+			status: 200,
+			body:   body,
+		},
+		{
+			desc:    "JSON value differs",
+			expect:  Expect{JSONPath: "items.0.id", JSONValue: "8"},
+			status:  200,
+			body:    body,
+			wantErr: true,
+		},
+		{
+			desc:    "JSON path missing",
+			expect:  Expect{JSONPath: "items.1.id"},
+			status:  200,
+			body:    body,
+			wantErr: true,
+		},
+		{
+			desc:    "Body isn't JSON",
+			expect:  Expect{JSONPath: "status"},
+			status:  200,
+			body:    []byte("Hello"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		err := test.expect.check(test.status, test.body, test.latency)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestExpectCheck(%s): got err == nil, want err != nil", test.desc)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestExpectCheck(%s): got err == %s, want err == nil", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/env"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Exporter represents the backend spans are exported to.
+type Exporter interface {
+	// Name returns the name of the backend, as -exporter spells it, for logs and metric attributes.
+	Name() string
+	isExporter()
+}
+
+// OTLPGRPC exports spans to an OpenTelemetry collector using OTLP over gRPC.
+type OTLPGRPC struct {
+	// Addr is the host:port of the collector's gRPC receiver.
+	Addr string
+	// TLS is the TLS configuration for the connection. If nil, the connection is plaintext.
+	TLS *tls.Config
+	// Headers are sent as gRPC metadata with every export, commonly used for API keys.
+	Headers map[string]string
+}
+
+// Name implements Exporter.Name.
+func (o OTLPGRPC) Name() string { return "otlpgrpc" }
+
+func (o OTLPGRPC) isExporter() {}
+
+// OTLPHTTP exports spans to an OpenTelemetry collector using OTLP over HTTP.
+type OTLPHTTP struct {
+	// Addr is the host:port of the collector's HTTP receiver.
+	Addr string
+	// TLS is the TLS configuration for the connection. If nil, the connection is plaintext.
+	TLS *tls.Config
+	// URLPath is the path spans are posted to. If empty, it is /v1/traces.
+	URLPath string
+	// Headers are sent as HTTP headers with every export, commonly used for API keys.
+	Headers map[string]string
+}
+
+// Name implements Exporter.Name.
+func (o OTLPHTTP) Name() string { return "otlphttp" }
+
+func (o OTLPHTTP) isExporter() {}
+
+// Stdout writes spans to os.Stdout in a human readable format.
+type Stdout struct{}
+
+// Name implements Exporter.Name.
+func (s Stdout) Name() string { return "stdout" }
+
+func (s Stdout) isExporter() {}
+
+// Zipkin exports spans to a Zipkin collector.
+type Zipkin struct {
+	// URL is the collector's span endpoint, like http://localhost:9411/api/v2/spans.
+	URL string
+}
+
+// Name implements Exporter.Name.
+func (z Zipkin) Name() string { return "zipkin" }
+
+func (z Zipkin) isExporter() {}
+
+// File writes spans to a file as newline delimited JSON, for inspecting traces offline.
+// The file is rotated when it grows past MaxSizeMB.
+type File struct {
+	// Path is the path to the file.
+	Path string
+	// MaxSizeMB is the size in megabytes at which the file is rotated.
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files to keep. 0 keeps all of them.
+	MaxBackups int
+}
+
+// Name implements Exporter.Name.
+func (f File) Name() string { return "file" }
+
+func (f File) isExporter() {}
+
+// exportersFromFlags returns the Exporters listed in the -exporter flag, or with -dry-run, Stdout.
+func exportersFromFlags() ([]Exporter, error) {
+	if *dryRun {
+		return []Exporter{Stdout{}}, nil
+	}
+	var exporters []Exporter
+	seen := map[string]bool{}
+	for _, name := range strings.Split(*exporterName, ",") {
+		name = strings.TrimSpace(name)
+		e, err := exporterFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		// Every exporter is sent all the spans, so the same backend listed twice, even by another name
+		// like otlp and otlpgrpc, would get each span twice.
+		t := fmt.Sprintf("%T", e)
+		if seen[t] {
+			return nil, fmt.Errorf("-exporter: %q sends spans to the same backend as an exporter listed before it", name)
+		}
+		seen[t] = true
+		exporters = append(exporters, e)
+	}
+	return exporters, nil
+}
+
+// exporterFromName returns the Exporter for a single name from the -exporter flag.
+func exporterFromName(name string) (Exporter, error) {
+	switch strings.ToLower(name) {
+	case "otlp", "otlpgrpc":
+		tlsConf, err := telemetryflags.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		headers, err := telemetryflags.Headers()
+		if err != nil {
+			return nil, err
+		}
+		// OTEL_EXPORTER_OTLP_PROTOCOL lets the standard "otlp" value select HTTP.
+		if strings.HasPrefix(env.Or("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc"), "http") {
+			return otlpHTTPFromFlags(tlsConf, headers)
+		}
+		return OTLPGRPC{Addr: *otlpEndpoint, TLS: tlsConf, Headers: headers}, nil
+	case "otlphttp":
+		tlsConf, err := telemetryflags.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		headers, err := telemetryflags.Headers()
+		if err != nil {
+			return nil, err
+		}
+		return otlpHTTPFromFlags(tlsConf, headers)
+	case "stdout":
+		return Stdout{}, nil
+	case "zipkin":
+		return Zipkin{URL: *zipkinEndpoint}, nil
+	case "file":
+		return File{Path: *spanFile, MaxSizeMB: *spanFileMaxSize, MaxBackups: *spanFileMaxBackups}, nil
+	}
+	return nil, fmt.Errorf("-exporter: %q is not a valid exporter", name)
+}
+
+// otlpHTTPFromFlags returns the OTLPHTTP exporter for -otlp-http-endpoint. Unlike -otlp-endpoint, it may be a
+// URL, as OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is, with the path spans are posted to.
+func otlpHTTPFromFlags(tlsConf *tls.Config, headers map[string]string) (Exporter, error) {
+	e := OTLPHTTP{Addr: *otlpHTTPEndpoint, TLS: tlsConf, Headers: headers}
+	if !strings.Contains(e.Addr, "://") {
+		return e, nil
+	}
+	u, err := url.Parse(e.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("-otlp-http-endpoint: %w", err)
+	}
+	e.Addr, e.URLPath = u.Host, u.Path
+	if u.Scheme == "https" && e.TLS == nil {
+		e.TLS = &tls.Config{}
+	}
+	return e, nil
+}
+
+// newExporter creates the sdktrace.SpanExporter that sends spans to e.
+func newExporter(ctx context.Context, e Exporter) (sdktrace.SpanExporter, error) {
+	switch v := e.(type) {
+	case OTLPGRPC:
+		return otlpGRPC(ctx, v)
+	case OTLPHTTP:
+		return otlpHTTP(ctx, v)
+	case Stdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case Zipkin:
+		return zipkin.New(v.URL)
+	case File:
+		return newFileExporter(v)
+	}
+	return nil, fmt.Errorf("%T is not a valid Exporter", e)
+}
+
+// newFileExporter creates an exporter that writes one JSON encoded span per line to a rotated file.
+func newFileExporter(f File) (sdktrace.SpanExporter, error) {
+	if f.Path == "" {
+		return nil, fmt.Errorf("-span-file must be set to use the file exporter")
+	}
+	w := &lumberjack.Logger{
+		Filename:   f.Path,
+		MaxSize:    f.MaxSizeMB,
+		MaxBackups: f.MaxBackups,
+	}
+	// Without pretty printing, stdouttrace encodes each span on its own line.
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	return fileExporter{Exporter: exp, file: w}, nil
+}
+
+// fileExporter is a stdouttrace.Exporter that closes its file on Shutdown.
+type fileExporter struct {
+	*stdouttrace.Exporter
+	file io.Closer
+}
+
+// Shutdown implements sdktrace.SpanExporter.Shutdown. The file is closed even if ctx is done first.
+func (f fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(f.Exporter.Shutdown(ctx), f.file.Close())
+}
+
+// otlpGRPC creates an OTLP exporter that sends spans over gRPC, see telemetryflags.TraceExporter.
+func otlpGRPC(ctx context.Context, e OTLPGRPC) (sdktrace.SpanExporter, error) {
+	return telemetryflags.TraceExporter(ctx, logger, e.Addr, e.TLS, e.Headers)
+}
+
+// otlpHTTP creates an OTLP exporter that sends spans over HTTP.
+func otlpHTTP(ctx context.Context, e OTLPHTTP) (sdktrace.SpanExporter, error) {
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(e.Addr),
+	}
+	if e.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(e.URLPath))
+	}
+	if len(e.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(e.Headers))
+	}
+	if e.TLS != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(e.TLS))
+	} else {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+}
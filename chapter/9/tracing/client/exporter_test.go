@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTLPHTTPFromFlags(t *testing.T) {
+	tests := []struct {
+		desc     string
+		endpoint string
+		wantAddr string
+		wantPath string
+		wantTLS  bool
+	}{
+		{desc: "Default", endpoint: "0.0.0.0:4318", wantAddr: "0.0.0.0:4318"},
+		{desc: "HTTP URL", endpoint: "http://otel-collector:4318/v1/traces", wantAddr: "otel-collector:4318", wantPath: "/v1/traces"},
+		{desc: "HTTPS URL enables TLS", endpoint: "https://api.honeycomb.io/v1/traces", wantAddr: "api.honeycomb.io", wantPath: "/v1/traces", wantTLS: true},
+	}
+
+	old := *otlpHTTPEndpoint
+	defer func() { *otlpHTTPEndpoint = old }()
+
+	for _, test := range tests {
+		*otlpHTTPEndpoint = test.endpoint
+		e, err := otlpHTTPFromFlags(nil, nil)
+		if err != nil {
+			t.Errorf("TestOTLPHTTPFromFlags(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		got := e.(OTLPHTTP)
+		if got.Addr != test.wantAddr || got.URLPath != test.wantPath || (got.TLS != nil) != test.wantTLS {
+			t.Errorf("TestOTLPHTTPFromFlags(%s): got addr %q, path %q, TLS %t, want %q, %q, %t",
+				test.desc, got.Addr, got.URLPath, got.TLS != nil, test.wantAddr, test.wantPath, test.wantTLS)
+		}
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	// Each span takes over 1KiB, so a thousand of them fill a 1MB file.
+	span := tracetest.SpanStub{Name: strings.Repeat("x", 1024)}.Snapshot()
+
+	tests := []struct {
+		desc      string
+		spans     int
+		wantFiles int
+	}{
+		{desc: "Below the max size", spans: 10, wantFiles: 1},
+		{desc: "Rotated", spans: 1100, wantFiles: 2},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		dir := t.TempDir()
+		exp, err := newFileExporter(File{Path: filepath.Join(dir, "spans.jsonl"), MaxSizeMB: 1})
+		if err != nil {
+			t.Fatalf("TestFileExporter(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		spans := make([]sdktrace.ReadOnlySpan, test.spans)
+		for i := range spans {
+			spans[i] = span
+		}
+		if err := exp.ExportSpans(ctx, spans); err != nil {
+			t.Errorf("TestFileExporter(%s): ExportSpans: got err == %s, want err == nil", test.desc, err)
+		}
+		if err := exp.Shutdown(ctx); err != nil {
+			t.Errorf("TestFileExporter(%s): Shutdown: got err == %s, want err == nil", test.desc, err)
+		}
+		// Spans exported after Shutdown are dropped rather than written to a reopened file.
+		exp.ExportSpans(ctx, spans[:1])
+
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("TestFileExporter(%s): %s", test.desc, err)
+		}
+		if len(files) != test.wantFiles {
+			t.Errorf("TestFileExporter(%s): got %d files, want %d", test.desc, len(files), test.wantFiles)
+		}
+		lines := 0
+		for _, f := range files {
+			b, err := os.ReadFile(filepath.Join(dir, f.Name()))
+			if err != nil {
+				t.Fatalf("TestFileExporter(%s): %s", test.desc, err)
+			}
+			lines += bytes.Count(b, []byte("\n"))
+		}
+		if lines != test.spans {
+			t.Errorf("TestFileExporter(%s): got %d spans written, want %d", test.desc, lines, test.spans)
+		}
+	}
+}
+
+// countingCloser is an io.Closer that counts the calls to Close.
+type countingCloser struct {
+	closed int
+}
+
+func (c *countingCloser) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestFileExporterShutdown(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		wantErr bool
+	}{
+		{desc: "Shut down", ctx: context.Background()},
+		{desc: "Context done", ctx: cancelled, wantErr: true},
+	}
+
+	for _, test := range tests {
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(io.Discard))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := &countingCloser{}
+		err = fileExporter{Exporter: exp, file: file}.Shutdown(test.ctx)
+		if (err != nil) != test.wantErr {
+			t.Errorf("TestFileExporterShutdown(%s): got err == %v, want error %t", test.desc, err, test.wantErr)
+		}
+		if file.closed != 1 {
+			t.Errorf("TestFileExporterShutdown(%s): got the file closed %d times, want 1", test.desc, file.closed)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/extra/redisotel/v8"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// globalLimiter is the -global-qps token bucket the clients share through Redis. It is nil unless
+// -redis-addr is set.
+var globalLimiter *redisTokenBucket
+
+// newRedisClient returns a client of the Redis server at addr whose commands are spans, like the client's
+// HTTP requests, so the time spent coordinating with other clients shows in traces.
+func newRedisClient(addr string) *redis.Client {
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	rdb.AddHook(redisotel.NewTracingHook())
+	return rdb
+}
+
+// takeScript refills the token bucket in KEYS[1] at ARGV[1] tokens a second, up to ARGV[2], and takes a
+// token if there is one. It returns 0 if it took one, or else the milliseconds until there will be one.
+// Running as a script makes this atomic across the clients, and it uses the Redis server's clock, so the
+// clients' clocks needn't agree. Calling TIME before writing needs Redis 5 or later. The bucket expires
+// once it would be full anyway.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// redisTokenBucket is a token bucket kept in Redis, so clients on different hosts share a rate limit.
+type redisTokenBucket struct {
+	rdb   redis.Scripter
+	key   string
+	rate  float64
+	burst int
+}
+
+func newRedisTokenBucket(rdb redis.Scripter, key string, rate float64, burst int) *redisTokenBucket {
+	return &redisTokenBucket{rdb: rdb, key: key, rate: rate, burst: burst}
+}
+
+// tryTake takes a token if there is one, and returns 0, or else returns how long until there will be one.
+func (b *redisTokenBucket) tryTake(ctx context.Context) (time.Duration, error) {
+	ms, err := takeScript.Run(ctx, b.rdb, []string{b.key}, b.rate, b.burst).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// take blocks until it takes a token, and returns nil, or until ctx is done, and returns its error. While
+// Redis can't be reached it keeps trying every second, so the clients send nothing rather than more
+// than -global-qps together.
+func (b *redisTokenBucket) take(ctx context.Context) error {
+	for {
+		wait, err := b.tryTake(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			logger.Warn("failed to take a token from the global rate limit", zap.String("key", b.key), zap.Error(err))
+			wait = time.Second
+		case wait == 0:
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
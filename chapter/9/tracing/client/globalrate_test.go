@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer rdb.Close()
+
+	// Two clients share the bucket, which starts full.
+	a := newRedisTokenBucket(rdb, "demo-client:tokens", 10, 2)
+	b := newRedisTokenBucket(rdb, "demo-client:tokens", 10, 2)
+	other := newRedisTokenBucket(rdb, "other:tokens", 10, 2)
+
+	for i, bucket := range []*redisTokenBucket{a, b} {
+		wait, err := bucket.tryTake(ctx)
+		if err != nil {
+			t.Fatalf("TestRedisTokenBucket(take %d): got err == %s, want err == nil", i, err)
+		}
+		if wait != 0 {
+			t.Errorf("TestRedisTokenBucket(take %d): got wait %s, want 0", i, wait)
+		}
+	}
+
+	wait, err := a.tryTake(ctx)
+	if err != nil {
+		t.Fatalf("TestRedisTokenBucket(empty): got err == %s, want err == nil", err)
+	}
+	// A token is added every 100ms.
+	if wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("TestRedisTokenBucket(empty): got wait %s, want (0, 100ms]", wait)
+	}
+
+	if wait, err := other.tryTake(ctx); err != nil || wait != 0 {
+		t.Errorf("TestRedisTokenBucket(other key): got wait %s, err %v, want 0, nil", wait, err)
+	}
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/deadline"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/hello"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/spanlink"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// greeter is the client of the demo server's Greeter gRPC service. It is nil unless requests are gRPC
+// calls, see useGRPC.
+var greeter hello.GreeterClient
+
+// useGRPC returns whether requests are gRPC calls to -grpc-endpoint: by -protocol, or if it isn't set,
+// whether -grpc-endpoint is.
+func useGRPC() bool {
+	if *protocol == "" {
+		return *grpcEndpoint != ""
+	}
+	return strings.ToLower(*protocol) == "grpc"
+}
+
+// dialGreeter connects to the Greeter service at addr. A grpcStatsHandler creates a span for each call
+// and propagates the trace context in its metadata, like otelhttp does in HTTP headers.
+func dialGreeter(addr string) (*grpc.ClientConn, error) {
+	return grpc.Dial(
+		addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(grpcStatsHandler{}),
+	)
+}
+
+// callWithRetries calls the Greeter, and calls it again by the -retry-* flags like sendWithRetries sends
+// HTTP requests. header, the rendered -request-headers, is sent as the calls' metadata.
+func callWithRetries(ctx context.Context, log *zap.Logger, instruments ClientInstruments, r request, header http.Header) error {
+	md := metadata.MD{}
+	for name, values := range header {
+		md.Append(name, values...)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	// Like HTTP requests, each attempt has its own span when calls are retried, with its number in
+	// the rpc.retry_count attribute, linked to the attempt before it.
+	attempts := spanlink.NewChain(spanlink.Retry)
+	for attempt := 0; ; attempt++ {
+		attemptCtx := ctx
+		var span trace.Span
+		if retries.maxAttempts > 1 {
+			attemptCtx, span = attempts.Start(
+				ctx,
+				otel.Tracer("demo-client-tracer"),
+				"Attempt",
+				trace.WithAttributes(attribute.Int("rpc.retry_count", attempt)),
+			)
+		}
+		err := callGreeter(attemptCtx, log, instruments)
+		if span != nil {
+			if err != nil {
+				span.SetStatus(codes.Error, fmt.Sprintf("attempt %d failed", attempt))
+			}
+			span.End()
+		}
+		if err == nil || attempt+1 >= retries.maxAttempts || !retries.shouldRetryCall(err) {
+			return err
+		}
+		wait := retries.backoff(attempt)
+		log.Debug(
+			"retrying call",
+			zap.String("endpoint", r.url),
+			zap.Int("attempt", attempt+1),
+			zap.String("code", status.Code(err).String()),
+			zap.Duration("retry_in", wait),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// callGreeter calls the Greeter once, SayHello or with -grpc-stream-messages a SayHelloStream, and
+// records the call's metrics.
+func callGreeter(ctx context.Context, log *zap.Logger, instruments ClientInstruments) error {
+	// gRPC propagates the deadline in the grpc-timeout header.
+	trace.SpanFromContext(ctx).SetAttributes(deadline.Remaining(ctx)...)
+
+	start := time.Now()
+	var greetings []string
+	var err error
+	if *grpcStreamMessages > 0 {
+		greetings, err = streamGreetings(ctx, *grpcStreamMessages)
+	} else {
+		var res *wrapperspb.StringValue
+		if res, err = greeter.SayHello(ctx, wrapperspb.String(serviceName)); err == nil {
+			greetings = []string{res.GetValue()}
+		}
+	}
+	latency := time.Since(start)
+	instruments.RED.Record(
+		ctx,
+		latency,
+		err != nil,
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.grpc.status_code", status.Code(err).String()),
+	)
+	if err != nil {
+		return err
+	}
+	log.Debug(
+		"call finished",
+		Ctx(ctx),
+		zap.Strings("greetings", greetings),
+		zap.Duration("latency", latency),
+	)
+	return nil
+}
+
+// streamGreetings sends n names on a SayHelloStream and returns the greetings. Names are sent while
+// greetings are read, so both directions of the stream are in use at once.
+func streamGreetings(ctx context.Context, n int) ([]string, error) {
+	stream, err := greeter.SayHelloStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sent := make(chan error, 1)
+	go func() {
+		for i := 1; i <= n; i++ {
+			if err := stream.Send(wrapperspb.String(fmt.Sprintf("%s-%d", serviceName, i))); err != nil {
+				// The stream failed, and Recv returns why.
+				sent <- nil
+				return
+			}
+		}
+		sent <- stream.CloseSend()
+	}()
+
+	var greetings []string
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return greetings, <-sent
+		}
+		if err != nil {
+			return greetings, err
+		}
+		greetings = append(greetings, res.GetValue())
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// grpcStatsHandler is a stats.Handler that traces the client's gRPC calls. Each call, unary or
+// streaming, is a client span named after its method, like demo.hello.Greeter/SayHello, with an event
+// for each message sent and received, and its trace context is injected in the call's metadata.
+//
+// It records what otelgrpc's interceptors do, from the stats gRPC reports for every kind of call. The
+// stats handlers of later otelgrpc releases need a newer OpenTelemetry than the demo's.
+type grpcStatsHandler struct{}
+
+// grpcCallKey is the context key of a call's grpcCall.
+type grpcCallKey struct{}
+
+// grpcCall counts the messages of a call. The messages of a stream can be reported by several goroutines.
+type grpcCall struct {
+	sent, received int64
+}
+
+// TagRPC implements stats.Handler.TagRPC. It starts the call's span and injects its context.
+func (grpcStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	name, attrs := grpcSpanInfo(info.FullMethodName)
+	ctx, _ = otel.Tracer("demo-client-tracer").Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	// The metadata in ctx may be shared with other calls, so the trace context is added to a copy.
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	return context.WithValue(ctx, grpcCallKey{}, &grpcCall{})
+}
+
+// HandleRPC implements stats.Handler.HandleRPC. It records messages as events, and ends the span with
+// the call's status.
+func (grpcStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	call, ok := ctx.Value(grpcCallKey{}).(*grpcCall)
+	if !ok {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	switch s := s.(type) {
+	case *stats.OutPayload:
+		span.AddEvent("message", trace.WithAttributes(
+			attribute.String("message.type", "SENT"),
+			attribute.Int64("message.id", atomic.AddInt64(&call.sent, 1)),
+			attribute.Int("message.uncompressed_size", s.Length),
+		))
+	case *stats.InPayload:
+		span.AddEvent("message", trace.WithAttributes(
+			attribute.String("message.type", "RECEIVED"),
+			attribute.Int64("message.id", atomic.AddInt64(&call.received, 1)),
+			attribute.Int("message.uncompressed_size", s.Length),
+		))
+	case *stats.End:
+		st := status.Convert(s.Error)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(st.Code())))
+		if s.Error != nil {
+			span.SetStatus(codes.Error, st.Message())
+		}
+		span.End(trace.WithTimestamp(s.EndTime))
+	}
+}
+
+// TagConn implements stats.Handler.TagConn.
+func (grpcStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.HandleConn.
+func (grpcStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// grpcSpanInfo returns the span name and attributes of a call to fullMethod, like
+// "/demo.hello.Greeter/SayHello".
+func grpcSpanInfo(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	attrs := []attribute.KeyValue{semconv.RPCSystemKey.String("grpc")}
+	service, method, ok := strings.Cut(name, "/")
+	if !ok {
+		return name, attrs
+	}
+	if service != "" {
+		attrs = append(attrs, semconv.RPCServiceKey.String(service))
+	}
+	if method != "" {
+		attrs = append(attrs, semconv.RPCMethodKey.String(method))
+	}
+	return name, attrs
+}
+
+// metadataCarrier is a propagation.TextMapCarrier of gRPC metadata.
+type metadataCarrier metadata.MD
+
+// Get implements propagation.TextMapCarrier.Get.
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set implements propagation.TextMapCarrier.Set.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys implements propagation.TextMapCarrier.Keys.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
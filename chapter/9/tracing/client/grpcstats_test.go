@@ -0,0 +1,47 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestGRPCSpanInfo(t *testing.T) {
+	tests := []struct {
+		desc       string
+		fullMethod string
+		wantName   string
+		wantAttrs  map[string]string
+	}{
+		{
+			desc:       "Method",
+			fullMethod: "/demo.hello.Greeter/SayHelloStream",
+			wantName:   "demo.hello.Greeter/SayHelloStream",
+			wantAttrs:  map[string]string{"rpc.system": "grpc", "rpc.service": "demo.hello.Greeter", "rpc.method": "SayHelloStream"},
+		},
+		{
+			desc:       "No service",
+			fullMethod: "/SayHello",
+			wantName:   "SayHello",
+			wantAttrs:  map[string]string{"rpc.system": "grpc"},
+		},
+	}
+
+	for _, test := range tests {
+		name, attrs := grpcSpanInfo(test.fullMethod)
+		if name != test.wantName {
+			t.Errorf("TestGRPCSpanInfo(%s): got name %q, want %q", test.desc, name, test.wantName)
+		}
+		got := map[string]string{}
+		for _, kv := range attrs {
+			got[string(kv.Key)] = kv.Value.AsString()
+		}
+		if len(got) != len(test.wantAttrs) {
+			t.Errorf("TestGRPCSpanInfo(%s): got attributes %v, want %v", test.desc, got, test.wantAttrs)
+			continue
+		}
+		for k, v := range test.wantAttrs {
+			if got[k] != v {
+				t.Errorf("TestGRPCSpanInfo(%s): got %s == %q, want %q", test.desc, k, got[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// headerTemplate is a header added to requests. Its value is a text/template, like the request body.
+type headerTemplate struct {
+	name  string
+	value *template.Template
+}
+
+// requestHeaders are the -request-headers added to every request.
+var requestHeaders []headerTemplate
+
+// spanHeaders are the canonical names of the -span-headers recorded on request spans.
+var spanHeaders []string
+
+// initRequestHeaders parses -request-headers, which are read from a file if they start with '@', and
+// -span-headers.
+func initRequestHeaders() error {
+	text := *requestHeadersSpec
+	if text != "" && text[0] == '@' {
+		b, err := os.ReadFile(text[1:])
+		if err != nil {
+			return fmt.Errorf("-request-headers: %w", err)
+		}
+		text = string(b)
+	}
+	hs, err := parseHeaders(text)
+	if err != nil {
+		return fmt.Errorf("-request-headers: %w", err)
+	}
+	requestHeaders = hs
+
+	for _, name := range strings.Split(*spanHeadersSpec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			spanHeaders = append(spanHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+	return nil
+}
+
+// parseHeaders parses headers like 'X-Tenant=acme,X-Correlation-Id={{uuid}}', which can also be separated
+// by newlines. Values are URL encoded, like -otlp-headers, and are templates that can use bodyFuncs.
+func parseHeaders(s string) ([]headerTemplate, error) {
+	var hs []headerTemplate
+	for _, pair := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("header %q is not in name=value format", name)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("header %q has an invalid URL encoded value", name)
+		}
+		t, err := template.New(name).Funcs(bodyFuncs).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %q: %w", name, err)
+		}
+		hs = append(hs, headerTemplate{name: http.CanonicalHeaderKey(name), value: t})
+	}
+	return hs, nil
+}
+
+// renderHeaders returns the headers rendered from hs. A header in hs more than once has each value.
+func renderHeaders(hs []headerTemplate) (http.Header, error) {
+	h := make(http.Header, len(hs))
+	for _, ht := range hs {
+		var buf bytes.Buffer
+		if err := ht.value.Execute(&buf, nil); err != nil {
+			return nil, fmt.Errorf("failed to render request header %s: %w", ht.name, err)
+		}
+		h.Add(ht.name, buf.String())
+	}
+	return h, nil
+}
+
+// headerAttributes returns the headers of h in names as attributes named like the semantic conventions'
+// http.request.header.<name>, where the name is lower case with '-' replaced by '_'.
+func headerAttributes(h http.Header, names []string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, name := range names {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		key := "http.request.header." + strings.ReplaceAll(strings.ToLower(name), "-", "_")
+		attrs = append(attrs, attribute.StringSlice(key, values))
+	}
+	return attrs
+}
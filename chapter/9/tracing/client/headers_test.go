@@ -0,0 +1,100 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    http.Header
+		wantErr bool
+	}{
+		{
+			desc: "Empty",
+			s:    "",
+			want: http.Header{},
+		},
+		{
+			desc: "Comma and newline separated with spaces",
+			s:    "x-tenant=acme, X-Api-Version = 2\nx-team=demo,",
+			want: http.Header{
+				"X-Tenant":      {"acme"},
+				"X-Api-Version": {"2"},
+				"X-Team":        {"demo"},
+			},
+		},
+		{
+			desc: "Values are decoded and may repeat",
+			s:    "Accept=text/html%2C application/json,accept=*/*",
+			want: http.Header{"Accept": {"text/html, application/json", "*/*"}},
+		},
+		{
+			desc: "Templates are rendered",
+			s:    `X-Static={{print "a" "-b"}}`,
+			want: http.Header{"X-Static": {"a-b"}},
+		},
+		{
+			desc:    "Missing value",
+			s:       "x-tenant",
+			wantErr: true,
+		},
+		{
+			desc:    "Name with a colon",
+			s:       "x-tenant: acme=1",
+			wantErr: true,
+		},
+		{
+			desc:    "Bad template",
+			s:       "x-id={{uuid",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		hs, err := parseHeaders(test.s)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseHeaders(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseHeaders(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		got, err := renderHeaders(hs)
+		if err != nil {
+			t.Errorf("TestParseHeaders(%s): renderHeaders: got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestParseHeaders(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestHeaderAttributes(t *testing.T) {
+	h := http.Header{
+		"X-Tenant-Id": {"acme"},
+		"Accept":      {"text/html", "*/*"},
+	}
+	attrs := headerAttributes(h, []string{"X-Tenant-Id", "Accept", "X-Missing"})
+
+	got := map[string][]string{}
+	for _, a := range attrs {
+		got[string(a.Key)] = a.Value.AsStringSlice()
+	}
+	want := map[string][]string{
+		"http.request.header.x_tenant_id": {"acme"},
+		"http.request.header.accept":      {"text/html", "*/*"},
+	}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestHeaderAttributes: -want/+got:\n%s", diff)
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clientHealth is the health the admin server's /healthz and /livez report.
+var clientHealth = newHealthState(time.Now())
+
+// healthState tracks the results of span exports and requests, to report whether the client is working.
+type healthState struct {
+	mu          sync.Mutex
+	start       time.Time
+	lastExport  time.Time
+	exportErr   error
+	lastSuccess time.Time
+	lastRequest time.Time
+	// dispatchSince is when the load loop started waiting to hand a request to a worker, or zero if it isn't.
+	dispatchSince time.Time
+	// standby is true while another replica is the -leader-elect leader, and this one sends no requests.
+	standby bool
+}
+
+// newHealthState returns a healthState for a client started at start.
+func newHealthState(start time.Time) *healthState {
+	return &healthState{start: start}
+}
+
+// recordExport records the result of exporting spans.
+func (h *healthState) recordExport(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastExport = time.Now()
+	h.exportErr = err
+}
+
+// recordRequest records a request that finished, and whether it failed.
+func (h *healthState) recordRequest(failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastRequest = time.Now()
+	if !failed {
+		h.lastSuccess = h.lastRequest
+	}
+}
+
+// recordDispatch records that the load loop started waiting at now to hand a request to a worker. The
+// returned func records that it stopped.
+func (h *healthState) recordDispatch(now time.Time) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dispatchSince = now
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.dispatchSince = time.Time{}
+	}
+}
+
+// recordLeadership records whether the client is the -leader-elect leader at now. A client standing by
+// sends no requests, so their age isn't checked until it leads, and then from when it started leading.
+func (h *healthState) recordLeadership(leading bool, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.standby = !leading
+	if leading {
+		h.start = now
+	}
+}
+
+// healthReport is the JSON body of /healthz.
+type healthReport struct {
+	Status                string     `json:"status"`
+	LastExport            *time.Time `json:"last_export,omitempty"`
+	ExportError           string     `json:"export_error,omitempty"`
+	LastSuccessfulRequest *time.Time `json:"last_successful_request,omitempty"`
+	Standby               bool       `json:"standby,omitempty"`
+	Problems              []string   `json:"problems,omitempty"`
+}
+
+// report returns the health at now. The client is healthy if its last span export succeeded and, unless
+// maxAge is 0 or the client is standing by, a request succeeded in the last maxAge or the client started,
+// or started leading, less than maxAge ago.
+func (h *healthState) report(now time.Time, maxAge time.Duration) healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := healthReport{Status: "ok"}
+	if !h.lastExport.IsZero() {
+		t := h.lastExport
+		r.LastExport = &t
+	}
+	if h.exportErr != nil {
+		r.ExportError = h.exportErr.Error()
+		r.Problems = append(r.Problems, "the last span export failed")
+	}
+	if !h.lastSuccess.IsZero() {
+		t := h.lastSuccess
+		r.LastSuccessfulRequest = &t
+	}
+	r.Standby = h.standby
+	if maxAge > 0 && !h.standby {
+		since := h.lastSuccess
+		if since.Before(h.start) {
+			since = h.start
+		}
+		if now.Sub(since) > maxAge {
+			r.Problems = append(r.Problems, fmt.Sprintf("no request succeeded in the last %s", maxAge))
+		}
+	}
+	if len(r.Problems) > 0 {
+		r.Status = "unhealthy"
+	}
+	return r
+}
+
+// livenessReport is the JSON body of /livez.
+type livenessReport struct {
+	Status      string     `json:"status"`
+	LastRequest *time.Time `json:"last_request,omitempty"`
+	LastExport  *time.Time `json:"last_export,omitempty"`
+	ExportError string     `json:"export_error,omitempty"`
+	Standby     bool       `json:"standby,omitempty"`
+	Problems    []string   `json:"problems,omitempty"`
+}
+
+// liveness returns whether the load loop is live at now. Unless maxWait is 0, it isn't if it has waited
+// longer than maxWait to hand a request to a worker, because every worker is stuck. Failed requests and
+// exports are reported but don't make the client not live, as restarting it wouldn't fix the server or
+// the collector.
+func (h *healthState) liveness(now time.Time, maxWait time.Duration) livenessReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := livenessReport{Status: "ok", Standby: h.standby}
+	if !h.lastRequest.IsZero() {
+		t := h.lastRequest
+		r.LastRequest = &t
+	}
+	if !h.lastExport.IsZero() {
+		t := h.lastExport
+		r.LastExport = &t
+	}
+	if h.exportErr != nil {
+		r.ExportError = h.exportErr.Error()
+	}
+	if maxWait > 0 && !h.dispatchSince.IsZero() && now.Sub(h.dispatchSince) > maxWait {
+		r.Problems = append(r.Problems, fmt.Sprintf("the load loop has waited %s for a worker", now.Sub(h.dispatchSince).Round(time.Second)))
+		r.Status = "stuck"
+	}
+	return r
+}
+
+// livenessHandler serves /livez. It reports the liveness as JSON, with a 503 status if the client isn't
+// live so it can be used as a Kubernetes liveness probe.
+func livenessHandler(h *healthState) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.liveness(time.Now(), *livenessMaxDispatchWait)
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// ServeHTTP implements http.Handler. It reports the health as JSON, with a 503 status if the client
+// is unhealthy so it can be used as a Kubernetes probe.
+func (h *healthState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.report(time.Now(), *healthMaxRequestAge)
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
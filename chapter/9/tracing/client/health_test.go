@@ -0,0 +1,175 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthReport(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc        string
+		exportErr   error
+		lastSuccess time.Time
+		leadingAt   time.Time
+		standby     bool
+		now         time.Time
+		maxAge      time.Duration
+		wantStatus  string
+		wantProblem int
+	}{
+		{
+			desc:       "Just started",
+			now:        start.Add(time.Second),
+			maxAge:     time.Minute,
+			wantStatus: "ok",
+		},
+		{
+			desc:        "No request succeeded since start",
+			now:         start.Add(2 * time.Minute),
+			maxAge:      time.Minute,
+			wantStatus:  "unhealthy",
+			wantProblem: 1,
+		},
+		{
+			desc:        "Recent success",
+			lastSuccess: start.Add(90 * time.Second),
+			now:         start.Add(2 * time.Minute),
+			maxAge:      time.Minute,
+			wantStatus:  "ok",
+		},
+		{
+			desc:       "Request age isn't checked",
+			now:        start.Add(time.Hour),
+			wantStatus: "ok",
+		},
+		{
+			desc:        "Export failed and no recent success",
+			exportErr:   errors.New("connection refused"),
+			now:         start.Add(time.Hour),
+			maxAge:      time.Minute,
+			wantStatus:  "unhealthy",
+			wantProblem: 2,
+		},
+		{
+			desc:       "Standing by",
+			standby:    true,
+			now:        start.Add(time.Hour),
+			maxAge:     time.Minute,
+			wantStatus: "ok",
+		},
+		{
+			desc:        "Leading without requests",
+			lastSuccess: start.Add(time.Second),
+			leadingAt:   start.Add(time.Hour),
+			now:         start.Add(time.Hour + 2*time.Minute),
+			maxAge:      time.Minute,
+			wantStatus:  "unhealthy",
+			wantProblem: 1,
+		},
+		{
+			desc:       "Just started leading",
+			leadingAt:  start.Add(time.Hour),
+			now:        start.Add(time.Hour + time.Second),
+			maxAge:     time.Minute,
+			wantStatus: "ok",
+		},
+	}
+
+	for _, test := range tests {
+		h := newHealthState(start)
+		h.exportErr = test.exportErr
+		h.lastSuccess = test.lastSuccess
+		if test.standby {
+			h.recordLeadership(false, start)
+		}
+		if !test.leadingAt.IsZero() {
+			h.recordLeadership(true, test.leadingAt)
+		}
+
+		got := h.report(test.now, test.maxAge)
+		if got.Status != test.wantStatus {
+			t.Errorf("TestHealthReport(%s): got status %q, want %q", test.desc, got.Status, test.wantStatus)
+		}
+		if len(got.Problems) != test.wantProblem {
+			t.Errorf("TestHealthReport(%s): got problems %v, want %d", test.desc, got.Problems, test.wantProblem)
+		}
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc       string
+		exportErr  error
+		dispatch   time.Time
+		done       bool
+		now        time.Time
+		maxWait    time.Duration
+		wantStatus string
+	}{
+		{
+			desc:       "Not dispatching",
+			now:        start.Add(time.Hour),
+			maxWait:    time.Minute,
+			wantStatus: "ok",
+		},
+		{
+			desc:       "Waiting for a worker",
+			dispatch:   start.Add(30 * time.Second),
+			now:        start.Add(time.Minute),
+			maxWait:    time.Minute,
+			wantStatus: "ok",
+		},
+		{
+			desc:       "Every worker stuck",
+			dispatch:   start.Add(30 * time.Second),
+			now:        start.Add(2 * time.Minute),
+			maxWait:    time.Minute,
+			wantStatus: "stuck",
+		},
+		{
+			desc:       "Worker freed",
+			dispatch:   start.Add(30 * time.Second),
+			done:       true,
+			now:        start.Add(2 * time.Minute),
+			maxWait:    time.Minute,
+			wantStatus: "ok",
+		},
+		{
+			desc:       "Not checked",
+			dispatch:   start.Add(30 * time.Second),
+			now:        start.Add(time.Hour),
+			wantStatus: "ok",
+		},
+		{
+			desc:       "Export failed",
+			exportErr:  errors.New("connection refused"),
+			now:        start.Add(time.Minute),
+			maxWait:    time.Minute,
+			wantStatus: "ok",
+		},
+	}
+
+	for _, test := range tests {
+		h := newHealthState(start)
+		h.exportErr = test.exportErr
+		if !test.dispatch.IsZero() {
+			done := h.recordDispatch(test.dispatch)
+			if test.done {
+				done()
+			}
+		}
+
+		got := h.liveness(test.now, test.maxWait)
+		if got.Status != test.wantStatus {
+			t.Errorf("TestLiveness(%s): got status %q, want %q", test.desc, got.Status, test.wantStatus)
+		}
+		if (test.exportErr != nil) != (got.ExportError != "") {
+			t.Errorf("TestLiveness(%s): got export error %q, want %v", test.desc, got.ExportError, test.exportErr)
+		}
+	}
+}
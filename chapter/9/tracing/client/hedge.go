@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/spanlink"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hedgeResult is the result of one of the requests sendHedged sends.
+type hedgeResult struct {
+	hedge   int
+	span    trace.Span
+	res     *http.Response
+	latency time.Duration
+	err     error
+}
+
+// sendHedged sends r with header and body like sendAttempt and, with -hedge-delay, sends it again if there
+// is no response after the delay. The first response is returned and the other request is cancelled,
+// which cuts the latency of the requests that would otherwise be the slowest.
+//
+// Both requests have a "Hedge" span, child of the span in ctx, with the demo.hedge attribute 0 for the
+// first request and 1 for the hedge, which links to the first with the spanlink.Hedge relation.
+// demo.hedge.won says which one's response was used.
+func sendHedged(ctx context.Context, instruments ClientInstruments, r request, header http.Header, body []byte) (*http.Response, time.Duration, error) {
+	if *hedgeDelay <= 0 {
+		return sendAttempt(ctx, instruments, r, header, body)
+	}
+
+	tracer := otel.Tracer("demo-client-tracer")
+	hedges := spanlink.NewChain(spanlink.Hedge)
+	// The channel holds both results, so the request that isn't used never blocks.
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	start := func(hedge int) {
+		hctx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		hctx, span := hedges.Start(hctx, tracer, "Hedge", trace.WithAttributes(attribute.Int("demo.hedge", hedge)))
+		go func() {
+			res, latency, err := sendAttempt(hctx, instruments, r, header, body)
+			results <- hedgeResult{hedge: hedge, span: span, res: res, latency: latency, err: err}
+		}()
+	}
+	start(0)
+
+	timer := time.NewTimer(*hedgeDelay)
+	defer timer.Stop()
+	pending := 1
+	var won hedgeResult
+	for won.span == nil {
+		select {
+		case <-timer.C:
+			start(1)
+			pending++
+		case res := <-results:
+			pending--
+			// A failed request only wins if there's no other to wait for.
+			if res.err != nil && pending > 0 {
+				endHedge(res, false)
+				continue
+			}
+			won = res
+		}
+	}
+	endHedge(won, true)
+
+	// The other request is cancelled, and its span ended when it returns. The request that's used is
+	// cancelled when its body is closed, as reading the body needs its context.
+	for hedge, cancel := range cancels {
+		if hedge != won.hedge {
+			cancel()
+		}
+	}
+	if won.err == nil {
+		won.res.Body = cancelOnClose{ReadCloser: won.res.Body, cancel: cancels[won.hedge]}
+	} else {
+		cancels[won.hedge]()
+	}
+	go func() {
+		for ; pending > 0; pending-- {
+			lost := <-results
+			if lost.err == nil {
+				lost.res.Body.Close()
+			}
+			endHedge(lost, false)
+		}
+	}()
+	return won.res, won.latency, won.err
+}
+
+// cancelOnClose is a response body that cancels the request's context when closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer.Close.
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// endHedge ends the span of a request sendHedged sent, recording whether its response was used.
+func endHedge(h hedgeResult, won bool) {
+	h.span.SetAttributes(attribute.Bool("demo.hedge.won", won))
+	switch {
+	case errors.Is(h.err, context.Canceled):
+		h.span.SetStatus(codes.Error, "cancelled")
+	case h.err != nil:
+		h.span.SetStatus(codes.Error, h.err.Error())
+	}
+	h.span.End()
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestSendHedged(t *testing.T) {
+	instruments, err := NewClientInstruments(metric.NewNoopMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc string
+		// firstDelay is how long the server takes to answer the first request. Hedges are answered at once.
+		firstDelay time.Duration
+		hedgeDelay time.Duration
+		// wantRequests is the number of requests the server gets, and wantCancelled whether the first
+		// one is cancelled before it is answered.
+		wantRequests  int32
+		wantCancelled bool
+	}{
+		{desc: "First request answered before the hedge delay", hedgeDelay: time.Minute, wantRequests: 1},
+		{desc: "Hedge answered first", firstDelay: time.Minute, hedgeDelay: 10 * time.Millisecond, wantRequests: 2, wantCancelled: true},
+	}
+
+	old := *hedgeDelay
+	defer func() { *hedgeDelay = old }()
+
+	for _, test := range tests {
+		var requests int32
+		cancelled := make(chan bool, 1)
+		// stop ends a first request that is still waiting when the test case is over.
+		stop := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) > 1 {
+				return
+			}
+			select {
+			case <-time.After(test.firstDelay):
+				cancelled <- false
+			case <-r.Context().Done():
+				cancelled <- true
+			case <-stop:
+			}
+		}))
+
+		*hedgeDelay = test.hedgeDelay
+		res, _, err := sendHedged(context.Background(), instruments, request{method: http.MethodGet, url: srv.URL}, nil, nil)
+		if err != nil {
+			t.Errorf("TestSendHedged(%s): got err == %s, want err == nil", test.desc, err)
+			close(stop)
+			srv.Close()
+			continue
+		}
+		res.Body.Close()
+
+		select {
+		case got := <-cancelled:
+			if got != test.wantCancelled {
+				t.Errorf("TestSendHedged(%s): got first request cancelled == %v, want %v", test.desc, got, test.wantCancelled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("TestSendHedged(%s): the first request was neither answered nor cancelled", test.desc)
+		}
+		close(stop)
+		srv.Close()
+		if got := atomic.LoadInt32(&requests); got != test.wantRequests {
+			t.Errorf("TestSendHedged(%s): got %d requests, want %d", test.desc, got, test.wantRequests)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package sampler
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// errorRateAlpha is the weight a single outcome has in the moving average of the error rate.
+	errorRateAlpha = 0.1
+	// errorRateForMax is the error rate at which Adaptive samples at its max ratio.
+	errorRateForMax = 0.1
+)
+
+// Adaptive is a sdktrace.Sampler whose sampling ratio follows the error rate of the requests it is
+// told about with Record(). When errors rise, the ratio moves up towards max so there are more traces
+// to debug with. As requests become healthy again, the ratio decays back to min.
+//
+// The ratio and error rate used for each decision are recorded as attributes on sampled spans.
+type Adaptive struct {
+	min, max float64
+
+	mu        sync.Mutex
+	errorRate float64 // exponentially weighted moving average
+	ratio     float64
+}
+
+// NewAdaptive creates an Adaptive sampler that samples between min and max of traces.
+func NewAdaptive(min, max float64) (*Adaptive, error) {
+	if min < 0 || max > 1 || min > max {
+		return nil, fmt.Errorf("must have 0 <= min(%v) <= max(%v) <= 1", min, max)
+	}
+	return &Adaptive{min: min, max: max, ratio: min}, nil
+}
+
+// Record reports the outcome of a request, which adjusts the sampling ratio.
+func (a *Adaptive) Record(failed bool) {
+	v := 0.0
+	if failed {
+		v = 1
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.errorRate = a.errorRate*(1-errorRateAlpha) + v*errorRateAlpha
+	a.ratio = a.min + (a.max-a.min)*math.Min(1, a.errorRate/errorRateForMax)
+}
+
+// Ratio returns the current sampling ratio and the error rate it is based on.
+func (a *Adaptive) Ratio() (ratio, errorRate float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ratio, a.errorRate
+}
+
+// ShouldSample implements sdktrace.Sampler.ShouldSample.
+func (a *Adaptive) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	ratio, errorRate := a.Ratio()
+
+	result := sdktrace.TraceIDRatioBased(ratio).ShouldSample(p)
+	result.Attributes = append(
+		result.Attributes,
+		attribute.Float64("sampler.adaptive.ratio", ratio),
+		attribute.Float64("sampler.adaptive.error_rate", errorRate),
+	)
+	return result
+}
+
+// Description implements sdktrace.Sampler.Description.
+func (a *Adaptive) Description() string {
+	return fmt.Sprintf("Adaptive{min:%v,max:%v}", a.min, a.max)
+}
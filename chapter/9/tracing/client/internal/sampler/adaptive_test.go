@@ -0,0 +1,92 @@
+package sampler
+
+import (
+	"math"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewAdaptive(t *testing.T) {
+	tests := []struct {
+		desc     string
+		min, max float64
+		wantErr  bool
+	}{
+		{desc: "Valid", min: 0.01, max: 0.5},
+		{desc: "Fixed ratio", min: 0.1, max: 0.1},
+		{desc: "Negative min", min: -0.1, max: 0.5, wantErr: true},
+		{desc: "Max above 1", min: 0.1, max: 1.5, wantErr: true},
+		{desc: "Min above max", min: 0.5, max: 0.1, wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewAdaptive(test.min, test.max)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNewAdaptive(%s): got err == nil, want err != nil", test.desc)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNewAdaptive(%s): got err == %s, want err == nil", test.desc, err)
+		}
+	}
+}
+
+func TestAdaptiveRecord(t *testing.T) {
+	a, err := NewAdaptive(0, 1)
+	if err != nil {
+		t.Fatalf("TestAdaptiveRecord: got err == %s, want err == nil", err)
+	}
+	if ratio, _ := a.Ratio(); ratio != 0 {
+		t.Errorf("TestAdaptiveRecord: got initial ratio %v, want 0", ratio)
+	}
+
+	// A single failure takes the moving average to errorRateForMax, so the ratio goes to max.
+	a.Record(true)
+	if ratio, errorRate := a.Ratio(); ratio != 1 || !near(errorRate, 0.1) {
+		t.Errorf("TestAdaptiveRecord(after a failure): got ratio %v, error rate %v, want 1, 0.1", ratio, errorRate)
+	}
+
+	// Then each success decays the error rate, and the ratio with it, by errorRateAlpha.
+	a.Record(false)
+	if ratio, errorRate := a.Ratio(); !near(ratio, 0.9) || !near(errorRate, 0.09) {
+		t.Errorf("TestAdaptiveRecord(after a success): got ratio %v, error rate %v, want 0.9, 0.09", ratio, errorRate)
+	}
+	for i := 0; i < 200; i++ {
+		a.Record(false)
+	}
+	if ratio, _ := a.Ratio(); ratio > 0.001 {
+		t.Errorf("TestAdaptiveRecord(after many successes): got ratio %v, want about 0", ratio)
+	}
+}
+
+func TestAdaptiveShouldSample(t *testing.T) {
+	a, err := NewAdaptive(0, 1)
+	if err != nil {
+		t.Fatalf("TestAdaptiveShouldSample: got err == %s, want err == nil", err)
+	}
+	p := sdktrace.SamplingParameters{TraceID: trace.TraceID{1}, Name: "Request"}
+
+	if got := a.ShouldSample(p).Decision; got != sdktrace.Drop {
+		t.Errorf("TestAdaptiveShouldSample(healthy): got %v, want Drop", got)
+	}
+
+	a.Record(true)
+	result := a.ShouldSample(p)
+	if result.Decision != sdktrace.RecordAndSample {
+		t.Errorf("TestAdaptiveShouldSample(failing): got %v, want RecordAndSample", result.Decision)
+	}
+	attrs := map[attribute.Key]float64{}
+	for _, kv := range result.Attributes {
+		attrs[kv.Key] = kv.Value.AsFloat64()
+	}
+	if attrs["sampler.adaptive.ratio"] != 1 || !near(attrs["sampler.adaptive.error_rate"], 0.1) {
+		t.Errorf("TestAdaptiveShouldSample(failing): got attributes %v, want ratio 1 and error rate 0.1", attrs)
+	}
+}
+
+// near reports whether a and b are equal but for floating point error.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
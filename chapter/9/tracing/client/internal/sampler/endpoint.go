@@ -0,0 +1,110 @@
+package sampler
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"gopkg.in/yaml.v2"
+)
+
+// Rule sets the sampling ratio for requests whose URL path matches Path.
+type Rule struct {
+	// Path is a path.Match pattern, like "/healthz" or "/api/*".
+	Path string `yaml:"path"`
+	// Ratio is the ratio of matching traces to sample, between 0 and 1.
+	Ratio float64 `yaml:"ratio"`
+}
+
+// Rules is the format of a rules file:
+//
+//	rules:
+//	  - path: /healthz
+//	    ratio: 0.01
+//	  - path: /checkout
+//	    ratio: 1.0
+type Rules struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules reads Rules from the YAML file at p.
+func LoadRules(p string) (Rules, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return Rules{}, err
+	}
+	var r Rules
+	if err := yaml.UnmarshalStrict(b, &r); err != nil {
+		return Rules{}, fmt.Errorf("could not parse rules file %s: %w", p, err)
+	}
+	return r, nil
+}
+
+// Endpoint is a sdktrace.Sampler that picks the sampling ratio by the URL path of the request a span
+// is started for. The path is taken from the span's http.target or http.url attribute, which must be
+// set when the span is started. The first matching Rule wins. Spans without a path or that match no
+// Rule are sampled by the fallback Sampler.
+type Endpoint struct {
+	rules    []Rule
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+// NewEndpoint creates an Endpoint sampler from rules.
+func NewEndpoint(rules Rules, fallback sdktrace.Sampler) (*Endpoint, error) {
+	if fallback == nil {
+		return nil, fmt.Errorf("fallback cannot be nil")
+	}
+
+	e := &Endpoint{fallback: fallback}
+	for i, r := range rules.Rules {
+		if _, err := path.Match(r.Path, ""); err != nil {
+			return nil, fmt.Errorf("rule %d: path %q is not a valid pattern: %w", i, r.Path, err)
+		}
+		if r.Ratio < 0 || r.Ratio > 1 {
+			return nil, fmt.Errorf("rule %d: ratio must be between 0 and 1, was %v", i, r.Ratio)
+		}
+		e.rules = append(e.rules, r)
+		e.samplers = append(e.samplers, sdktrace.TraceIDRatioBased(r.Ratio))
+	}
+	return e, nil
+}
+
+// ShouldSample implements sdktrace.Sampler.ShouldSample.
+func (e *Endpoint) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	urlPath := pathFromAttributes(p.Attributes)
+	if urlPath == "" {
+		return e.fallback.ShouldSample(p)
+	}
+
+	for i, r := range e.rules {
+		if ok, _ := path.Match(r.Path, urlPath); ok {
+			result := e.samplers[i].ShouldSample(p)
+			result.Attributes = append(result.Attributes, attribute.String("sampler.rule", r.Path))
+			return result
+		}
+	}
+	return e.fallback.ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler.Description.
+func (e *Endpoint) Description() string {
+	return fmt.Sprintf("Endpoint{rules:%d,fallback:%s}", len(e.rules), e.fallback.Description())
+}
+
+// pathFromAttributes returns the URL path from the http.target or http.url attribute.
+func pathFromAttributes(attrs []attribute.KeyValue) string {
+	for _, kv := range attrs {
+		switch kv.Key {
+		case semconv.HTTPTargetKey, semconv.HTTPURLKey:
+			if u, err := url.Parse(kv.Value.AsString()); err == nil {
+				return u.Path
+			}
+		}
+	}
+	return ""
+}
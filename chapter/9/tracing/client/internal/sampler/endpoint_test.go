@@ -0,0 +1,120 @@
+package sampler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		desc    string
+		file    string
+		want    Rules
+		wantErr bool
+	}{
+		{
+			desc: "Rules",
+			file: "rules:\n  - path: /healthz\n    ratio: 0.01\n  - path: /api/*\n    ratio: 1\n",
+			want: Rules{Rules: []Rule{{Path: "/healthz", Ratio: 0.01}, {Path: "/api/*", Ratio: 1}}},
+		},
+		{
+			desc:    "Unknown field",
+			file:    "rules:\n  - path: /healthz\n    rate: 0.01\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		p := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(p, []byte(test.file), 0o644); err != nil {
+			t.Fatalf("TestLoadRules(%s): could not write the rules file: %s", test.desc, err)
+		}
+		got, err := LoadRules(p)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestLoadRules(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestLoadRules(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestLoadRules(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestNewEndpoint(t *testing.T) {
+	tests := []struct {
+		desc     string
+		rules    []Rule
+		fallback sdktrace.Sampler
+		wantErr  bool
+	}{
+		{desc: "Valid", rules: []Rule{{Path: "/api/*", Ratio: 0.5}}, fallback: sdktrace.AlwaysSample()},
+		{desc: "No fallback", rules: []Rule{{Path: "/api/*", Ratio: 0.5}}, wantErr: true},
+		{desc: "Bad pattern", rules: []Rule{{Path: "/api/[", Ratio: 0.5}}, fallback: sdktrace.AlwaysSample(), wantErr: true},
+		{desc: "Ratio above 1", rules: []Rule{{Path: "/api/*", Ratio: 2}}, fallback: sdktrace.AlwaysSample(), wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := NewEndpoint(Rules{Rules: test.rules}, test.fallback)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNewEndpoint(%s): got err == nil, want err != nil", test.desc)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNewEndpoint(%s): got err == %s, want err == nil", test.desc, err)
+		}
+	}
+}
+
+func TestEndpointShouldSample(t *testing.T) {
+	rules := Rules{Rules: []Rule{
+		{Path: "/healthz", Ratio: 0},
+		{Path: "/api/*", Ratio: 1},
+		// Never reached for /api/ paths, the first matching rule wins.
+		{Path: "/api/users", Ratio: 0},
+	}}
+	e, err := NewEndpoint(rules, sdktrace.NeverSample())
+	if err != nil {
+		t.Fatalf("TestEndpointShouldSample: got err == %s, want err == nil", err)
+	}
+
+	tests := []struct {
+		desc     string
+		attrs    []attribute.KeyValue
+		want     sdktrace.SamplingDecision
+		wantRule string
+	}{
+		{desc: "Dropped path", attrs: []attribute.KeyValue{semconv.HTTPTargetKey.String("/healthz")}, want: sdktrace.Drop, wantRule: "/healthz"},
+		{desc: "First match wins", attrs: []attribute.KeyValue{semconv.HTTPTargetKey.String("/api/users")}, want: sdktrace.RecordAndSample, wantRule: "/api/*"},
+		{desc: "Path of a URL", attrs: []attribute.KeyValue{semconv.HTTPURLKey.String("http://server:7080/api/orders?id=1")}, want: sdktrace.RecordAndSample, wantRule: "/api/*"},
+		{desc: "No matching rule", attrs: []attribute.KeyValue{semconv.HTTPTargetKey.String("/hello")}, want: sdktrace.Drop},
+		{desc: "No path", want: sdktrace.Drop},
+	}
+
+	for _, test := range tests {
+		result := e.ShouldSample(sdktrace.SamplingParameters{TraceID: trace.TraceID{1}, Name: "Request", Attributes: test.attrs})
+		if result.Decision != test.want {
+			t.Errorf("TestEndpointShouldSample(%s): got %v, want %v", test.desc, result.Decision, test.want)
+		}
+		var rule string
+		for _, kv := range result.Attributes {
+			if kv.Key == "sampler.rule" {
+				rule = kv.Value.AsString()
+			}
+		}
+		if rule != test.wantRule {
+			t.Errorf("TestEndpointShouldSample(%s): got sampler.rule %q, want %q", test.desc, rule, test.wantRule)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package sampler
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RateLimited is a sdktrace.Sampler that samples at most a fixed number of traces per second,
+// so that a high QPS load run doesn't overwhelm the collector.
+type RateLimited struct {
+	bucket *tokenBucket
+
+	// sampled and dropped count decisions. They must be accessed atomically.
+	sampled, dropped int64
+}
+
+// NewRateLimited creates a RateLimited that samples up to perSecond traces each second.
+func NewRateLimited(perSecond float64) (*RateLimited, error) {
+	if perSecond <= 0 {
+		return nil, fmt.Errorf("perSecond must be > 0, was %v", perSecond)
+	}
+	return &RateLimited{bucket: newTokenBucket(perSecond)}, nil
+}
+
+// SetLimit changes the maximum number of traces sampled per second to perSecond. Unlike a new
+// RateLimited, which starts with a full bucket, the traces sampled recently still count against it.
+func (r *RateLimited) SetLimit(perSecond float64) error {
+	if perSecond <= 0 {
+		return fmt.Errorf("perSecond must be > 0, was %v", perSecond)
+	}
+	r.bucket.setRate(perSecond)
+	return nil
+}
+
+// ShouldSample implements sdktrace.Sampler.ShouldSample.
+func (r *RateLimited) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	if !r.bucket.take() {
+		atomic.AddInt64(&r.dropped, 1)
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: psc.TraceState()}
+	}
+	atomic.AddInt64(&r.sampled, 1)
+	return sdktrace.SamplingResult{Decision: sdktrace.RecordAndSample, Tracestate: psc.TraceState()}
+}
+
+// Description implements sdktrace.Sampler.Description.
+func (r *RateLimited) Description() string {
+	return fmt.Sprintf("RateLimited{%v/s}", r.Limit())
+}
+
+// Limit returns the maximum number of traces sampled per second.
+func (r *RateLimited) Limit() float64 {
+	return r.bucket.currentRate()
+}
+
+// Sampled returns the number of traces that have been sampled.
+func (r *RateLimited) Sampled() int64 {
+	return atomic.LoadInt64(&r.sampled)
+}
+
+// Dropped returns the number of traces that were dropped because the limit was reached.
+func (r *RateLimited) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
@@ -0,0 +1,59 @@
+package sampler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewRateLimited(t *testing.T) {
+	for _, perSecond := range []float64{0, -1} {
+		if _, err := NewRateLimited(perSecond); err == nil {
+			t.Errorf("TestNewRateLimited(%v): got err == nil, want err != nil", perSecond)
+		}
+	}
+}
+
+func TestRateLimitedShouldSample(t *testing.T) {
+	r, err := NewRateLimited(2)
+	if err != nil {
+		t.Fatalf("TestRateLimitedShouldSample: got err == %s, want err == nil", err)
+	}
+	now := time.Now()
+	r.bucket.now = func() time.Time { return now }
+	r.bucket.last = now
+
+	ts, err := trace.ParseTraceState("vendor=value")
+	if err != nil {
+		t.Fatalf("TestRateLimitedShouldSample: ParseTraceState: %s", err)
+	}
+	parent := trace.ContextWithSpanContext(
+		context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceState: ts}),
+	)
+	params := sdktrace.SamplingParameters{ParentContext: parent, TraceID: trace.TraceID{1}, Name: "span"}
+
+	want := []sdktrace.SamplingDecision{sdktrace.RecordAndSample, sdktrace.RecordAndSample, sdktrace.Drop}
+	for i, w := range want {
+		res := r.ShouldSample(params)
+		if res.Decision != w {
+			t.Errorf("TestRateLimitedShouldSample: decision %d: got %v, want %v", i, res.Decision, w)
+		}
+		if res.Tracestate.String() != ts.String() {
+			t.Errorf("TestRateLimitedShouldSample: decision %d: got tracestate %q, want %q", i, res.Tracestate.String(), ts.String())
+		}
+	}
+
+	// A second later the bucket has refilled.
+	now = now.Add(time.Second)
+	if got := r.ShouldSample(params).Decision; got != sdktrace.RecordAndSample {
+		t.Errorf("TestRateLimitedShouldSample: decision after refill: got %v, want %v", got, sdktrace.RecordAndSample)
+	}
+
+	if r.Sampled() != 3 || r.Dropped() != 1 {
+		t.Errorf("TestRateLimitedShouldSample: got %d sampled and %d dropped, want 3 and 1", r.Sampled(), r.Dropped())
+	}
+}
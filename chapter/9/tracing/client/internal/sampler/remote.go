@@ -0,0 +1,178 @@
+package sampler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Remote is a sdktrace.Sampler that uses the sampling strategy a Jaeger agent or collector serves for
+// a service, so sampling can be managed centrally. The strategy is fetched every refresh interval.
+// If a fetch fails, the error is passed to otel.Handle() and the last strategy stays in use.
+//
+// Probabilistic, rate limiting and per operation strategies are supported. For per operation
+// strategies the operation is the span name.
+type Remote struct {
+	url     string
+	client  *http.Client
+	refresh time.Duration
+
+	mu       sync.RWMutex
+	fallback sdktrace.Sampler
+	perOp    map[string]sdktrace.Sampler
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRemote creates a Remote sampler that fetches the strategy for service from endpoint, which is
+// the agent's sampling URL like http://localhost:5778/sampling. initial is used until a strategy has
+// been fetched. Call Close() to stop refreshing.
+func NewRemote(endpoint, service string, refresh time.Duration, initial sdktrace.Sampler) (*Remote, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint %q is not a valid URL: %w", endpoint, err)
+	}
+	if refresh <= 0 {
+		return nil, fmt.Errorf("refresh must be > 0")
+	}
+	q := u.Query()
+	q.Set("service", service)
+	u.RawQuery = q.Encode()
+
+	r := &Remote{
+		url: u.String(),
+		// This client must not be instrumented, or fetching the strategy would create spans.
+		client:   &http.Client{Timeout: 10 * time.Second},
+		refresh:  refresh,
+		fallback: initial,
+		stop:     make(chan struct{}),
+	}
+	if err := r.update(); err != nil {
+		otel.Handle(fmt.Errorf("could not fetch initial sampling strategy: %w", err))
+	}
+	go r.refreshLoop()
+	return r, nil
+}
+
+// Close stops refreshing the strategy.
+func (r *Remote) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// ShouldSample implements sdktrace.Sampler.ShouldSample.
+func (r *Remote) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	r.mu.RLock()
+	s, ok := r.perOp[p.Name]
+	if !ok {
+		s = r.fallback
+	}
+	r.mu.RUnlock()
+
+	return s.ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler.Description.
+func (r *Remote) Description() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return fmt.Sprintf("JaegerRemote{%s}", r.fallback.Description())
+}
+
+func (r *Remote) refreshLoop() {
+	ticker := time.NewTicker(r.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.update(); err != nil {
+				otel.Handle(fmt.Errorf("could not refresh sampling strategy: %w", err))
+			}
+		}
+	}
+}
+
+// probabilistic is the Jaeger probabilistic strategy.
+type probabilistic struct {
+	SamplingRate float64 `json:"samplingRate"`
+}
+
+// strategyResponse is the JSON a Jaeger agent serves on its sampling endpoint.
+type strategyResponse struct {
+	ProbabilisticSampling *probabilistic `json:"probabilisticSampling"`
+	RateLimitingSampling  *struct {
+		MaxTracesPerSecond float64 `json:"maxTracesPerSecond"`
+	} `json:"rateLimitingSampling"`
+	OperationSampling *struct {
+		DefaultSamplingProbability float64 `json:"defaultSamplingProbability"`
+		PerOperationStrategies     []struct {
+			Operation             string        `json:"operation"`
+			ProbabilisticSampling probabilistic `json:"probabilisticSampling"`
+		} `json:"perOperationStrategies"`
+	} `json:"operationSampling"`
+}
+
+// update fetches the strategy and switches to it.
+func (r *Remote) update() error {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %s", r.url, resp.Status)
+	}
+
+	var sr strategyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return fmt.Errorf("could not decode sampling strategy: %w", err)
+	}
+
+	var fallback sdktrace.Sampler
+	perOp := map[string]sdktrace.Sampler{}
+	switch {
+	case sr.OperationSampling != nil:
+		fallback = sdktrace.TraceIDRatioBased(sr.OperationSampling.DefaultSamplingProbability)
+		for _, op := range sr.OperationSampling.PerOperationStrategies {
+			perOp[op.Operation] = sdktrace.TraceIDRatioBased(op.ProbabilisticSampling.SamplingRate)
+		}
+	case sr.RateLimitingSampling != nil:
+		perSecond := sr.RateLimitingSampling.MaxTracesPerSecond
+		// A rate limiting strategy that is still in use keeps its sampler, and the traces it sampled
+		// recently, rather than starting every refresh with a full bucket.
+		r.mu.RLock()
+		rl, ok := r.fallback.(*RateLimited)
+		r.mu.RUnlock()
+		if ok {
+			if err := rl.SetLimit(perSecond); err != nil {
+				return fmt.Errorf("invalid rate limiting strategy: %w", err)
+			}
+		} else {
+			var err error
+			if rl, err = NewRateLimited(perSecond); err != nil {
+				return fmt.Errorf("invalid rate limiting strategy: %w", err)
+			}
+		}
+		fallback = rl
+	case sr.ProbabilisticSampling != nil:
+		fallback = sdktrace.TraceIDRatioBased(sr.ProbabilisticSampling.SamplingRate)
+	default:
+		return fmt.Errorf("sampling strategy had no known strategy type")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = fallback
+	r.perOp = perOp
+	return nil
+}
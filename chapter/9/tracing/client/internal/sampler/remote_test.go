@@ -0,0 +1,138 @@
+package sampler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRemote(t *testing.T) {
+	tests := []struct {
+		desc     string
+		status   int
+		strategy string
+		// want are the decisions for the span names, with a sampler that is AlwaysSample until a
+		// strategy is fetched.
+		want map[string]sdktrace.SamplingDecision
+	}{
+		{
+			desc:     "Probabilistic",
+			status:   http.StatusOK,
+			strategy: `{"probabilisticSampling": {"samplingRate": 0}}`,
+			want:     map[string]sdktrace.SamplingDecision{"Request": sdktrace.Drop},
+		},
+		{
+			desc:   "Per operation",
+			status: http.StatusOK,
+			strategy: `{"operationSampling": {"defaultSamplingProbability": 0, "perOperationStrategies": [
+				{"operation": "Checkout", "probabilisticSampling": {"samplingRate": 1}}
+			]}}`,
+			want: map[string]sdktrace.SamplingDecision{"Request": sdktrace.Drop, "Checkout": sdktrace.RecordAndSample},
+		},
+		{
+			desc:     "Rate limiting",
+			status:   http.StatusOK,
+			strategy: `{"rateLimitingSampling": {"maxTracesPerSecond": 1}}`,
+			want:     map[string]sdktrace.SamplingDecision{"Request": sdktrace.RecordAndSample},
+		},
+		{
+			desc:     "Unknown strategy keeps the initial sampler",
+			status:   http.StatusOK,
+			strategy: `{}`,
+			want:     map[string]sdktrace.SamplingDecision{"Request": sdktrace.RecordAndSample},
+		},
+		{
+			desc:   "Error keeps the initial sampler",
+			status: http.StatusInternalServerError,
+			want:   map[string]sdktrace.SamplingDecision{"Request": sdktrace.RecordAndSample},
+		},
+	}
+
+	for _, test := range tests {
+		var service string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			service = r.URL.Query().Get("service")
+			w.WriteHeader(test.status)
+			fmt.Fprint(w, test.strategy)
+		}))
+
+		r, err := NewRemote(srv.URL+"/sampling", "demo-client", time.Hour, sdktrace.AlwaysSample())
+		if err != nil {
+			t.Fatalf("TestRemote(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		if service != "demo-client" {
+			t.Errorf("TestRemote(%s): got service=%q, want service=%q", test.desc, service, "demo-client")
+		}
+		for name, want := range test.want {
+			p := sdktrace.SamplingParameters{TraceID: trace.TraceID{1}, Name: name}
+			if got := r.ShouldSample(p).Decision; got != want {
+				t.Errorf("TestRemote(%s): got %v for %s, want %v", test.desc, got, name, want)
+			}
+		}
+		r.Close()
+		// Closing again is a no-op.
+		r.Close()
+		srv.Close()
+	}
+}
+
+func TestNewRemoteErrors(t *testing.T) {
+	if _, err := NewRemote("http://[::1", "demo-client", time.Minute, sdktrace.AlwaysSample()); err == nil {
+		t.Errorf("TestNewRemoteErrors(bad URL): got err == nil, want err != nil")
+	}
+	if _, err := NewRemote("http://localhost:5778/sampling", "demo-client", 0, sdktrace.AlwaysSample()); err == nil {
+		t.Errorf("TestNewRemoteErrors(no refresh): got err == nil, want err != nil")
+	}
+}
+
+func TestRemoteRefreshKeepsRateLimited(t *testing.T) {
+	strategy := `{"rateLimitingSampling": {"maxTracesPerSecond": 1}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strategy)
+	}))
+	defer srv.Close()
+
+	r, err := NewRemote(srv.URL+"/sampling", "demo-client", time.Hour, sdktrace.AlwaysSample())
+	if err != nil {
+		t.Fatalf("TestRemoteRefreshKeepsRateLimited: got err == %s, want err == nil", err)
+	}
+	defer r.Close()
+	rl, ok := r.fallback.(*RateLimited)
+	if !ok {
+		t.Fatalf("TestRemoteRefreshKeepsRateLimited: got sampler %s, want a RateLimited", r.Description())
+	}
+	now := time.Now()
+	rl.bucket.now = func() time.Time { return now }
+	rl.bucket.last = now
+
+	p := sdktrace.SamplingParameters{TraceID: trace.TraceID{1}, Name: "Request"}
+	if got := r.ShouldSample(p).Decision; got != sdktrace.RecordAndSample {
+		t.Fatalf("TestRemoteRefreshKeepsRateLimited: got %v, want %v", got, sdktrace.RecordAndSample)
+	}
+
+	// Refreshing the same strategy type updates the limit, but the trace just sampled still counts.
+	strategy = `{"rateLimitingSampling": {"maxTracesPerSecond": 2}}`
+	if err := r.update(); err != nil {
+		t.Fatalf("TestRemoteRefreshKeepsRateLimited: update: got err == %s, want err == nil", err)
+	}
+	if r.fallback != rl {
+		t.Errorf("TestRemoteRefreshKeepsRateLimited: got a new sampler after the refresh, want the same RateLimited")
+	}
+	if got := rl.Limit(); got != 2 {
+		t.Errorf("TestRemoteRefreshKeepsRateLimited: got limit %v, want 2", got)
+	}
+	if got := r.ShouldSample(p).Decision; got != sdktrace.Drop {
+		t.Errorf("TestRemoteRefreshKeepsRateLimited: after refresh: got %v, want %v", got, sdktrace.Drop)
+	}
+	now = now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if got := r.ShouldSample(p).Decision; got != sdktrace.RecordAndSample {
+			t.Errorf("TestRemoteRefreshKeepsRateLimited: decision %d a second later: got %v, want %v", i, got, sdktrace.RecordAndSample)
+		}
+	}
+}
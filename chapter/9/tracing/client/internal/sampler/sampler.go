@@ -0,0 +1,88 @@
+/*
+Package sampler provides sdktrace.Sampler implementations for the demo client that go beyond the
+samplers in the OpenTelemetry SDK.
+
+RateLimited samples at most a set number of traces per second. It should be wrapped in a
+ParentBased sampler so that the children of a sampled span are also sampled:
+
+	s, err := sampler.NewRateLimited(100)
+	if err != nil {
+		// Do something
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.ParentBased(s)))
+*/
+package sampler
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket that is refilled at rate tokens per second, up to burst tokens.
+// Tokens are added when take() is called, so there is no goroutine to stop.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// now is time.Now, except in tests.
+	now func() time.Time
+}
+
+// newTokenBucket creates a full tokenBucket that refills at rate tokens per second. The bucket
+// holds a second's worth of tokens, but never less than 1.
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := math.Max(1, rate)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// take removes a token and returns true. If the bucket is empty, it returns false.
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// setRate changes the rate the bucket refills at, and its burst to a second's worth of tokens like
+// newTokenBucket. The tokens already in the bucket are kept, up to the new burst.
+func (b *tokenBucket) setRate(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Tokens up to now are added at the old rate.
+	b.refill()
+	b.rate = rate
+	b.burst = math.Max(1, rate)
+	b.tokens = math.Min(b.burst, b.tokens)
+}
+
+// currentRate returns the rate the bucket refills at.
+func (b *tokenBucket) currentRate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rate
+}
+
+// refill adds the tokens for the time since the last refill. b.mu must be held.
+func (b *tokenBucket) refill() {
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+	}
+	b.last = now
+}
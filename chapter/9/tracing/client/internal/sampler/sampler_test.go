@@ -0,0 +1,61 @@
+package sampler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// A new bucket is full and holds a second's worth of tokens.
+	for i := 0; i < 2; i++ {
+		if !b.take() {
+			t.Fatalf("TestTokenBucket: take(%d) on a full bucket: got false, want true", i)
+		}
+	}
+	if b.take() {
+		t.Fatalf("TestTokenBucket: take() on an empty bucket: got true, want false")
+	}
+
+	// Half a second at 2 tokens per second adds a single token.
+	now = now.Add(500 * time.Millisecond)
+	if !b.take() {
+		t.Fatalf("TestTokenBucket: take() after refill: got false, want true")
+	}
+	if b.take() {
+		t.Fatalf("TestTokenBucket: second take() after refill: got true, want false")
+	}
+
+	// Refilling never goes past the burst size.
+	now = now.Add(time.Hour)
+	got := 0
+	for b.take() {
+		got++
+	}
+	if got != 2 {
+		t.Errorf("TestTokenBucket: tokens after a long wait: got %d, want 2", got)
+	}
+}
+
+func TestTokenBucketFractionalRate(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(0.5)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	if !b.take() {
+		t.Fatalf("TestTokenBucketFractionalRate: first take(): got false, want true")
+	}
+	now = now.Add(time.Second)
+	if b.take() {
+		t.Fatalf("TestTokenBucketFractionalRate: take() after 1s: got true, want false")
+	}
+	now = now.Add(time.Second)
+	if !b.take() {
+		t.Errorf("TestTokenBucketFractionalRate: take() after 2s: got false, want true")
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeWorkload is the client of the Kubernetes API that -kube-api requests use. It is nil unless -kube-api
+// is set.
+var kubeWorkload *kubeClient
+
+// kubeClient lists and watches pods with a clientset whose transport creates a span for each call to the
+// API server, as an operator's would.
+type kubeClient struct {
+	clientset kubernetes.Interface
+	// host is the API server's URL.
+	host string
+}
+
+// newKubeClient creates a kubeClient from -kubeconfig or, if it isn't set, the pod's service account.
+func newKubeClient() (*kubeClient, error) {
+	var config *rest.Config
+	var err error
+	if *kubeconfig != "" {
+		config, err = clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	} else {
+		config, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-kube-api needs -kubeconfig outside Kubernetes: %w", err)
+	}
+	// client-go's own transport, with its authentication and rate limits, is wrapped, so each call, including
+	// each page of a list and the watch, is a span whose trace context is sent to the API server.
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt, otelhttp.WithSpanNameFormatter(kubeSpanName))
+	})
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &kubeClient{clientset: clientset, host: config.Host}, nil
+}
+
+// kubeSpanName names the span of a call to the API server by its method and path, like
+// "GET /api/v1/namespaces/default/pods".
+func kubeSpanName(_ string, r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+// listAndWatch lists the pods in -kube-namespace that match -kube-label-selector and, with -kube-watch,
+// watches them from the list's resource version, adding an event to span for each change. It returns the
+// number of pods listed and the changes watched.
+func (k *kubeClient) listAndWatch(ctx context.Context, span trace.Span) (pods, changes int, err error) {
+	client := k.clientset.CoreV1().Pods(*kubeNamespace)
+	list, err := client.List(ctx, metav1.ListOptions{LabelSelector: *kubeLabelSelector})
+	if err != nil {
+		return 0, 0, err
+	}
+	span.SetAttributes(attribute.Int("demo.k8s.pods", len(list.Items)))
+	if *kubeWatch <= 0 {
+		return len(list.Items), 0, nil
+	}
+
+	// The API server ends the watch after -kube-watch, rounded up to a second, so the request isn't cancelled.
+	seconds := int64((*kubeWatch + time.Second - 1) / time.Second)
+	w, err := client.Watch(ctx, metav1.ListOptions{
+		LabelSelector:   *kubeLabelSelector,
+		ResourceVersion: list.ResourceVersion,
+		TimeoutSeconds:  &seconds,
+	})
+	if err != nil {
+		return len(list.Items), 0, err
+	}
+	defer w.Stop()
+	for event := range w.ResultChan() {
+		if event.Type == watch.Error {
+			return len(list.Items), changes, apierrors.FromObject(event.Object)
+		}
+		pod, ok := event.Object.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		changes++
+		span.AddEvent("pod "+strings.ToLower(string(event.Type)), trace.WithAttributes(
+			semconv.K8SNamespaceNameKey.String(pod.Namespace),
+			semconv.K8SPodNameKey.String(pod.Name),
+			attribute.String("demo.k8s.pod.phase", string(pod.Status.Phase)),
+		))
+	}
+	span.SetAttributes(attribute.Int("demo.k8s.pod_changes", changes))
+	return len(list.Items), changes, ctx.Err()
+}
+
+// sendKubeRequest lists, and with -kube-watch watches, pods in a new trace, like sendRequest sends an HTTP
+// request. A failed call is recorded on its span and logged, and the error returned.
+func sendKubeRequest(tracer trace.Tracer, log *zap.Logger, instruments ClientInstruments) error {
+	ctx := withBaggage(parentCtx)
+	if *debugTrace {
+		ctx = forcesample.With(ctx)
+	}
+	ctx, span := tracer.Start(
+		ctx,
+		"ExecuteRequest",
+		trace.WithAttributes(
+			attribute.String("demo.target", kubeWorkload.host),
+			attribute.String("demo.k8s.namespace", *kubeNamespace),
+			attribute.String("demo.k8s.label_selector", *kubeLabelSelector),
+		),
+		trace.WithAttributes(baggageAttributes(ctx)...),
+	)
+	defer span.End()
+	if *requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *requestTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	pods, changes, err := kubeWorkload.listAndWatch(ctx, span)
+	latency := time.Since(start)
+	instruments.RED.Record(
+		ctx,
+		latency,
+		err != nil,
+		attribute.String("k8s.resource", "pods"),
+		attribute.String("k8s.reason", string(apierrors.ReasonForError(err))),
+	)
+	if adaptiveSampler != nil {
+		adaptiveSampler.Record(err != nil)
+	}
+	if err != nil {
+		recordRequestError(ctx, log, kubeWorkload.host, err)
+		return err
+	}
+	SuccessfullyFinishedRequestEvent(span)
+	log.Debug(
+		"request finished",
+		Ctx(ctx),
+		zap.Int("pods", pods),
+		zap.Int("changes", changes),
+		zap.Duration("latency", latency),
+	)
+	return nil
+}
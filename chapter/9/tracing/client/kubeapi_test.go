@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubeList(t *testing.T) {
+	pod := func(namespace, name, app string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}}}
+	}
+	k := &kubeClient{clientset: fake.NewSimpleClientset(
+		pod("default", "demo-server-1", "demo-server"),
+		pod("default", "demo-server-2", "demo-server"),
+		pod("default", "demo-client-1", "demo-client"),
+		pod("observability", "otel-collector-1", "otel-collector"),
+	)}
+
+	tests := []struct {
+		desc      string
+		namespace string
+		selector  string
+		want      int
+	}{
+		{desc: "Every namespace", want: 4},
+		{desc: "One namespace", namespace: "default", want: 3},
+		{desc: "Label selector", namespace: "default", selector: "app=demo-server", want: 2},
+		{desc: "No match", namespace: "observability", selector: "app=demo-server", want: 0},
+	}
+
+	oldNamespace, oldSelector, oldWatch := *kubeNamespace, *kubeLabelSelector, *kubeWatch
+	defer func() { *kubeNamespace, *kubeLabelSelector, *kubeWatch = oldNamespace, oldSelector, oldWatch }()
+	*kubeWatch = 0
+
+	for _, test := range tests {
+		*kubeNamespace, *kubeLabelSelector = test.namespace, test.selector
+		got, _, err := k.listAndWatch(context.Background(), trace.SpanFromContext(context.Background()))
+		if err != nil {
+			t.Errorf("TestKubeList(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestKubeList(%s): got %d pods, want %d", test.desc, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/k8sinfo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// elector is the -leader-elect leader election, or nil without it.
+var elector *leaderElector
+
+// leaderElector tracks whether this client holds the -leader-elect-lease, so that of the replicas of a
+// Deployment only the leader sends requests and the others stand by to take over.
+type leaderElector struct {
+	identity string
+
+	mu      sync.Mutex
+	leading bool
+	// changed is closed, and replaced, when leading changes.
+	changed chan struct{}
+}
+
+func newLeaderElector(identity string) *leaderElector {
+	return &leaderElector{identity: identity, changed: make(chan struct{})}
+}
+
+// wait blocks until this client is the leader, and returns true, or until ctx is done, and returns false.
+func (e *leaderElector) wait(ctx context.Context) bool {
+	for {
+		e.mu.Lock()
+		leading, changed := e.leading, e.changed
+		e.mu.Unlock()
+		if leading {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// setLeading records whether this client is the leader.
+func (e *leaderElector) setLeading(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leading == e.leading {
+		return
+	}
+	e.leading = leading
+	close(e.changed)
+	e.changed = make(chan struct{})
+	clientHealth.recordLeadership(leading, time.Now())
+}
+
+// recordChange records a change of leader, in a "Leader election" span with a "leadership changed"
+// event, always sampled so every change can be found in the tracing backend.
+func (e *leaderElector) recordChange(leader string) {
+	ctx, span := otel.Tracer("demo-client-tracer").Start(forcesample.With(context.Background()), "Leader election")
+	defer span.End()
+	span.AddEvent("leadership changed", trace.WithAttributes(
+		attribute.String("demo.leader.lease", *leaderElectLease),
+		attribute.String("demo.leader.identity", leader),
+		attribute.String("demo.leader.candidate", e.identity),
+		attribute.Bool("demo.leader.is_self", leader == e.identity),
+	))
+	logger.Info("leadership changed", zap.String("leader", leader), zap.Bool("leading", leader == e.identity), Ctx(ctx))
+}
+
+// startLeaderElection starts competing for the -leader-elect-lease in the pod's namespace, as the pod, until
+// ctx is done, when the lease is released if it is held. The client must run in Kubernetes, with a
+// service account allowed to get, create and update leases.
+func startLeaderElection(ctx context.Context) (*leaderElector, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("-leader-elect needs to run in Kubernetes: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	info := k8sinfo.Get()
+	namespace := *leaderElectNamespace
+	if namespace == "" {
+		namespace = info.Namespace
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("-leader-elect-namespace must be set, as the pod's namespace isn't known")
+	}
+	identity := info.PodName
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+
+	e := newLeaderElector(identity)
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: *leaderElectLease, Namespace: namespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: lock,
+		// In the same proportions as Kubernetes' own controllers, 15s, 10s and 2s by default.
+		LeaseDuration:   *leaderElectLeaseDuration,
+		RenewDeadline:   *leaderElectLeaseDuration * 2 / 3,
+		RetryPeriod:     *leaderElectLeaseDuration * 2 / 15,
+		ReleaseOnCancel: true,
+		Name:            *leaderElectLease,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) { e.setLeading(true) },
+			OnStoppedLeading: func() { e.setLeading(false) },
+			OnNewLeader:      e.recordChange,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		// Run returns when leadership is lost, after which the client is a candidate again.
+		for ctx.Err() == nil {
+			le.Run(ctx)
+		}
+	}()
+	logger.Info("standing by until elected leader", zap.String("lease", namespace+"/"+*leaderElectLease), zap.String("identity", identity))
+	return e, nil
+}
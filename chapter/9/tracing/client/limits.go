@@ -0,0 +1,23 @@
+package client
+
+import (
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanLimits returns the sdktrace.SpanLimits set by the -span-* limit flags. Limits not exposed as flags
+// come from the OTEL_*_LIMIT environment variables the SDK reads. A negative limit means unlimited.
+func spanLimits() (sdktrace.SpanLimits, error) {
+	if *spanAttributeValueLengthLimit == 0 {
+		// The SDK would truncate every string attribute to "", which is never what's wanted.
+		return sdktrace.SpanLimits{}, fmt.Errorf("-span-attribute-value-length-limit cannot be 0, use a negative value for no limit")
+	}
+
+	limits := sdktrace.NewSpanLimits()
+	limits.AttributeValueLengthLimit = *spanAttributeValueLengthLimit
+	limits.AttributeCountLimit = *spanAttributeCountLimit
+	limits.EventCountLimit = *spanEventCountLimit
+	limits.LinkCountLimit = *spanLinkCountLimit
+	return limits, nil
+}
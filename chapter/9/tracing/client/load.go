@@ -0,0 +1,203 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// loadInterval returns the time between the start of requests. A rate, in requests per second,
+// takes precedence over interval when it is set. If maxQPS is set, the interval is never shorter than
+// 1/maxQPS, so a misconfigured rate can't overwhelm the server.
+func loadInterval(interval time.Duration, rate, maxQPS float64) (time.Duration, error) {
+	switch {
+	case rate < 0:
+		return 0, fmt.Errorf("-rate cannot be negative, was %v", rate)
+	case maxQPS < 0:
+		return 0, fmt.Errorf("-max-qps cannot be negative, was %v", maxQPS)
+	case rate > 0:
+		interval = time.Duration(float64(time.Second) / rate)
+	case interval <= 0:
+		return 0, fmt.Errorf("-request-interval must be positive, was %s", interval)
+	}
+
+	if maxQPS > 0 {
+		if min := time.Duration(float64(time.Second) / maxQPS); interval < min {
+			interval = min
+		}
+	}
+	if interval <= 0 {
+		// A rate too high to represent as a duration.
+		return 0, fmt.Errorf("-rate=%v is too high", rate)
+	}
+	return interval, nil
+}
+
+// pacer decides when requests are started.
+type pacer struct {
+	// interval is the time between requests when there is no profile.
+	interval time.Duration
+	// profile, if set, is the rate requests are sent at over time.
+	profile loadProfile
+	// maxQPS, if set, caps the rate of the profile.
+	maxQPS float64
+	// distribution is how the wait between requests varies around the one for the rate: "constant",
+	// "uniform" for within jitter of it, or "exponential" for waits with it as the mean.
+	distribution string
+	// jitter is the fraction of the wait it may vary by with the uniform distribution.
+	jitter float64
+}
+
+// validate returns an error if the distribution or jitter aren't valid.
+func (p pacer) validate() error {
+	switch p.distribution {
+	case "constant", "exponential":
+	case "uniform":
+		if p.jitter < 0 || p.jitter > 1 {
+			return fmt.Errorf("-request-jitter must be between 0 and 1, was %v", p.jitter)
+		}
+	default:
+		return fmt.Errorf("-request-distribution=%s is not a valid value", p.distribution)
+	}
+	return nil
+}
+
+// idleDelay is how long to wait before checking the profile again when its rate is 0.
+const idleDelay = time.Second
+
+// next returns whether to send a request elapsed after the first one, and how long to wait before
+// asking again.
+func (p pacer) next(elapsed time.Duration) (send bool, wait time.Duration) {
+	if p.profile == nil {
+		return true, p.vary(p.interval)
+	}
+	rate := p.profile(elapsed)
+	if p.maxQPS > 0 && rate > p.maxQPS {
+		rate = p.maxQPS
+	}
+	if rate <= 0 {
+		return false, idleDelay
+	}
+	return true, p.vary(time.Duration(float64(time.Second) / rate))
+}
+
+// vary returns wait varied by the pacer's distribution, so requests don't arrive in a perfectly regular
+// rhythm. The average wait, and so the rate, stays the same.
+func (p pacer) vary(wait time.Duration) time.Duration {
+	switch p.distribution {
+	case "uniform":
+		return wait + time.Duration((2*rand.Float64()-1)*p.jitter*float64(wait))
+	case "exponential":
+		return time.Duration(rand.ExpFloat64() * float64(wait))
+	}
+	return wait
+}
+
+// maxLatencySamples is the most request latencies loadStats keeps to compute percentiles from.
+const maxLatencySamples = 100000
+
+// loadStats totals the requests sent by all of the workers.
+type loadStats struct {
+	start time.Time
+
+	mu      sync.Mutex
+	sent    int
+	failed  int
+	dropped int
+	latency time.Duration
+	max     time.Duration
+	// samples is a uniform random sample of the latencies, so long runs use bounded memory.
+	samples []time.Duration
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{start: time.Now()}
+}
+
+// record adds a request that took latency to the totals.
+func (s *loadStats) record(latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent++
+	if failed {
+		s.failed++
+	}
+	s.latency += latency
+	if latency > s.max {
+		s.max = latency
+	}
+	// Reservoir sampling keeps each latency with the same probability.
+	if len(s.samples) < maxLatencySamples {
+		s.samples = append(s.samples, latency)
+	} else if i := rand.Intn(s.sent); i < maxLatencySamples {
+		s.samples[i] = latency
+	}
+}
+
+// drop counts a request that wasn't sent because too many were in flight.
+func (s *loadStats) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropped++
+}
+
+// errorRate returns the fraction of requests that failed.
+func (s *loadStats) errorRate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sent == 0 {
+		return 0
+	}
+	return float64(s.failed) / float64(s.sent)
+}
+
+// percentile returns the latency a fraction p of the sorted latencies are at or below.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// log logs the totals, the latency percentiles and the rate requests were sent at since the stats
+// were created.
+func (s *loadStats) log(log *zap.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := append([]time.Duration(nil), s.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	elapsed := time.Since(s.start)
+	fields := []zap.Field{
+		zap.Int("sent", s.sent),
+		zap.Int("failed", s.failed),
+		zap.Int("dropped", s.dropped),
+		zap.Duration("elapsed", elapsed),
+		zap.Float64("qps", float64(s.sent)/elapsed.Seconds()),
+		zap.Duration("p50_latency", percentile(sorted, 0.50)),
+		zap.Duration("p95_latency", percentile(sorted, 0.95)),
+		zap.Duration("p99_latency", percentile(sorted, 0.99)),
+		zap.Duration("max_latency", s.max),
+	}
+	if s.sent > 0 {
+		fields = append(
+			fields,
+			zap.Duration("mean_latency", s.latency/time.Duration(s.sent)),
+			zap.Float64("error_rate", float64(s.failed)/float64(s.sent)),
+		)
+	}
+	log.Info("requests finished", fields...)
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadInterval(t *testing.T) {
+	tests := []struct {
+		desc     string
+		interval time.Duration
+		rate     float64
+		maxQPS   float64
+		want     time.Duration
+		wantErr  bool
+	}{
+		{
+			desc:     "Interval",
+			interval: 250 * time.Millisecond,
+			want:     250 * time.Millisecond,
+		},
+		{
+			desc:     "Rate takes precedence",
+			interval: time.Second,
+			rate:     20,
+			want:     50 * time.Millisecond,
+		},
+		{
+			desc:     "Sub-second rate",
+			interval: time.Second,
+			rate:     0.5,
+			want:     2 * time.Second,
+		},
+		{
+			desc:     "Capped by max QPS",
+			interval: time.Millisecond,
+			maxQPS:   100,
+			want:     10 * time.Millisecond,
+		},
+		{
+			desc:     "Under max QPS",
+			interval: time.Second,
+			maxQPS:   100,
+			want:     time.Second,
+		},
+		{
+			desc:     "Zero interval",
+			interval: 0,
+			wantErr:  true,
+		},
+		{
+			desc:     "Negative rate",
+			interval: time.Second,
+			rate:     -1,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := loadInterval(test.interval, test.rate, test.maxQPS)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestLoadInterval(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestLoadInterval(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestLoadInterval(%s): got %s, want %s", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestPacerVary(t *testing.T) {
+	wait := 100 * time.Millisecond
+
+	p := pacer{distribution: "constant"}
+	if got := p.vary(wait); got != wait {
+		t.Errorf("TestPacerVary(constant): got %s, want %s", got, wait)
+	}
+
+	p = pacer{distribution: "uniform", jitter: 0.2}
+	for i := 0; i < 1000; i++ {
+		if got := p.vary(wait); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("TestPacerVary(uniform): got %s, want between 80ms and 120ms", got)
+		}
+	}
+
+	p = pacer{distribution: "exponential"}
+	var total time.Duration
+	for i := 0; i < 10000; i++ {
+		got := p.vary(wait)
+		if got < 0 {
+			t.Fatalf("TestPacerVary(exponential): got %s, want >= 0", got)
+		}
+		total += got
+	}
+	if mean := total / 10000; mean < 90*time.Millisecond || mean > 110*time.Millisecond {
+		t.Errorf("TestPacerVary(exponential): got mean %s, want about %s", mean, wait)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Millisecond},
+		{p: 0.50, want: 50 * time.Millisecond},
+		{p: 0.95, want: 95 * time.Millisecond},
+		{p: 0.99, want: 99 * time.Millisecond},
+		{p: 1, want: 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := percentile(sorted, test.p); got != test.want {
+			t.Errorf("TestPercentile(%v): got %s, want %s", test.p, got, test.want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("TestPercentile(empty): got %s, want 0", got)
+	}
+}
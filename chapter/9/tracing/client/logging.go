@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// newLogger creates the logger for operational messages at the -log-level. With -log-format=json, entries
+// are JSON encoded with ISO8601 timestamps so they can be parsed by log collectors. With 'console', they
+// are written as colored, tab separated lines that are easier to read when running the client locally.
+//
+// With -log-file, entries are also written as JSON to the file, which is rotated when it reaches
+// -log-file-max-size megabytes. Rotated files older than -log-file-max-age days or beyond the newest
+// -log-file-max-backups are removed.
+//
+// Entries logged with a Ctx field get the span and trace IDs of the span in its context.
+//
+// With -log-sampling, the first -log-sampling-initial entries with the same level and message each second
+// are logged, then every -log-sampling-thereafter'th. This keeps a failing request loop from flooding
+// the logs.
+func newLogger() (*zap.Logger, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return nil, fmt.Errorf("-log-level=%s is not a valid value", *logLevel)
+	}
+
+	config := zap.NewProductionConfig()
+	switch strings.ToLower(*logFormat) {
+	case "json":
+	case "console":
+		config.Encoding = "console"
+		config.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	default:
+		return nil, fmt.Errorf("-log-format=%s is not a valid value", *logFormat)
+	}
+	logAtomicLevel.SetLevel(level)
+	config.Level = logAtomicLevel
+	config.EncoderConfig.TimeKey = "time"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	config.Sampling = nil
+	if *tui {
+		// The dashboard takes over the terminal, so entries only go to -log-file and -logs-exporter.
+		config.OutputPaths = nil
+	}
+	opts := []zap.Option{zap.Fields(zap.String("service", serviceName))}
+	if *logFile != "" {
+		if *logFileMaxSize < 1 || *logFileMaxAge < 0 || *logFileMaxBackups < 0 {
+			return nil, fmt.Errorf("-log-file-max-size must be at least 1, -log-file-max-age and -log-file-max-backups cannot be negative")
+		}
+		file := newLogFileCore()
+		opts = append(opts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(c, file)
+		}))
+	}
+	opts = append(opts, zap.WrapCore(func(c zapcore.Core) zapcore.Core { return traceCore{Core: c} }))
+	if *logSampling {
+		if *logSamplingInitial < 0 || *logSamplingThereafter < 0 {
+			return nil, fmt.Errorf("-log-sampling-initial and -log-sampling-thereafter cannot be negative")
+		}
+		opts = append(opts, zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(
+				c,
+				time.Second,
+				*logSamplingInitial,
+				*logSamplingThereafter,
+				zapcore.SamplerHook(countSuppressed),
+			)
+		}))
+	}
+	return config.Build(opts...)
+}
+
+// newLogFileCore returns a core that writes JSON entries to -log-file, rotating it as set by the
+// -log-file-max-* flags.
+func newLogFileCore() zapcore.Core {
+	w := &lumberjack.Logger{
+		Filename:   *logFile,
+		MaxSize:    *logFileMaxSize,
+		MaxAge:     *logFileMaxAge,
+		MaxBackups: *logFileMaxBackups,
+		Compress:   *logFileCompress,
+	}
+	encConfig := zap.NewProductionEncoderConfig()
+	encConfig.TimeKey = "time"
+	encConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	return zapcore.NewCore(zapcore.NewJSONEncoder(encConfig), zapcore.AddSync(w), logAtomicLevel)
+}
+
+// logAtomicLevel is the level of the logger, which can be changed while the client runs.
+var logAtomicLevel = zap.NewAtomicLevel()
+
+// toggleDebugOnSignal switches the log level between debug and -log-level each time the client gets
+// SIGUSR1, so a running client can be debugged without restarting it. It returns when ctx is cancelled.
+func toggleDebugOnSignal(ctx context.Context) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	defer signal.Stop(usr1)
+
+	configured := logAtomicLevel.Level()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-usr1:
+		}
+		level := zapcore.DebugLevel
+		if logAtomicLevel.Level() == zapcore.DebugLevel {
+			level = configured
+		}
+		logAtomicLevel.SetLevel(level)
+		logger.Info("log level changed", zap.Stringer("level", level))
+	}
+}
+
+// slogLevel is a slog.Leveler that follows logAtomicLevel, so slog and zap log at the same level.
+type slogLevel struct{}
+
+// Level implements slog.Leveler.Level.
+func (slogLevel) Level() slog.Level {
+	switch logAtomicLevel.Level() {
+	case zapcore.DebugLevel:
+		return slog.LevelDebug
+	case zapcore.InfoLevel:
+		return slog.LevelInfo
+	case zapcore.WarnLevel:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+// suppressedLogs is the number of log entries dropped by log sampling.
+var suppressedLogs int64
+
+// countSuppressed is a zapcore.SamplerHook that counts the entries the sampler drops.
+func countSuppressed(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+	if dec&zapcore.LogDropped != 0 {
+		atomic.AddInt64(&suppressedLogs, 1)
+	}
+}
+
+// registerLogMetrics reports the number of log entries suppressed by sampling as a metric, so a flood
+// of messages is still visible when most of them aren't logged.
+func registerLogMetrics() {
+	metric.Must(global.Meter(meterName)).NewInt64CounterObserver(
+		"demo_client/logs_suppressed",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(atomic.LoadInt64(&suppressedLogs))
+		},
+		metric.WithDescription("The number of log entries dropped by log sampling"),
+	)
+}
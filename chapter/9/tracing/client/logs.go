@@ -0,0 +1,328 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// logBatchSize is the most log records sent in one export.
+	logBatchSize = 512
+	// logExportInterval is how often buffered log records are exported.
+	logExportInterval = time.Second
+)
+
+// initLogs sets up the logs exporter selected by -logs-exporter. With 'otlp', logger is replaced with one
+// that also sends every entry to the OTLP collector at -otlp-endpoint, described by res like the spans
+// and metrics are.
+//
+// The OpenTelemetry Go logs SDK and its otlploggrpc exporter need otel v1.28 or later, and this client
+// is on v1.6, so entries are sent with the OTLP logs service directly. With -dry-run, logs are only
+// written to stderr.
+func initLogs(ctx context.Context, res *resource.Resource) (func(context.Context), error) {
+	if *dryRun {
+		return func(context.Context) {}, nil
+	}
+	switch strings.ToLower(*logsExporter) {
+	case "none":
+		return func(context.Context) {}, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("-logs-exporter=%s is not a valid value", *logsExporter)
+	}
+
+	exp, err := newOTLPLogExporter(ctx, res)
+	if err != nil {
+		return nil, err
+	}
+	core := &otlpLogCore{LevelEnabler: logger.Core(), exp: exp}
+	logger = logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, core)
+	}))
+
+	return func(doneCtx context.Context) {
+		if err := exp.shutdown(doneCtx); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
+}
+
+// otlpLogExporter batches log records and exports them to an OTLP collector's logs service.
+type otlpLogExporter struct {
+	conn     *grpc.ClientConn
+	client   collogspb.LogsServiceClient
+	headers  metadata.MD
+	resource *resourcepb.Resource
+
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newOTLPLogExporter connects to the OTLP collector with the same TLS and header settings as the
+// span exporters. The connection is made in the background.
+func newOTLPLogExporter(ctx context.Context, res *resource.Resource) (*otlpLogExporter, error) {
+	tlsConf, err := telemetryflags.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	headers, err := telemetryflags.Headers()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := insecure.NewCredentials()
+	if tlsConf != nil {
+		creds = credentials.NewTLS(tlsConf)
+	}
+	target, dialOpts, err := telemetryflags.OTLPTarget(*otlpEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("-otlp-discovery: %w", err)
+	}
+	conn, err := grpc.DialContext(ctx, target, append(dialOpts, grpc.WithTransportCredentials(creds))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the OTLP collector for logs: %w", err)
+	}
+
+	e := &otlpLogExporter{
+		conn:     conn,
+		client:   collogspb.NewLogsServiceClient(conn),
+		headers:  metadata.New(headers),
+		resource: &resourcepb.Resource{Attributes: keyValues(res.Attributes())},
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go e.exportLoop()
+	return e, nil
+}
+
+// add buffers r to be exported. If the buffer is full, r is dropped rather than blocking the caller.
+func (e *otlpLogExporter) add(r *logspb.LogRecord) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Keep a few batches buffered in case the collector is briefly unavailable.
+	if len(e.records) >= 4*logBatchSize {
+		return
+	}
+	e.records = append(e.records, r)
+}
+
+// exportLoop exports buffered records every logExportInterval until shutdown is called.
+func (e *otlpLogExporter) exportLoop() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(logExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), logExportInterval)
+			if err := e.export(ctx); err != nil {
+				// The error handler logs this too, the buffer limit in add() keeps a collector that
+				// is down from growing it.
+				otel.Handle(err)
+			}
+			cancel()
+		}
+	}
+}
+
+// export sends the buffered records to the collector, logBatchSize at a time. Records that fail to send
+// are dropped.
+func (e *otlpLogExporter) export(ctx context.Context) error {
+	e.mu.Lock()
+	records := e.records
+	e.records = nil
+	e.mu.Unlock()
+
+	ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	for len(records) > 0 {
+		n := len(records)
+		if n > logBatchSize {
+			n = logBatchSize
+		}
+		req := &collogspb.ExportLogsServiceRequest{
+			ResourceLogs: []*logspb.ResourceLogs{
+				{
+					Resource: e.resource,
+					InstrumentationLibraryLogs: []*logspb.InstrumentationLibraryLogs{
+						{
+							InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: serviceName},
+							LogRecords:             records[:n],
+						},
+					},
+				},
+			},
+		}
+		if _, err := e.client.Export(ctx, req); err != nil {
+			return fmt.Errorf("failed to export %d log records: %w", len(records), err)
+		}
+		records = records[n:]
+	}
+	return nil
+}
+
+// shutdown exports any buffered records and closes the connection to the collector.
+func (e *otlpLogExporter) shutdown(ctx context.Context) error {
+	close(e.stop)
+	<-e.stopped
+
+	err := e.export(ctx)
+	if cerr := e.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// otlpLogCore is a zapcore.Core that converts entries to OTLP log records for an otlpLogExporter.
+// Entries with a Ctx field are sent with the trace and span IDs of its span.
+type otlpLogCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	span   trace.SpanContext
+	exp    *otlpLogExporter
+}
+
+// With implements zapcore.Core.With.
+func (c *otlpLogCore) With(fields []zapcore.Field) zapcore.Core {
+	sc, fields := spanContextFromFields(fields)
+	if !sc.IsValid() {
+		sc = c.span
+	}
+	return &otlpLogCore{
+		LevelEnabler: c.LevelEnabler,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+		span:         sc,
+		exp:          c.exp,
+	}
+}
+
+// Check implements zapcore.Core.Check.
+func (c *otlpLogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write implements zapcore.Core.Write.
+func (c *otlpLogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	sc, fields := spanContextFromFields(fields)
+	if !sc.IsValid() {
+		sc = c.span
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	attrs := make([]*commonpb.KeyValue, 0, len(enc.Fields)+1)
+	for k, v := range enc.Fields {
+		attrs = append(attrs, &commonpb.KeyValue{Key: k, Value: anyValue(v)})
+	}
+	if ent.LoggerName != "" {
+		attrs = append(attrs, &commonpb.KeyValue{Key: "logger", Value: anyValue(ent.LoggerName)})
+	}
+
+	r := &logspb.LogRecord{
+		TimeUnixNano:   uint64(ent.Time.UnixNano()),
+		SeverityNumber: severity(ent.Level),
+		SeverityText:   ent.Level.CapitalString(),
+		Body:           anyValue(ent.Message),
+		Attributes:     attrs,
+	}
+	if sc.IsValid() {
+		traceID, spanID := sc.TraceID(), sc.SpanID()
+		r.TraceId = traceID[:]
+		r.SpanId = spanID[:]
+		r.Flags = uint32(sc.TraceFlags())
+	}
+	c.exp.add(r)
+	return nil
+}
+
+// Sync implements zapcore.Core.Sync. It exports the buffered records, so entries logged just before
+// exiting, like with logger.Fatal(), aren't lost.
+func (c *otlpLogCore) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), logExportInterval)
+	defer cancel()
+	return c.exp.export(ctx)
+}
+
+// severity returns the OTLP severity number for a zap level.
+func severity(l zapcore.Level) logspb.SeverityNumber {
+	switch l {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	}
+	// DPanic, Panic and Fatal.
+	return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+}
+
+// anyValue converts a value from a zapcore.MapObjectEncoder to an OTLP value. Types OTLP doesn't have,
+// like durations, are sent as strings.
+func anyValue(v interface{}) *commonpb.AnyValue {
+	switch x := v.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: x}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: x}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(x)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: x}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(x)}}
+	case uint32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(x)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: x}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(x)}}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+}
+
+// keyValues converts resource attributes to OTLP key values.
+func keyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		kvs = append(kvs, &commonpb.KeyValue{Key: string(kv.Key), Value: anyValue(kv.Value.AsInterface())})
+	}
+	return kvs
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeLogsClient is a collogspb.LogsServiceClient that records the size of each export.
+type fakeLogsClient struct {
+	batches []int
+	err     error
+}
+
+func (f *fakeLogsClient) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest, _ ...grpc.CallOption) (*collogspb.ExportLogsServiceResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.batches = append(f.batches, len(req.ResourceLogs[0].InstrumentationLibraryLogs[0].LogRecords))
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestOTLPLogExport(t *testing.T) {
+	tests := []struct {
+		desc    string
+		records int
+		err     error
+		want    []int
+		wantErr bool
+	}{
+		{desc: "Nothing buffered", records: 0},
+		{desc: "One batch", records: 3, want: []int{3}},
+		{desc: "Split into batches", records: logBatchSize + 1, want: []int{logBatchSize, 1}},
+		{desc: "Full buffer drops the rest", records: 5 * logBatchSize, want: []int{logBatchSize, logBatchSize, logBatchSize, logBatchSize}},
+		{desc: "Export fails", records: 3, err: errors.New("unavailable"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		client := &fakeLogsClient{err: test.err}
+		e := &otlpLogExporter{client: client}
+		for i := 0; i < test.records; i++ {
+			e.add(&logspb.LogRecord{})
+		}
+
+		err := e.export(context.Background())
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestOTLPLogExport(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestOTLPLogExport(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		if diff := pretty.Compare(test.want, client.batches); diff != "" {
+			t.Errorf("TestOTLPLogExport(%s): batches -want/+got:\n%s", test.desc, diff)
+		}
+		// Records are dropped whether or not they were sent.
+		if len(e.records) != 0 {
+			t.Errorf("TestOTLPLogExport(%s): got %d records still buffered, want 0", test.desc, len(e.records))
+		}
+	}
+}
+
+func TestOTLPLogCore(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	other := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{3}, SpanID: trace.SpanID{4}})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	otherCtx := trace.ContextWithSpanContext(context.Background(), other)
+
+	tests := []struct {
+		desc      string
+		with      []zap.Field
+		fields    []zap.Field
+		wantAttrs map[string]string
+		wantSpan  bool
+	}{
+		{
+			desc:      "No span",
+			fields:    []zap.Field{zap.String("url", "/hello")},
+			wantAttrs: map[string]string{"url": "/hello"},
+		},
+		{
+			desc:      "Ctx passed to With",
+			with:      []zap.Field{Ctx(ctx), zap.String("worker", "1")},
+			fields:    []zap.Field{zap.String("url", "/hello")},
+			wantAttrs: map[string]string{"worker": "1", "url": "/hello"},
+			wantSpan:  true,
+		},
+		{
+			desc:      "Ctx passed with the entry",
+			with:      []zap.Field{zap.String("worker", "1")},
+			fields:    []zap.Field{Ctx(ctx)},
+			wantAttrs: map[string]string{"worker": "1"},
+			wantSpan:  true,
+		},
+		{
+			desc:      "Ctx with the entry takes precedence",
+			with:      []zap.Field{Ctx(otherCtx)},
+			fields:    []zap.Field{Ctx(ctx)},
+			wantAttrs: map[string]string{},
+			wantSpan:  true,
+		},
+	}
+
+	for _, test := range tests {
+		e := &otlpLogExporter{}
+		var core zapcore.Core = &otlpLogCore{LevelEnabler: zapcore.DebugLevel, exp: e}
+		if test.with != nil {
+			core = core.With(test.with)
+		}
+		ent := zapcore.Entry{Level: zapcore.WarnLevel, Time: time.Unix(1, 0), Message: "request failed"}
+		if err := core.Write(ent, test.fields); err != nil {
+			t.Fatalf("TestOTLPLogCore(%s): got err == %s, want err == nil", test.desc, err)
+		}
+
+		if len(e.records) != 1 {
+			t.Errorf("TestOTLPLogCore(%s): got %d records, want 1", test.desc, len(e.records))
+			continue
+		}
+		r := e.records[0]
+		if r.Body.GetStringValue() != "request failed" {
+			t.Errorf("TestOTLPLogCore(%s): got body %q, want %q", test.desc, r.Body.GetStringValue(), "request failed")
+		}
+		if r.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_WARN || r.SeverityText != "WARN" {
+			t.Errorf("TestOTLPLogCore(%s): got severity %s %q, want WARN", test.desc, r.SeverityNumber, r.SeverityText)
+		}
+		if r.TimeUnixNano != uint64(time.Second) {
+			t.Errorf("TestOTLPLogCore(%s): got time %d, want %d", test.desc, r.TimeUnixNano, uint64(time.Second))
+		}
+
+		attrs := map[string]string{}
+		for _, kv := range r.Attributes {
+			attrs[kv.Key] = kv.Value.GetStringValue()
+		}
+		if diff := pretty.Compare(test.wantAttrs, attrs); diff != "" {
+			t.Errorf("TestOTLPLogCore(%s): attributes -want/+got:\n%s", test.desc, diff)
+		}
+
+		var wantTraceID, wantSpanID []byte
+		if test.wantSpan {
+			traceID, spanID := sc.TraceID(), sc.SpanID()
+			wantTraceID, wantSpanID = traceID[:], spanID[:]
+		}
+		if diff := pretty.Compare(wantTraceID, r.TraceId); diff != "" {
+			t.Errorf("TestOTLPLogCore(%s): trace ID -want/+got:\n%s", test.desc, diff)
+		}
+		if diff := pretty.Compare(wantSpanID, r.SpanId); diff != "" {
+			t.Errorf("TestOTLPLogCore(%s): span ID -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		level zapcore.Level
+		want  logspb.SeverityNumber
+	}{
+		{level: zapcore.DebugLevel, want: logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG},
+		{level: zapcore.InfoLevel, want: logspb.SeverityNumber_SEVERITY_NUMBER_INFO},
+		{level: zapcore.WarnLevel, want: logspb.SeverityNumber_SEVERITY_NUMBER_WARN},
+		{level: zapcore.ErrorLevel, want: logspb.SeverityNumber_SEVERITY_NUMBER_ERROR},
+		{level: zapcore.DPanicLevel, want: logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+		{level: zapcore.FatalLevel, want: logspb.SeverityNumber_SEVERITY_NUMBER_FATAL},
+	}
+
+	for _, test := range tests {
+		if got := severity(test.level); got != test.want {
+			t.Errorf("TestSeverity(%s): got %s, want %s", test.level, got, test.want)
+		}
+	}
+}
+
+func TestAnyValue(t *testing.T) {
+	tests := []struct {
+		desc string
+		v    interface{}
+		want *commonpb.AnyValue
+	}{
+		{desc: "String", v: "hello", want: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "hello"}}},
+		{desc: "Bool", v: true, want: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}},
+		{desc: "Int", v: 3, want: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 3}}},
+		{desc: "Int32", v: int32(-3), want: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: -3}}},
+		{desc: "Float32", v: float32(1.5), want: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 1.5}}},
+		{desc: "Duration as a string", v: 2 * time.Second, want: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "2s"}}},
+	}
+
+	for _, test := range tests {
+		if got := anyValue(test.v); !proto.Equal(got, test.want) {
+			t.Errorf("TestAnyValue(%s): got %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
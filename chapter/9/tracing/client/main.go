@@ -1,138 +1,1274 @@
-package main
+package client
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/deadline"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/env"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/hello"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/otelenv"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/redmetrics"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/spanlink"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
-	"google.golang.org/grpc"
 )
 
-// main sets up the trace providers and starts a loop to continuously call the server
-func main() {
-	shutdown := initTraceProvider()
-	defer shutdown()
+// serviceName is the name the client is known by in trace backends.
+const serviceName = "demo-client"
+
+// Flags are the client's own flags, which the tracedemo command parses with telemetryflags.FlagSet.
+var Flags = flag.NewFlagSet("client", flag.ContinueOnError)
+
+// The flags shared by every command, which the client is configured with like its own.
+var (
+	otlpEndpoint    = telemetryflags.OTLPEndpoint
+	propagatorNames = telemetryflags.Propagators
+	shutdownTimeout = telemetryflags.ShutdownTimeout
+	logLevel        = telemetryflags.LogLevel
+	logFormat       = telemetryflags.LogFormat
+	dryRun          = telemetryflags.DryRun
+	sdkDisabled     = telemetryflags.SDKDisabled
+	otelEnvOnly     = telemetryflags.EnvOnly
+	samplerName     = telemetryflags.SamplerName
+	samplerArg      = telemetryflags.SamplerArg
+	metricsInterval = telemetryflags.MetricsInterval
+)
+
+// Flags related to the servers requests are sent to.
+var (
+	serverEndpoint = Flags.String("server-endpoint", env.Or("DEMO_SERVER_ENDPOINT", "http://0.0.0.0:7080/hello"), "The URL requests are sent to. A comma separated list "+
+		"spreads requests over several URLs, each optionally followed by ';weight=N' to send it N times the share of requests. "+
+		"Defaults to env variable 'DEMO_SERVER_ENDPOINT'.",
+	)
+	serverEndpointsFile = Flags.String("server-endpoints-file", env.Or("DEMO_SERVER_ENDPOINTS_FILE", ""), "A YAML file listing the URLs requests are sent to "+
+		"and their weights. Takes precedence over -server-endpoint. Defaults to env variable 'DEMO_SERVER_ENDPOINTS_FILE'.",
+	)
+	protocol = Flags.String("protocol", env.Or("DEMO_PROTOCOL", ""), "How requests are sent: 'http' to -server-endpoint, or 'grpc' to -grpc-endpoint. "+
+		"By default, 'grpc' if -grpc-endpoint is set and 'http' otherwise. Defaults to env variable 'DEMO_PROTOCOL'.",
+	)
+	grpcEndpoint = Flags.String("grpc-endpoint", env.Or("DEMO_GRPC_ENDPOINT", ""), "The host:port of the Greeter gRPC service requests are calls to "+
+		"with -protocol=grpc, like 'demo-server:7081'. Defaults to env variable 'DEMO_GRPC_ENDPOINT'.",
+	)
+	grpcStreamMessages = Flags.Int("grpc-stream-messages", env.Int("GRPC_STREAM_MESSAGES", 0), "If set, gRPC requests are SayHelloStream calls sending "+
+		"this many names on a bidirectional stream, in place of SayHello calls. Defaults to env variable 'GRPC_STREAM_MESSAGES'.",
+	)
+)
+
+// Flags related to load generation.
+var (
+	requestInterval = Flags.Duration("request-interval", env.Duration("REQUEST_INTERVAL", time.Second), "The time between the start of requests, like '1s' or '100ms'. "+
+		"Defaults to env variable 'REQUEST_INTERVAL'.",
+	)
+	requestRate = Flags.Float64("rate", env.Float("REQUEST_RATE", 0), "If set, the requests sent per second, like 0.5 or 20. Takes precedence over -request-interval. "+
+		"Defaults to env variable 'REQUEST_RATE'.",
+	)
+	concurrency = Flags.Int("concurrency", env.Int("CONCURRENCY", 1), "The number of workers sending requests at the same time, each request in its own trace. "+
+		"Defaults to env variable 'CONCURRENCY'.",
+	)
+	requestMethod = Flags.String("request-method", env.Or("REQUEST_METHOD", http.MethodGet), "The HTTP method of requests, like 'GET', 'POST' or 'PUT'. "+
+		"Defaults to env variable 'REQUEST_METHOD'.",
+	)
+	requestBody = Flags.String("request-body", env.Or("REQUEST_BODY", ""), "A text/template rendered as the body of each request, or '@' and the path of a "+
+		"file with one. Templates can use {{uuid}}, {{now}}, {{seq}} and {{randInt min max}}. Defaults to env variable 'REQUEST_BODY'.",
+	)
+	requestContentType = Flags.String("request-content-type", env.Or("REQUEST_CONTENT_TYPE", "application/json"), "The Content-Type of requests with a body. "+
+		"Defaults to env variable 'REQUEST_CONTENT_TYPE'.",
+	)
+	requestHeadersSpec = Flags.String("request-headers", env.Or("REQUEST_HEADERS", ""), "Headers added to every request, like 'X-Tenant=acme,X-Correlation-Id={{uuid}}', "+
+		"or '@' and the path of a file with them, one per line. Values are URL encoded text/templates like -request-body. "+
+		"Defaults to env variable 'REQUEST_HEADERS'.",
+	)
+	spanHeadersSpec = Flags.String("span-headers", env.Or("SPAN_HEADERS", ""), "Names of request headers, separated by commas, recorded on request spans "+
+		"as http.request.header.<name> attributes. Defaults to env variable 'SPAN_HEADERS'.",
+	)
+	scenarioFile = Flags.String("scenario", env.Or("DEMO_SCENARIO", ""), "A YAML or JSON file of steps run in order in one trace, in place of single requests. "+
+		"Defaults to env variable 'DEMO_SCENARIO'.",
+	)
+	loadProfileSpec = Flags.String("load-profile", env.Or("LOAD_PROFILE", ""), "Changes the request rate over time, in place of -rate and -request-interval. "+
+		"'ramp:1-50:5m' raises it from 1 to 50 requests per second over 5 minutes, 'step:10,20,50:1m' sends each rate for a minute "+
+		"and 'spike:5,100:2m:10s' sends 100 requests per second for 10s of every 2 minutes and 5 otherwise. '@' and the path of a file "+
+		"reads the profile from it. Defaults to env variable 'LOAD_PROFILE'.",
+	)
+	fileReloadInterval = Flags.Duration("file-reload-interval", env.Duration("FILE_RELOAD_INTERVAL", 0), "If positive, the -scenario, "+
+		"-load-profile and -server-endpoints-file files are read this often, and those that changed are reloaded, so a mounted "+
+		"ConfigMap can change the traffic of a running client. Defaults to env variable 'FILE_RELOAD_INTERVAL'.",
+	)
+	requestDistribution = Flags.String("request-distribution", env.Or("REQUEST_DISTRIBUTION", "constant"), "How the time between requests varies around the one "+
+		"for the rate: 'constant', 'uniform' for within -request-jitter of it, or 'exponential'. Defaults to env variable 'REQUEST_DISTRIBUTION'.",
+	)
+	requestJitter = Flags.Float64("request-jitter", env.Float("REQUEST_JITTER", 0.2), "With -request-distribution=uniform, the fraction of the time between "+
+		"requests it varies by, between 0 and 1. Defaults to env variable 'REQUEST_JITTER'.",
+	)
+	requestCount = Flags.Int("requests", env.Int("REQUEST_COUNT", 0), "If set, the client exits after sending this many requests, or scenario runs. "+
+		"Defaults to env variable 'REQUEST_COUNT'.",
+	)
+	runDuration = Flags.Duration("duration", env.Duration("RUN_DURATION", 0), "If set, the client exits after sending requests for this long, like '5m'. "+
+		"Defaults to env variable 'RUN_DURATION'.",
+	)
+	maxErrorRate = Flags.Float64("max-error-rate", env.Float("MAX_ERROR_RATE", 1), "The client exits with a non-zero code if the fraction of requests that "+
+		"failed is higher than this, like 0.01. Defaults to env variable 'MAX_ERROR_RATE'.",
+	)
+	trafficModel = Flags.String("traffic-model", env.Or("TRAFFIC_MODEL", "closed"), "'closed' sends requests from -concurrency workers, so a slow server slows "+
+		"the requests down. 'poisson' starts requests at random, independent times at the rate however many are in flight, up to -max-in-flight. "+
+		"Defaults to env variable 'TRAFFIC_MODEL'.",
+	)
+	maxInFlight = Flags.Int("max-in-flight", env.Int("MAX_IN_FLIGHT", 1000), "With -traffic-model=poisson, the most requests sent at once. Requests over this "+
+		"are dropped and counted. Defaults to env variable 'MAX_IN_FLIGHT'.",
+	)
+	replayLog = Flags.String("replay", env.Or("REPLAY_LOG", ""), "An access log, in the Common Log Format or JSON lines, whose requests are sent to "+
+		"the server's host with the same methods, paths and times between them. Defaults to env variable 'REPLAY_LOG'.",
+	)
+	replaySpeed = Flags.Float64("replay-speed", env.Float("REPLAY_SPEED", 1), "With -replay, how many times faster than in the log requests are sent. "+
+		"Defaults to env variable 'REPLAY_SPEED'.",
+	)
+	maxQPS = Flags.Float64("max-qps", env.Float("MAX_QPS", 0), "If set, the most requests sent per second, regardless of -rate and -request-interval. "+
+		"Defaults to env variable 'MAX_QPS'.",
+	)
+)
+
+// Flags related to the global rate limit, shared through Redis by every replica of the client.
+var (
+	redisAddr = Flags.String("redis-addr", env.Or("REDIS_ADDR", ""), "If set, the host:port of a Redis server that the clients "+
+		"share a token bucket through, so together they send at most -global-qps. Defaults to env variable 'REDIS_ADDR'.",
+	)
+	redisKey = Flags.String("redis-key", env.Or("REDIS_KEY", "demo-client:tokens"), "The key of the token bucket in Redis. Clients "+
+		"with the same key share a rate limit. Defaults to env variable 'REDIS_KEY'.",
+	)
+	globalQPS = Flags.Float64("global-qps", env.Float("GLOBAL_QPS", 0), "With -redis-addr, the most requests sent per second by "+
+		"all of the clients together. Defaults to env variable 'GLOBAL_QPS'.",
+	)
+	globalBurst = Flags.Int("global-burst", env.Int("GLOBAL_BURST", 1), "With -redis-addr, the most requests the clients may send "+
+		"at once after sending none for a while. Defaults to env variable 'GLOBAL_BURST'.",
+	)
+)
+
+// Flags related to the HTTP client's connection pool, protocol and proxy.
+var (
+	httpMaxIdleConns = Flags.Int("http-max-idle-conns", env.Int("HTTP_MAX_IDLE_CONNS", 100), "The most idle connections kept open for reuse, "+
+		"across all hosts. 0 means no limit. Defaults to env variable 'HTTP_MAX_IDLE_CONNS'.",
+	)
+	httpMaxIdleConnsPerHost = Flags.Int("http-max-idle-conns-per-host", env.Int("HTTP_MAX_IDLE_CONNS_PER_HOST", http.DefaultMaxIdleConnsPerHost), "The most idle "+
+		"connections kept open for reuse to each host. Defaults to env variable 'HTTP_MAX_IDLE_CONNS_PER_HOST'.",
+	)
+	httpMaxConnsPerHost = Flags.Int("http-max-conns-per-host", env.Int("HTTP_MAX_CONNS_PER_HOST", 0), "The most connections open to each host, requests "+
+		"over this wait for one. 0 means no limit. Defaults to env variable 'HTTP_MAX_CONNS_PER_HOST'.",
+	)
+	httpIdleConnTimeout = Flags.Duration("http-idle-conn-timeout", env.Duration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second), "How long an idle connection "+
+		"is kept open for reuse. 0 means no limit. Defaults to env variable 'HTTP_IDLE_CONN_TIMEOUT'.",
+	)
+	httpVersion = Flags.String("http-version", env.Or("HTTP_VERSION", "auto"), "The HTTP version requests are sent with: 'auto' for HTTP/2 when the server "+
+		"supports it over TLS and HTTP/1.1 otherwise, '1.1', '2' for HTTP/2 over TLS only or 'h2c' for HTTP/2 without TLS. "+
+		"Defaults to env variable 'HTTP_VERSION'.",
+	)
+	proxyURL = Flags.String("proxy", env.Or("PROXY_URL", ""), "The URL of an HTTP, HTTPS or SOCKS5 proxy requests are sent through, like "+
+		"'socks5://proxy:1080'. If not set, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used. "+
+		"Defaults to env variable 'PROXY_URL'.",
+	)
+	noProxy = Flags.String("no-proxy", env.Or("NO_PROXY", ""), "Comma separated hosts, domains and IP ranges requests to which aren't sent "+
+		"through -proxy, like 'internal.example.com,10.0.0.0/8'. Defaults to env variable 'NO_PROXY'.",
+	)
+	httpDisableKeepAlives = Flags.Bool("http-disable-keep-alives", env.Bool("HTTP_DISABLE_KEEP_ALIVES", false), "If true, each request opens a new "+
+		"connection. Defaults to env variable 'HTTP_DISABLE_KEEP_ALIVES'.",
+	)
+)
+
+// Flags related to retrying and hedging requests, and the circuit breaker.
+var (
+	requestTimeout = Flags.Duration("request-timeout", env.Duration("REQUEST_TIMEOUT", 10*time.Second), "How long a request, including its retries, may take "+
+		"before it is cancelled. 0 means no limit. Defaults to env variable 'REQUEST_TIMEOUT'.",
+	)
+	retryMaxAttempts = Flags.Int("retry-max-attempts", env.Int("RETRY_MAX_ATTEMPTS", 1), "The most times a request is sent. 1 turns retries off. "+
+		"Defaults to env variable 'RETRY_MAX_ATTEMPTS'.",
+	)
+	retryInitialBackoff = Flags.Duration("retry-initial-backoff", env.Duration("RETRY_INITIAL_BACKOFF", 100*time.Millisecond), "The wait before the first retry, "+
+		"which doubles for each retry after it. Defaults to env variable 'RETRY_INITIAL_BACKOFF'.",
+	)
+	retryMaxBackoff = Flags.Duration("retry-max-backoff", env.Duration("RETRY_MAX_BACKOFF", 2*time.Second), "The longest wait between retries. "+
+		"Defaults to env variable 'RETRY_MAX_BACKOFF'.",
+	)
+	hedgeDelay = Flags.Duration("hedge-delay", env.Duration("HEDGE_DELAY", 0), "If set, a request without a response after this long is sent again, "+
+		"and the first response used. 0 turns hedging off. Defaults to env variable 'HEDGE_DELAY'.",
+	)
+	breakerFailures = Flags.Int("circuit-breaker-failures", env.Int("CIRCUIT_BREAKER_FAILURES", 0), "If set, the circuit breaker opens after this many "+
+		"requests fail in a row and requests fail without being sent. Defaults to env variable 'CIRCUIT_BREAKER_FAILURES'.",
+	)
+	breakerCooldown = Flags.Duration("circuit-breaker-cooldown", env.Duration("CIRCUIT_BREAKER_COOLDOWN", 10*time.Second), "How long the circuit breaker "+
+		"stays open before letting a request through to see if the server recovered. Defaults to env variable 'CIRCUIT_BREAKER_COOLDOWN'.",
+	)
+	retryOn = Flags.String("retry-on", env.Or("RETRY_ON", "5xx,connection"), "A comma separated list of failures that are retried: '5xx' for responses "+
+		"with a 5xx status and 'connection' for requests that got no response. Defaults to env variable 'RETRY_ON'.",
+	)
+)
+
+// Flags related to validating responses. When any is set, responses without -expect-status must have a
+// status below 400.
+var (
+	expectStatus = Flags.Int("expect-status", env.Int("EXPECT_STATUS", 0), "The status code responses must have. "+
+		"Defaults to env variable 'EXPECT_STATUS'.",
+	)
+	expectBody = Flags.String("expect-body", env.Or("EXPECT_BODY_CONTAINS", ""), "A string response bodies must contain. "+
+		"Defaults to env variable 'EXPECT_BODY_CONTAINS'.",
+	)
+	expectJSON = Flags.String("expect-json", env.Or("EXPECT_JSON", ""), "A path response bodies must have as JSON, like 'items.0.id', "+
+		"or a path and the value it must have, like 'status=ok'. Defaults to env variable 'EXPECT_JSON'.",
+	)
+	expectMaxLatency = Flags.Duration("expect-max-latency", env.Duration("EXPECT_MAX_LATENCY", 0), "The longest a response may take. 0 means no limit. "+
+		"Defaults to env variable 'EXPECT_MAX_LATENCY'.",
+	)
+)
+
+// Flags related to propagation, and the baggage requests carry, the business context propagated with the trace.
+var (
+	traceParent = Flags.String("traceparent", env.Or("TRACEPARENT", ""), "A W3C traceparent, like the one a CI job sets, that requests' "+
+		"traces are children of. Defaults to env variable 'TRACEPARENT'.",
+	)
+	traceState = Flags.String("tracestate", env.Or("TRACESTATE", ""), "The W3C tracestate of -traceparent. "+
+		"Defaults to env variable 'TRACESTATE'.",
+	)
+	idGeneratorName = Flags.String("id-generator", env.Or("ID_GENERATOR", "random"), "How trace and span IDs are generated: 'random', "+
+		"or 'xray' for trace IDs AWS X-Ray accepts. Use it with -propagators=xray. Defaults to env variable 'ID_GENERATOR'.",
+	)
+	baggageMembers = Flags.String("baggage", env.Or("BAGGAGE", ""), "Baggage every request carries, like 'tenant=acme,plan=free'. "+
+		"Defaults to env variable 'BAGGAGE'.",
+	)
+	baggageUsers = Flags.Int("baggage-users", env.Int("BAGGAGE_USERS", 100), "The number of simulated users. Each request carries the "+
+		"user.id of one at random and its session.id in baggage. 0 doesn't add them. Defaults to env variable 'BAGGAGE_USERS'.",
+	)
+	requestOrigin = Flags.String("request-origin", env.Or("REQUEST_ORIGIN", serviceName), "The request.origin every request carries in "+
+		"baggage. Defaults to env variable 'REQUEST_ORIGIN'.",
+	)
+)
+
+// Flags related to exporting traces.
+var (
+	exporterName = Flags.String("exporter", env.Or("OTEL_TRACES_EXPORTER", "otlp"), "A comma separated list of backends spans are exported to, like 'otlp,stdout'. "+
+		"Valid values are: 'otlp' (gRPC unless OTEL_EXPORTER_OTLP_PROTOCOL is http/protobuf), 'otlphttp', 'stdout', 'zipkin' and 'file'. "+
+		"Defaults to env variable 'OTEL_TRACES_EXPORTER'.",
+	)
+	otlpHTTPEndpoint = Flags.String("otlp-http-endpoint", env.Or("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "0.0.0.0:4318"), "The collector's OTLP/HTTP receiver, "+
+		"which -exporter=otlphttp sends spans to, as a host:port or a URL like 'https://otel-collector:4318/v1/traces'. An https URL enables TLS. "+
+		"Defaults to env variable 'OTEL_EXPORTER_OTLP_TRACES_ENDPOINT'.",
+	)
+	zipkinEndpoint = Flags.String("zipkin-endpoint", env.Or("OTEL_EXPORTER_ZIPKIN_ENDPOINT", "http://localhost:9411/api/v2/spans"), "The URL of the Zipkin collector. Defaults to env variable 'OTEL_EXPORTER_ZIPKIN_ENDPOINT'.")
+)
 
-	continuouslySendRequests()
+// Flags related to sampling.
+var (
+	samplingRules = Flags.String("sampling-rules", "", "A YAML file of rules that set the sampling ratio by URL path, like '/healthz' at 0.01. Requests no rule matches use -sampler.")
+	debugTrace    = Flags.Bool("debug-trace", env.Bool("DEBUG_TRACE", false), "If true, every request is sampled regardless of -sampler and carries the 'x-debug-trace: 1' header "+
+		"so the server samples it too. Defaults to env variable 'DEBUG_TRACE'.",
+	)
+	adaptiveSamplerMax = Flags.Float64("adaptive-sampler-max", 1.0, "The ratio -sampler=adaptive rises to when requests are failing.")
+)
+
+// Flags for tail sampling, where traces are buffered in the client and only the interesting ones are exported.
+var (
+	tailSampling             = Flags.Bool("tail-sampling", false, "If true, only export traces that have an error or whose root span is slower than -tail-sampling-latency. Use with -sampler=always_on.")
+	tailSamplingLatency      = Flags.Duration("tail-sampling-latency", 500*time.Millisecond, "Traces whose root span takes longer than this are exported when -tail-sampling is set.")
+	tailSamplingDecisionWait = Flags.Duration("tail-sampling-decision-wait", 30*time.Second, "How long to wait for a trace's root span to end before deciding with the spans received so far.")
+	tailSamplingRatio        = Flags.Float64("tail-sampling-ratio", 0, "The ratio, from 0 to 1, of the traces without an error or a slow root span that -tail-sampling exports too, as a baseline to compare with.")
+	tailSamplingMaxTraces    = Flags.Int("tail-sampling-max-traces", 10000, "The most traces buffered while waiting for a decision. Spans of new traces are dropped after that.")
+)
+
+// dropSpans lists conditions for spans that are never exported.
+var dropSpans = Flags.String("drop-spans", "", "A comma separated list of key=pattern conditions for spans that are dropped before export, "+
+	"like 'http.target=/healthz,name=Probe*'. The key 'name' matches the span name, any other key matches that attribute's value.",
+)
+
+// Flags for the file exporter.
+var (
+	spanFile           = Flags.String("span-file", "spans.jsonl", "The file the 'file' exporter writes spans to, one JSON object per line.")
+	spanFileMaxSize    = Flags.Int("span-file-max-size", 100, "The size in megabytes at which the span file is rotated.")
+	spanFileMaxBackups = Flags.Int("span-file-max-backups", 5, "The number of rotated span files to keep. 0 keeps all of them.")
+)
+
+// Flags that tune the batch span processor. Larger batches and longer delays favor throughput,
+// smaller ones get spans to the backend sooner.
+var (
+	bspScheduleDelay = Flags.Duration("bsp-schedule-delay", env.Millis("OTEL_BSP_SCHEDULE_DELAY", sdktrace.DefaultScheduleDelay*time.Millisecond), "The longest time spans wait in the queue before being exported. "+
+		"Defaults to env variable 'OTEL_BSP_SCHEDULE_DELAY' in milliseconds.",
+	)
+	bspExportTimeout = Flags.Duration("bsp-export-timeout", env.Millis("OTEL_BSP_EXPORT_TIMEOUT", sdktrace.DefaultExportTimeout*time.Millisecond), "How long an export may take before it is cancelled. "+
+		"Defaults to env variable 'OTEL_BSP_EXPORT_TIMEOUT' in milliseconds.",
+	)
+	bspMaxQueueSize = Flags.Int("bsp-max-queue-size", env.Int("OTEL_BSP_MAX_QUEUE_SIZE", sdktrace.DefaultMaxQueueSize), "The number of spans that can be queued for export. Spans are dropped when it is full. "+
+		"Defaults to env variable 'OTEL_BSP_MAX_QUEUE_SIZE'.",
+	)
+	bspMaxExportBatchSize = Flags.Int("bsp-max-export-batch-size", env.Int("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", sdktrace.DefaultMaxExportBatchSize), "The most spans sent in a single export. "+
+		"Defaults to env variable 'OTEL_BSP_MAX_EXPORT_BATCH_SIZE'.",
+	)
+)
+
+// Flags that bound the size of spans, protecting backends from unbounded payloads. Attributes, events and
+// links past a limit are dropped and string values longer than the length limit are truncated.
+var (
+	spanAttributeValueLengthLimit = Flags.Int("span-attribute-value-length-limit", env.Int("OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT", sdktrace.DefaultAttributeValueLengthLimit), "The longest a string attribute value may be "+
+		"before it is truncated. -1 is unlimited. Defaults to env variable 'OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT'.",
+	)
+	spanAttributeCountLimit = Flags.Int("span-attribute-count-limit", env.Int("OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributeCountLimit), "The most attributes a span may have. -1 is unlimited. "+
+		"Defaults to env variable 'OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT'.",
+	)
+	spanEventCountLimit = Flags.Int("span-event-count-limit", env.Int("OTEL_SPAN_EVENT_COUNT_LIMIT", sdktrace.DefaultEventCountLimit), "The most events a span may have. -1 is unlimited. "+
+		"Defaults to env variable 'OTEL_SPAN_EVENT_COUNT_LIMIT'.",
+	)
+	spanLinkCountLimit = Flags.Int("span-link-count-limit", env.Int("OTEL_SPAN_LINK_COUNT_LIMIT", sdktrace.DefaultLinkCountLimit), "The most links a span may have. -1 is unlimited. "+
+		"Defaults to env variable 'OTEL_SPAN_LINK_COUNT_LIMIT'.",
+	)
+	demoAttributeSize = Flags.Int("demo-attribute-size", 0, "If set, each request span gets a 'demo.payload' attribute of this many bytes, "+
+		"to see it truncated by -span-attribute-value-length-limit.",
+	)
+)
+
+// logsExporter is the backend logs are exported to, in addition to stderr.
+var logsExporter = Flags.String("logs-exporter", env.Or("OTEL_LOGS_EXPORTER", "none"), "The backend logs are exported to in addition to stderr. "+
+	"Valid values are: 'otlp', which uses the OTLP gRPC collector at -otlp-endpoint, and 'none'. Defaults to env variable 'OTEL_LOGS_EXPORTER'.",
+)
+
+// Flags related to exporting metrics.
+var (
+	metricsExporter = Flags.String("metrics-exporter", env.Or("OTEL_METRICS_EXPORTER", "otlp"), "The backend metrics are exported to. Valid values are: 'otlp', which uses "+
+		"the OTLP gRPC collector at -otlp-endpoint, 'prometheus', which serves them at /metrics on -metrics-addr, "+
+		"'pushgateway', which pushes them to -pushgateway-url on exit, 'statsd' and 'dogstatsd', which send request metrics to -statsd-addr, and 'none'. "+
+		"Defaults to env variable 'OTEL_METRICS_EXPORTER'.",
+	)
+	latencyBuckets = Flags.String("latency-buckets", "5,10,25,50,100,250,500,1000,2500,5000", "A comma separated list of the bucket boundaries, in milliseconds, "+
+		"of the demo_client/request_latency histogram.",
+	)
+	hostMetrics    = Flags.Bool("host-metrics", false, "If true, the CPU, memory and network usage of the host are exported with the client's metrics.")
+	pushgatewayURL = Flags.String("pushgateway-url", "", "The URL of the Prometheus Pushgateway metrics are pushed to when -metrics-exporter=pushgateway, like 'http://pushgateway:9091'.")
+	statsdAddr     = Flags.String("statsd-addr", "localhost:8125", "The host:port of the StatsD server when -metrics-exporter is 'statsd' or 'dogstatsd'.")
+	metricsAddr    = Flags.String("metrics-addr", ":9464", "The address the /metrics endpoint listens on when -metrics-exporter=prometheus.")
+)
+
+// Flags related to logging.
+var (
+	logSpanEvents = Flags.Bool("log-span-events", env.Bool("LOG_SPAN_EVENTS", false), "If true, warn and error entries logged with a span's "+
+		"context are also added to the span as events. Defaults to env variable 'LOG_SPAN_EVENTS'.",
+	)
+	logFile = Flags.String("log-file", env.Or("LOG_FILE", ""), "If set, log entries are also written as JSON to this file, which is rotated. "+
+		"Defaults to env variable 'LOG_FILE'.",
+	)
+	logFileMaxSize    = Flags.Int("log-file-max-size", env.Int("LOG_FILE_MAX_SIZE", 100), "The size in megabytes at which the log file is rotated. Defaults to env variable 'LOG_FILE_MAX_SIZE'.")
+	logFileMaxAge     = Flags.Int("log-file-max-age", env.Int("LOG_FILE_MAX_AGE", 7), "The number of days to keep rotated log files. 0 keeps them regardless of age. Defaults to env variable 'LOG_FILE_MAX_AGE'.")
+	logFileMaxBackups = Flags.Int("log-file-max-backups", env.Int("LOG_FILE_MAX_BACKUPS", 5), "The number of rotated log files to keep. 0 keeps all of them. Defaults to env variable 'LOG_FILE_MAX_BACKUPS'.")
+	logFileCompress   = Flags.Bool("log-file-compress", env.Bool("LOG_FILE_COMPRESS", false), "If true, rotated log files are gzipped. Defaults to env variable 'LOG_FILE_COMPRESS'.")
+	logSampling       = Flags.Bool("log-sampling", env.Bool("LOG_SAMPLING", false), "If true, repeated log messages are sampled. The number suppressed is "+
+		"reported as the demo_client/logs_suppressed metric. Defaults to env variable 'LOG_SAMPLING'.",
+	)
+	logSamplingInitial    = Flags.Int("log-sampling-initial", 10, "With -log-sampling, the number of entries with the same level and message logged each second before sampling starts.")
+	logSamplingThereafter = Flags.Int("log-sampling-thereafter", 100, "With -log-sampling, after the initial entries only every Nth entry with the same level and message is logged that second.")
+	adminAddr             = Flags.String("admin-addr", env.Or("ADMIN_ADDR", ""), "If set, the address operational endpoints like /log/level are served on, like ':8081'. Defaults to env variable 'ADMIN_ADDR'.")
+	healthMaxRequestAge   = Flags.Duration("health-max-request-age", env.Duration("HEALTH_MAX_REQUEST_AGE", 5*time.Minute), "The admin server's /healthz reports "+
+		"the client unhealthy if no request succeeded for this long. 0 doesn't check requests. Defaults to env variable 'HEALTH_MAX_REQUEST_AGE'.",
+	)
+	livenessMaxDispatchWait = Flags.Duration("livez-max-dispatch-wait", env.Duration("LIVEZ_MAX_DISPATCH_WAIT", time.Minute), "The admin "+
+		"server's /livez reports the client isn't live if the load loop waited this long to hand a request to a worker, because every "+
+		"worker is stuck. 0 doesn't check. Defaults to env variable 'LIVEZ_MAX_DISPATCH_WAIT'.",
+	)
+)
+
+// Flags related to leader election, for when the client runs as several replicas of a Kubernetes Deployment.
+var (
+	leaderElect = Flags.Bool("leader-elect", env.Bool("LEADER_ELECT", false), "If true, replicas of the client compete for a "+
+		"Kubernetes lease and only the leader sends requests, while the others stand by to take over. Defaults to env variable 'LEADER_ELECT'.",
+	)
+	leaderElectLease = Flags.String("leader-elect-lease", env.Or("LEADER_ELECT_LEASE", "demo-client"), "The name of the lease the replicas "+
+		"compete for. Defaults to env variable 'LEADER_ELECT_LEASE'.",
+	)
+	leaderElectNamespace = Flags.String("leader-elect-namespace", env.Or("LEADER_ELECT_NAMESPACE", ""), "The namespace of the lease, "+
+		"the pod's by default. Defaults to env variable 'LEADER_ELECT_NAMESPACE'.",
+	)
+	leaderElectLeaseDuration = Flags.Duration("leader-elect-lease-duration", env.Duration("LEADER_ELECT_LEASE_DURATION", 15*time.Second),
+		"How long a leader that stops renewing the lease keeps it, before a replica standing by takes over. "+
+			"Defaults to env variable 'LEADER_ELECT_LEASE_DURATION'.",
+	)
+)
+
+// Flags related to the Kubernetes API workload, which traces client-go's calls in place of requests to the server.
+var (
+	kubeAPI = Flags.Bool("kube-api", env.Bool("KUBE_API", false), "If true, requests list the pods in -kube-namespace through the "+
+		"Kubernetes API, with a span for each call client-go makes, in place of HTTP requests to -server-endpoint. Defaults to env variable 'KUBE_API'.",
+	)
+	kubeNamespace = Flags.String("kube-namespace", env.Or("KUBE_NAMESPACE", ""), "The namespace whose pods -kube-api lists, every "+
+		"namespace by default. Defaults to env variable 'KUBE_NAMESPACE'.",
+	)
+	kubeLabelSelector = Flags.String("kube-label-selector", env.Or("KUBE_LABEL_SELECTOR", ""), "The label selector of the pods -kube-api "+
+		"lists, like 'app=demo-server'. Defaults to env variable 'KUBE_LABEL_SELECTOR'.",
+	)
+	kubeWatch = Flags.Duration("kube-watch", env.Duration("KUBE_WATCH", 0), "If positive, each -kube-api request also watches the pods "+
+		"for this long after listing them, with a span event for each change. Defaults to env variable 'KUBE_WATCH'.",
+	)
+	kubeconfig = Flags.String("kubeconfig", env.Or("KUBECONFIG", ""), "The kubeconfig file -kube-api uses outside Kubernetes. In a pod, "+
+		"its service account is used. Defaults to env variable 'KUBECONFIG'.",
+	)
+)
+
+// Flags related to the terminal dashboard.
+var (
+	tui = Flags.Bool("tui", env.Bool("TUI", false), "If true, a live dashboard of the request rate, error rate and latency, the export "+
+		"queues and links to the last traces is shown in place of the log on stderr. Defaults to env variable 'TUI'.",
+	)
+	traceURL = Flags.String("trace-url", env.Or("TRACE_URL", "http://localhost:16686/trace/{traceID}"), "The URL of a trace in the tracing UI, "+
+		"with {traceID} replaced by its ID, that the dashboard links traces to. Defaults to env variable 'TRACE_URL'.",
+	)
+)
+
+// logger is the structured logger used for operational messages. It is replaced in Main.
+var logger = zap.NewNop()
+
+// Main sets up the trace providers and starts a loop to continuously call the server. Flags and
+// telemetryflags.FlagSet must be parsed first.
+func Main() {
+	if err := Validate(); err != nil {
+		// Every problem is reported at once, before the logger, which may be what is misconfigured.
+		validate.Print(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	l, err := newLogger()
+	if err != nil {
+		// There is no logger to report this with yet.
+		fmt.Fprintf(os.Stderr, "failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+	logger = l
+	defer logger.Sync()
+
+	if err := run(); err != nil {
+		// run has closed what it set up and flushed the telemetry by now.
+		logger.Fatal("client failed", zap.Error(err))
+	}
 }
 
-// initTraceProvider initializes an OTLP exporter, and configures the corresponding trace provider.
-func initTraceProvider() func() {
-	ctx := context.Background()
+// run sets up the trace providers and calls the server until the client is stopped. An error setting up
+// is returned after what was set up before it is closed again, so spans are flushed either way.
+func run() error {
+	if *tui {
+		dashboard = newTerminalDashboard()
+	}
+	setDefaultSlog()
+	registerLogMetrics()
+
+	interval, err := loadInterval(*requestInterval, *requestRate, *maxQPS)
+	if err != nil {
+		return fmt.Errorf("invalid request rate: %w", err)
+	}
+	profile, err := parseLoadProfile(*loadProfileSpec)
+	if err != nil {
+		return fmt.Errorf("invalid load profile: %w", err)
+	}
+	pace := pacer{
+		interval:     interval,
+		profile:      profile,
+		maxQPS:       *maxQPS,
+		distribution: strings.ToLower(*requestDistribution),
+		jitter:       *requestJitter,
+	}
+	model := strings.ToLower(*trafficModel)
+	switch model {
+	case "closed":
+	case "poisson":
+		// Exponential times between arrivals are what makes them a Poisson process.
+		pace.distribution = "exponential"
+	default:
+		return fmt.Errorf("-traffic-model=%s is not a valid value", *trafficModel)
+	}
+	if err := pace.validate(); err != nil {
+		return fmt.Errorf("invalid request distribution: %w", err)
+	}
+	targets, err := targetsFromFlags()
+	if err != nil {
+		return fmt.Errorf("invalid server endpoints: %w", err)
+	}
+	if err := initRequestBody(); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	if err := initParentContext(); err != nil {
+		return fmt.Errorf("invalid parent trace: %w", err)
+	}
+	if err := initBaggage(); err != nil {
+		return fmt.Errorf("invalid baggage: %w", err)
+	}
+	if err := initRequestHeaders(); err != nil {
+		return fmt.Errorf("invalid request headers: %w", err)
+	}
+	if expectation, err = expectFromFlags(); err != nil {
+		return fmt.Errorf("invalid response validation: %w", err)
+	}
+	if retries, err = retryPolicyFromFlags(); err != nil {
+		return fmt.Errorf("invalid retry policy: %w", err)
+	}
+	if httpClient, err = newHTTPClient(); err != nil {
+		return fmt.Errorf("invalid HTTP client settings: %w", err)
+	}
+	if *breakerFailures > 0 {
+		breaker = newCircuitBreaker(*breakerFailures, *breakerCooldown)
+	}
+	if *redisAddr != "" {
+		rdb := newRedisClient(*redisAddr)
+		defer rdb.Close()
+		globalLimiter = newRedisTokenBucket(rdb, *redisKey, *globalQPS, *globalBurst)
+	}
+	if *kubeAPI {
+		if kubeWorkload, err = newKubeClient(); err != nil {
+			return fmt.Errorf("failed to create the Kubernetes client: %w", err)
+		}
+	}
+	if useGRPC() {
+		conn, err := dialGreeter(*grpcEndpoint)
+		if err != nil {
+			return fmt.Errorf("failed to dial the gRPC endpoint: %w", err)
+		}
+		defer conn.Close()
+		greeter = hello.NewGreeterClient(conn)
+	}
+	var replay io.Reader
+	if *replayLog != "" {
+		f, err := os.Open(*replayLog)
+		if err != nil {
+			return fmt.Errorf("failed to open access log: %w", err)
+		}
+		defer f.Close()
+		replay = f
+	}
+	var scenario *Scenario
+	if *scenarioFile != "" {
+		if scenario, err = LoadScenario(*scenarioFile); err != nil {
+			return fmt.Errorf("invalid scenario: %w", err)
+		}
+	}
+
+	// Errors from exporting, like a lost connection to the collector, are reported here.
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error("OpenTelemetry error", zap.Error(err))
+	}))
+
+	// ctx is cancelled on SIGINT or SIGTERM, which stops the request loop so run can flush spans and return.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go toggleDebugOnSignal(ctx)
 
-	otelAgentAddr, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if !ok {
-		otelAgentAddr = "0.0.0.0:4317"
+	closeAdmin, err := startAdminServer()
+	if err != nil {
+		return fmt.Errorf("failed to start admin server: %w", err)
+	}
+	defer closeAdmin(context.Background())
+
+	shutdown, err := initTelemetry(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize telemetry: %w", err)
+	}
+	defer shutdown()
+
+	instruments, err := NewClientInstruments(global.Meter(meterName))
+	if err != nil {
+		return fmt.Errorf("failed to create request metrics: %w", err)
+	}
+	if dashboard != nil {
+		// Deferred after shutdown, so the terminal is restored first.
+		closeDashboard := dashboard.start(stop)
+		defer closeDashboard()
+	}
+	if *leaderElect {
+		// The lease is released when the client stops, so a replica standing by takes over right away.
+		electionCtx, cancelElection := context.WithCancel(ctx)
+		defer cancelElection()
+		if elector, err = startLeaderElection(electionCtx); err != nil {
+			return fmt.Errorf("failed to start leader election: %w", err)
+		}
+	}
+	if *runDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *runDuration)
+		defer cancel()
+	}
+	liveTargets = newTargetPicker(targets)
+	if scenario != nil {
+		liveScenario = newSwappableScenario(scenario)
+	}
+	if *fileReloadInterval > 0 {
+		go watchFiles(ctx, *fileReloadInterval)
 	}
+	stats := continuouslySendRequests(ctx, logger, instruments, load{
+		targets:     liveTargets,
+		scenario:    liveScenario,
+		pace:        pace,
+		requests:    *requestCount,
+		openLoop:    model == "poisson",
+		maxInFlight: *maxInFlight,
+		replay:      replay,
+		replaySpeed: *replaySpeed,
+	})
+	logger.Info("shutting down, flushing spans and metrics", zap.Duration("timeout", *shutdownTimeout))
+	if rate := stats.errorRate(); rate > *maxErrorRate {
+		return fmt.Errorf("error rate %g exceeded -max-error-rate %g", rate, *maxErrorRate)
+	}
+	return nil
+}
 
-	closeTraces := initTracer(ctx, otelAgentAddr)
+// initTelemetry initializes the exporters selected by flags, and configures the corresponding trace and
+// metric providers and log exporter. The signals share a resource, so the traces, metrics and logs from
+// a client are tied together. With -sdk-disabled, no-op providers are installed instead, and with
+// -otel-env-only, ones set up by the standard environment variables.
+func initTelemetry(ctx context.Context) (func(), error) {
+	if *sdkDisabled {
+		return initNoopTelemetry()
+	}
+	if *otelEnvOnly {
+		return initEnvTelemetry(ctx)
+	}
+	exporters, err := exportersFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	res, err := telemetryflags.Resource(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	closeTraces, err := initTracer(ctx, res, exporters)
+	if err != nil {
+		return nil, err
+	}
+	closeMetrics, err := initMetrics(ctx, res)
+	if err != nil {
+		closeTraces(ctx)
+		return nil, err
+	}
+	if err := startHostMetrics(); err != nil {
+		closeTraces(ctx)
+		closeMetrics(ctx)
+		return nil, err
+	}
+	closeLogs, err := initLogs(ctx, res)
+	if err != nil {
+		closeTraces(ctx)
+		closeMetrics(ctx)
+		return nil, err
+	}
 
 	return func() {
-		doneCtx, cancel := context.WithTimeout(ctx, time.Second)
+		// ctx may already be cancelled by a signal, so shutdown gets its own deadline.
+		doneCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
 		defer cancel()
 		// pushes any last exports to the receiver
 		closeTraces(doneCtx)
+		closeMetrics(doneCtx)
+		// Logs are last, so messages about shutting down the others are exported.
+		closeLogs(doneCtx)
+	}, nil
+}
+
+// initNoopTelemetry installs no-op tracer and meter providers, so requests are sent without the overhead
+// of recording telemetry. Trace context and baggage are still propagated in the -propagators formats.
+func initNoopTelemetry() (func(), error) {
+	prop, err := propagators.New(*propagatorNames)
+	if err != nil {
+		return nil, fmt.Errorf("-propagators=%s is not a valid value: %w", *propagatorNames, err)
 	}
+	otel.SetTextMapPropagator(prop)
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	global.SetMeterProvider(metric.NewNoopMeterProvider())
+	logger.Info("telemetry is disabled, spans and metrics aren't recorded")
+	return func() {}, nil
 }
 
-// initTracer initializes an OTLP trace exporter and registers the trace provider with the global context
-func initTracer(ctx context.Context, otelAgentAddr string) func(context.Context) {
-	traceClient := otlptracegrpc.NewClient(
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(otelAgentAddr),
-		otlptracegrpc.WithDialOption(grpc.WithBlock()))
-	traceExp, err := otlptrace.New(ctx, traceClient)
-	handleErr(err, "Failed to create the collector trace exporter")
-
-	res, err := resource.New(ctx,
-		resource.WithFromEnv(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-		resource.WithHost(),
-		resource.WithAttributes(
-			// the service name used to display traces in backends
-			semconv.ServiceNameKey.String("demo-client"),
-		),
+// initEnvTelemetry installs tracer and meter providers set up by the standard OTEL_* environment variables
+// alone, see otelenv. The client's own additions, like tail sampling, the log exporter and the traces of
+// the -tui dashboard, aren't set up.
+func initEnvTelemetry(ctx context.Context) (func(), error) {
+	c, err := otelenv.Load(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	closeTelemetry, err := otelenv.Setup(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info(
+		"telemetry is set up by the OTEL_* environment variables",
+		zap.String("traces_exporter", c.TracesExporter),
+		zap.String("metrics_exporter", c.MetricsExporter),
+		zap.String("sampler", c.Sampler),
 	)
-	handleErr(err, "failed to create resource")
+	return func() {
+		doneCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		closeTelemetry(doneCtx)
+	}, nil
+}
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExp)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+// initTracer initializes a trace exporter for each of exporters and registers the trace provider with the global context.
+// Every exporter gets its own batch span processor, so all spans are sent to each of them and a slow
+// backend doesn't hold up the others.
+func initTracer(ctx context.Context, res *resource.Resource, exporters []Exporter) (func(context.Context), error) {
+	prop, err := propagators.New(*propagatorNames)
+	if err != nil {
+		return nil, fmt.Errorf("-propagators=%s is not a valid value: %w", *propagatorNames, err)
+	}
+	bspOpts, err := batchOptions()
+	if err != nil {
+		return nil, err
+	}
+	filters, err := parseSpanFilters(*dropSpans)
+	if err != nil {
+		return nil, err
+	}
+	traceSampler, err := samplerFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	limits, err := spanLimits()
+	if err != nil {
+		return nil, err
+	}
+	var idGenerator sdktrace.IDGenerator
+	switch strings.ToLower(*idGeneratorName) {
+	case "random":
+	case "xray":
+		// X-Ray only accepts trace IDs that start with the time they were created.
+		idGenerator = xray.NewIDGenerator()
+	default:
+		return nil, fmt.Errorf("-id-generator=%s is not a valid value", *idGeneratorName)
+	}
+
+	var traceExps []sdktrace.SpanExporter
+	for _, e := range exporters {
+		traceExp, err := newExporter(ctx, e)
+		if err != nil {
+			for _, created := range traceExps {
+				created.Shutdown(ctx)
+			}
+			return nil, fmt.Errorf("failed to create the %s trace exporter: %w", e.Name(), err)
+		}
+		traceExps = append(traceExps, traceExp)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(traceSampler),
 		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
+		sdktrace.WithRawSpanLimits(limits),
+	}
+	if idGenerator != nil {
+		opts = append(opts, sdktrace.WithIDGenerator(idGenerator))
+	}
+	var processors []sdktrace.SpanProcessor
+	for i, traceExp := range traceExps {
+		processors = append(processors, newTrackedBatchSpanProcessor(exporters[i].Name(), traceExp, bspOpts...))
+	}
+	if *tailSampling {
+		if *tailSamplingDecisionWait <= 0 || *tailSamplingMaxTraces < 1 {
+			return nil, fmt.Errorf("-tail-sampling-decision-wait and -tail-sampling-max-traces must be positive")
+		}
+		processors = []sdktrace.SpanProcessor{
+			newTailSampler(processors, *tailSamplingLatency, *tailSamplingDecisionWait, *tailSamplingRatio, *tailSamplingMaxTraces),
+		}
+	}
+	// Filtering comes first so dropped spans aren't held by the tail sampler.
+	if len(filters) > 0 {
+		processors = []sdktrace.SpanProcessor{&filterProcessor{next: processors, filters: filters}}
+	}
+	if dashboard != nil {
+		processors = append(processors, dashboard.traces)
+	}
+	for _, sp := range processors {
+		opts = append(opts, sdktrace.WithSpanProcessor(sp))
+	}
+	tracerProvider := sdktrace.NewTracerProvider(opts...)
 
-	// set global propagator to tracecontext (the default is no-op).
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// set the global propagator to the -propagators formats (the default is no-op).
+	otel.SetTextMapPropagator(prop)
 	otel.SetTracerProvider(tracerProvider)
 
 	return func(doneCtx context.Context) {
-		if err := traceExp.Shutdown(doneCtx); err != nil {
+		// Flush first so that spans still queued in the batch span processors are exported, then shut down
+		// the provider which shuts down each exporter.
+		if err := tracerProvider.ForceFlush(doneCtx); err != nil {
+			otel.Handle(err)
+		}
+		if err := tracerProvider.Shutdown(doneCtx); err != nil {
 			otel.Handle(err)
 		}
+		if remoteSampler != nil {
+			remoteSampler.Close()
+		}
+	}, nil
+}
+
+// load is what continuouslySendRequests sends.
+type load struct {
+	// targets picks the server each request is sent to.
+	targets *targetPicker
+	// scenario, if set, is run in place of single requests.
+	scenario *swappableScenario
+	// pace sets the rate requests are started at.
+	pace pacer
+	// requests, if set, is the number of requests sent before stopping.
+	requests int
+	// openLoop starts each request on schedule in its own goroutine, instead of waiting for one of
+	// -concurrency workers to be free. At most maxInFlight requests are sent at once.
+	openLoop    bool
+	maxInFlight int
+	// replay, if set, is an access log whose requests are sent, at the times between them divided by
+	// replaySpeed, in place of the pace.
+	replay      io.Reader
+	replaySpeed float64
+}
+
+// continuouslySendRequests continuously sends requests to the server, starting them at the rate set by
+// l.pace. If l.scenario isn't nil, its steps are run instead of a single request. With l.replay, the
+// requests in the access log are sent instead. It returns when ctx is cancelled, l.requests have been
+// sent or the access log ends, after the requests in flight have finished, logging and returning the
+// totals.
+//
+// Requests are sent from -concurrency workers. When every worker is busy, the next request waits for one
+// to finish, so the rate is also limited by the server's latency. With l.openLoop, requests are started
+// on schedule however many are in flight, so a slow server doesn't hide its latency by slowing the
+// requests down. Requests that would be more than l.maxInFlight are counted as dropped.
+func continuouslySendRequests(ctx context.Context, log *zap.Logger, instruments ClientInstruments, l load) *loadStats {
+	tracer := otel.Tracer("demo-client-tracer")
+	stats := newLoadStats()
+	if l.scenario != nil {
+		// Deferred first so the run's span ends after the requests in flight have finished.
+		endRun := l.scenario.get().startRun(tracer)
+		defer endRun(stats)
+	}
+	// send sends a request, or runs the scenario, and records the result. With -replay, entry is the
+	// access log entry to send.
+	send := func(log *zap.Logger, entry *accessLogEntry) {
+		start := time.Now()
+		target := l.targets.next()
+		var err error
+		switch {
+		case entry != nil:
+			err = sendReplayed(tracer, log, instruments, target, entry)
+		case l.scenario != nil:
+			err = l.scenario.get().run(tracer, log, instruments, target)
+		case kubeWorkload != nil:
+			err = sendKubeRequest(tracer, log, instruments)
+		case greeter != nil:
+			err = sendRequest(tracer, log, instruments, request{url: *grpcEndpoint, grpc: true})
+		default:
+			r := request{method: *requestMethod, url: target.URL, body: bodyTemplate}
+			if expectation != nil {
+				r.check = expectation.check
+			}
+			err = sendRequest(tracer, log, instruments, r)
+		}
+		stats.record(time.Since(start), err != nil)
+		if dashboard != nil {
+			dashboard.stats.record(time.Now(), time.Since(start), err != nil)
+		}
+		clientHealth.recordRequest(err != nil)
+	}
+
+	var wg sync.WaitGroup
+	ticks := make(chan *accessLogEntry)
+	workers := *concurrency
+	if l.openLoop {
+		workers = 0
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			workerLog := log.With(zap.Int("worker", worker))
+			for entry := range ticks {
+				send(workerLog, entry)
+			}
+		}(i)
+	}
+	inFlight := make(chan struct{}, l.maxInFlight)
+	dispatch := func(entry *accessLogEntry) bool {
+		// Only the -leader-elect leader sends requests.
+		if elector != nil && !elector.wait(ctx) {
+			return false
+		}
+		// With -redis-addr, the clients together send at most -global-qps.
+		if globalLimiter != nil && globalLimiter.take(ctx) != nil {
+			return false
+		}
+		defer clientHealth.recordDispatch(time.Now())()
+		if !l.openLoop {
+			select {
+			case <-ctx.Done():
+				return false
+			case ticks <- entry:
+			}
+			return true
+		}
+		select {
+		case inFlight <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				send(log, entry)
+			}()
+		default:
+			stats.drop()
+		}
+		return true
+	}
+	defer func() {
+		close(ticks)
+		wg.Wait()
+		stats.log(log)
+	}()
+
+	start := time.Now()
+	next := start
+	// The timer has always fired when it is Reset.
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+	if l.replay != nil {
+		if err := replayAccessLog(ctx, log, l.replay, l.replaySpeed, l.requests, dispatch); err != nil {
+			log.Error("failed to read access log", zap.Error(err))
+		}
+		return stats
 	}
+
+	for sent := 0; l.requests == 0 || sent < l.requests; {
+		select {
+		case c := <-rateChanges:
+			l.pace.interval, l.pace.maxQPS, l.pace.profile = c.interval, c.maxQPS, c.profile
+			if c.restart {
+				start = time.Now()
+			}
+		default:
+		}
+		ok, wait := l.pace.next(time.Since(start))
+		if ok {
+			if !dispatch(nil) {
+				return stats
+			}
+			sent++
+		}
+
+		// Requests are started at the pacer's rate, but time lost waiting for a worker isn't made up
+		// with a burst of requests.
+		next = next.Add(wait)
+		if now := time.Now(); next.Before(now) {
+			next = now
+		}
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return stats
+		case <-timer.C:
+		}
+	}
+	return stats
 }
 
-// handleErr provides a simple way to handle errors and messages
-func handleErr(err error, message string) {
+// sendReplayed sends the request of an access log entry to target's host in a new trace.
+func sendReplayed(tracer trace.Tracer, log *zap.Logger, instruments ClientInstruments, target Target, entry *accessLogEntry) error {
+	u, err := stepURL(target.URL, entry.Path)
 	if err != nil {
-		log.Fatalf("%s: %v", message, err)
+		return err
 	}
+	return sendRequest(tracer, log, instruments, request{method: entry.Method, url: u})
 }
 
-// continuouslySendRequests continuously sends requests to the server sleeping for a second after each request.
-func continuouslySendRequests() {
-	tracer := otel.Tracer("demo-client-tracer")
-
-	for {
-		ctx, span := tracer.Start(context.Background(), "ExecuteRequest")
-		makeRequest(ctx)
+// sendRequest sends r in a new trace. A failed request is recorded on its span and
+// logged, and the error returned.
+func sendRequest(tracer trace.Tracer, log *zap.Logger, instruments ClientInstruments, r request) error {
+	// Requests don't use the loop's ctx, so one in flight when a signal arrives completes and its span
+	// is exported.
+	reqCtx := withBaggage(parentCtx)
+	if *debugTrace {
+		reqCtx = forcesample.With(reqCtx)
+	}
+	// The URL is set when the span starts so samplers can make decisions by endpoint.
+	target := semconv.HTTPURLKey.String(r.url)
+	if r.grpc {
+		target = semconv.RPCSystemKey.String("grpc")
+	}
+	reqCtx, span := tracer.Start(
+		reqCtx,
+		"ExecuteRequest",
+		trace.WithAttributes(
+			target,
+			attribute.String("demo.target", r.url),
+		),
+		trace.WithAttributes(baggageAttributes(reqCtx)...),
+		trace.WithAttributes(propagators.MeshAttributes(reqCtx, nil)...),
+	)
+	defer span.End()
+	if *demoAttributeSize > 0 {
+		span.SetAttributes(attribute.String("demo.payload", strings.Repeat("x", *demoAttributeSize)))
+	}
+	err := makeRequest(reqCtx, log.With(Ctx(reqCtx)), instruments, r)
+	if err != nil {
+		// A failed request is recorded and the loop continues, so a server outage doesn't stop the demo.
+		recordRequestError(reqCtx, log, r.url, err)
+	} else {
 		SuccessfullyFinishedRequestEvent(span)
-		span.End()
-		time.Sleep(time.Duration(1) * time.Second)
 	}
+	if adaptiveSampler != nil {
+		adaptiveSampler.Record(err != nil)
+	}
+	return err
+}
+
+// request is a request sent by makeRequest.
+type request struct {
+	method string
+	url    string
+	// grpc is whether the request is a call to the Greeter at url rather than an HTTP request. Replayed
+	// and scenario requests are HTTP requests even with -protocol=grpc.
+	grpc bool
+	// body is rendered as the body of the request. If nil, the request has no body.
+	body *template.Template
+	// check, if set, is given the response's status, body and latency and returns an error if they aren't
+	// what was expected.
+	check func(status int, body []byte, latency time.Duration) error
+}
+
+// recordRequestError records err from a request to url on the span in ctx as an exception event, with the exception.type,
+// exception.message and exception.stacktrace attributes, and sets the span's status to error. err is
+// logged with the same type, and the logger adds the stack trace to error entries.
+func recordRequestError(ctx context.Context, log *zap.Logger, url string, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err, trace.WithStackTrace(true))
+	if errors.Is(err, context.DeadlineExceeded) {
+		// A fixed description lets trace backends group the requests that timed out.
+		span.SetStatus(codes.Error, "deadline_exceeded")
+	} else {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	log.Error(
+		"request failed",
+		Ctx(ctx),
+		zap.String("url", url),
+		zap.String("error_type", fmt.Sprintf("%T", err)),
+		zap.Error(err),
+	)
 }
 
 // makeRequest sends requests to the server using an OTEL HTTP transport which will instrument the requests with traces.
-func makeRequest(ctx context.Context) {
+// The rate, errors and duration of requests are recorded by the target host and HTTP status class, and
+// failures are also counted by status code and endpoint. A response r.check rejects is returned as an error.
+// Failed requests are sent again as set by the retry policy. While the circuit breaker is open, requests
+// fail without being sent. Requests, with their retries, that take longer than -request-timeout are
+// cancelled and return context.DeadlineExceeded. With r.grpc, the request is a call to the Greeter
+// instead, see callWithRetries, with the same timeout, retries and circuit breaker.
+func makeRequest(ctx context.Context, log *zap.Logger, instruments ClientInstruments, r request) error {
+	url := r.url
+	body, err := renderBody(r.body)
+	if err != nil {
+		return err
+	}
+	// Headers are rendered once, so retries send the same values, like correlation IDs.
+	header, err := renderHeaders(requestHeaders)
+	if err != nil {
+		return err
+	}
+	span := trace.SpanFromContext(ctx)
+	if !r.grpc {
+		span.SetAttributes(semconv.HTTPRequestContentLengthKey.Int(len(body)))
+	}
+	span.SetAttributes(headerAttributes(header, spanHeaders)...)
 
-	demoServerAddr, ok := os.LookupEnv("DEMO_SERVER_ENDPOINT")
-	if !ok {
-		demoServerAddr = "http://0.0.0.0:7080/hello"
+	if breaker != nil {
+		if err := breaker.allow(ctx); err != nil {
+			return err
+		}
+	}
+	if *requestTimeout > 0 {
+		// Cancelling ctx cancels the request in flight, and the retries.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *requestTimeout)
+		defer cancel()
+	}
+	if r.grpc {
+		// gRPC calls have the same timeout, retries and circuit breaker as HTTP requests.
+		err := callWithRetries(ctx, log, instruments, r, header)
+		if breaker != nil {
+			breaker.record(ctx, err != nil)
+		}
+		return err
+	}
+	res, latency, err := sendWithRetries(ctx, log, instruments, r, header, body)
+	if breaker != nil {
+		breaker.record(ctx, err != nil || res.StatusCode >= 500)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Trace an HTTP client by wrapping the transport
-	client := http.Client{
-		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	// Reading the whole body lets the connection be reused and gives the response's size.
+	var size int64
+	var resBody []byte
+	if r.check != nil {
+		resBody, err = io.ReadAll(res.Body)
+		size = int64(len(resBody))
+	} else {
+		size, err = io.Copy(io.Discard, res.Body)
+	}
+	res.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int64(size))
+	// Behind a sidecar, the response says how much of the latency was the server's.
+	span.SetAttributes(propagators.MeshAttributes(ctx, res.Header)...)
+	if r.check != nil {
+		if err := r.check(res.StatusCode, resBody, latency); err != nil {
+			// The error is the span's status, so the trace backend shows why the response was rejected.
+			return fmt.Errorf("response validation failed: %w", err)
+		}
 	}
+	log.Debug(
+		"request finished",
+		zap.String("url", url),
+		zap.Int("status", res.StatusCode),
+		zap.Duration("latency", latency),
+		zap.Int64("response_size", size),
+	)
+	return nil
+}
+
+// sendWithRetries sends r with header and body until it succeeds or the retry policy gives up, returning the last
+// attempt's response and latency.
+func sendWithRetries(ctx context.Context, log *zap.Logger, instruments ClientInstruments, r request, header http.Header, body []byte) (*http.Response, time.Duration, error) {
+	// When requests are retried, each attempt has its own span with its number in the http.retry_count
+	// attribute, linked to the attempt before it.
+	attempts := spanlink.NewChain(spanlink.Retry)
+	for attempt := 0; ; attempt++ {
+		attemptCtx := ctx
+		var span trace.Span
+		if retries.maxAttempts > 1 {
+			attemptCtx, span = attempts.Start(
+				ctx,
+				otel.Tracer("demo-client-tracer"),
+				"Attempt",
+				trace.WithAttributes(attribute.Int("http.retry_count", attempt)),
+			)
+		}
+		res, latency, err := sendHedged(attemptCtx, instruments, r, header, body)
+		if span != nil {
+			if err != nil || res.StatusCode >= 500 {
+				span.SetStatus(codes.Error, fmt.Sprintf("attempt %d failed", attempt))
+			}
+			span.End()
+		}
+		if attempt+1 >= retries.maxAttempts || !retries.shouldRetry(res, err) {
+			return res, latency, err
+		}
+		if err == nil {
+			// The connection can only be reused if the body is read.
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		wait := retries.backoff(attempt)
+		log.Debug(
+			"retrying request",
+			zap.String("url", r.url),
+			zap.Int("attempt", attempt+1),
+			zap.Int("status", responseStatus(res, err)),
+			zap.Duration("retry_in", wait),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
 
+// sendAttempt sends r with header and body once and records its metrics.
+func sendAttempt(ctx context.Context, instruments ClientInstruments, r request, header http.Header, body []byte) (*http.Response, time.Duration, error) {
+	url := r.url
 	// Make sure we pass the context to the request to avoid broken traces.
-	req, err := http.NewRequestWithContext(ctx, "GET", demoServerAddr, nil)
+	req, err := http.NewRequestWithContext(withClientTrace(ctx, instruments), r.method, url, bytes.NewReader(body))
 	if err != nil {
-		handleErr(err, "failed to http request")
+		return nil, 0, fmt.Errorf("failed to create http request: %w", err)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", *requestContentType)
+	}
+	// Configured headers can replace the Content-Type.
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if forcesample.IsForced(ctx) {
+		req.Header.Set(forcesample.Header, "1")
+	}
+	// With -request-timeout, the server gets the budget that is left and records it like this span.
+	deadline.Inject(ctx, req.Header)
+	trace.SpanFromContext(ctx).SetAttributes(deadline.Remaining(ctx)...)
 
-	// All requests made with this client will create spans.
+	// All requests made with this client will create spans, and are recorded in the RED metrics with
+	// their http.host and http.status_class.
+	client := *httpClient
+	client.Transport = instruments.RED.Transport(client.Transport)
+	start := time.Now()
 	res, err := client.Do(req)
+	latency := time.Since(start)
+	if errors.Is(err, context.Canceled) {
+		// A hedged request cancelled because the other got a response first didn't fail.
+		return nil, latency, err
+	}
+	// Requests that never got a response have a status class of "error", like in the RED metrics.
+	class := "error"
+	if err == nil {
+		class = redmetrics.StatusClass(res.StatusCode)
+		// The protocol negotiated with the server, like 2.0 when HTTP/2 is used.
+		trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPFlavorKey.String(fmt.Sprintf("%d.%d", res.ProtoMajor, res.ProtoMinor)))
+	}
+	failed := err != nil || res.StatusCode >= 500
+	// Metrics aren't sampled, so failures are counted even when their spans are dropped.
+	// A status code of 0 means no response was received.
+	if status := responseStatus(res, err); status == 0 || status >= 400 {
+		instruments.ErrorsByStatus.Add(
+			ctx,
+			1,
+			attribute.Int("http.status_code", status),
+			attribute.String("http.url", url),
+		)
+	}
+	recordLatencyExemplar(ctx, float64(latency)/float64(time.Millisecond), req.URL.Host, class)
+	recordStatsd(req.URL.Host, class, latency, failed)
+	return res, latency, err
+}
+
+// responseStatus returns the status code of res, or 0 if the request failed before there was a response.
+func responseStatus(res *http.Response, err error) int {
 	if err != nil {
-		panic(err)
+		return 0
 	}
-	res.Body.Close()
+	return res.StatusCode
 }
 
 // SuccessfullyFinishedRequestEvent adds an event to the span which is analogous with a log statement, but is included
@@ -143,6 +1279,7 @@ func SuccessfullyFinishedRequestEvent(span trace.Span, opts ...trace.EventOption
 }
 
 // WithCorrelation adds span and trace IDs to a zap logger to enable better correlation between traces and logs.
+// Loggers created by newLogger do this for entries with a Ctx field, which only needs the context.
 func WithCorrelation(span trace.Span, log *zap.Logger) *zap.Logger {
 	return log.With(
 		zap.String("span_id", convertTraceID(span.SpanContext().SpanID().String())),
@@ -0,0 +1,370 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/promexport"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/redmetrics"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// meterName is the name of the meter the client's instruments are created with.
+const meterName = "demo-client-meter"
+
+// initMetrics sets up the metrics exporter selected by -metrics-exporter and registers the meter provider
+// with the global context. With -dry-run, metrics aren't exported.
+func initMetrics(ctx context.Context, res *resource.Resource) (func(context.Context), error) {
+	if *dryRun {
+		return func(context.Context) {}, nil
+	}
+	switch strings.ToLower(*metricsExporter) {
+	case "none":
+		return func(context.Context) {}, nil
+	case "otlp":
+		return initOTLPMetrics(ctx, res)
+	case "prometheus":
+		return initPrometheusMetrics(res)
+	case "pushgateway":
+		return initPushgatewayMetrics(res)
+	case "statsd", "dogstatsd":
+		return initStatsdMetrics()
+	}
+	return nil, fmt.Errorf("-metrics-exporter=%s is not a valid value", *metricsExporter)
+}
+
+// startHostMetrics reports the CPU, memory and network usage of the host the client runs on, so the
+// health of the node generating load can be seen next to its traces.
+func startHostMetrics() error {
+	if !*hostMetrics || *dryRun {
+		return nil
+	}
+	switch strings.ToLower(*metricsExporter) {
+	case "none", "statsd", "dogstatsd":
+		return fmt.Errorf("-host-metrics requires an OpenTelemetry -metrics-exporter, one of 'otlp', 'prometheus' or 'pushgateway'")
+	}
+	if err := host.Start(host.WithMeterProvider(global.GetMeterProvider())); err != nil {
+		return fmt.Errorf("failed to start host metrics: %w", err)
+	}
+	return nil
+}
+
+// initOTLPMetrics pushes metrics to the same OTLP collector as spans, with the same TLS and header settings.
+func initOTLPMetrics(ctx context.Context, res *resource.Resource) (func(context.Context), error) {
+	metricExp, err := telemetryflags.MetricExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := latencyBucketsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	pusher := controller.New(
+		processor.NewFactory(
+			simple.NewWithHistogramDistribution(histogram.WithExplicitBoundaries(buckets)),
+			metricExp,
+		),
+		controller.WithExporter(metricExp),
+		controller.WithCollectPeriod(*metricsInterval),
+		controller.WithResource(res),
+	)
+	global.SetMeterProvider(pusher)
+
+	if err := pusher.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start metric pusher: %w", err)
+	}
+	logger.Info("exporting metrics", zap.String("addr", *otlpEndpoint), zap.Duration("interval", *metricsInterval))
+
+	return func(doneCtx context.Context) {
+		// Stop does a final collection and pushes it to the collector.
+		if err := pusher.Stop(doneCtx); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
+}
+
+// initPrometheusMetrics serves metrics at /metrics on -metrics-addr, so Prometheus can scrape the
+// client while it runs.
+func initPrometheusMetrics(res *resource.Resource) (func(context.Context), error) {
+	// The exporter's sums are cumulative from when it starts, which is the time of their _created samples.
+	created := time.Now()
+	registry, err := newPrometheusRegistry(res)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen before returning, so an address that is in use fails at startup.
+	lis, err := net.Listen("tcp", *metricsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("-metrics-addr: %w", err)
+	}
+	mux := http.NewServeMux()
+	// Exemplars and _created samples are only part of the OpenMetrics format, which scrapers ask for
+	// with the Accept header.
+	mux.Handle("/metrics", promexport.Handler(registry, created))
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+			logger.Error("metrics server stopped", zap.Error(err))
+		}
+	}()
+	logger.Info("serving Prometheus metrics", zap.String("addr", lis.Addr().String()))
+
+	return func(doneCtx context.Context) {
+		if err := srv.Shutdown(doneCtx); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
+}
+
+// initPushgatewayMetrics pushes metrics to the Prometheus Pushgateway at -pushgateway-url when the
+// client exits. This suits short runs, like a smoke test in CI, that end before they could be scraped.
+func initPushgatewayMetrics(res *resource.Resource) (func(context.Context), error) {
+	if *pushgatewayURL == "" {
+		return nil, fmt.Errorf("-pushgateway-url must be set to use -metrics-exporter=pushgateway")
+	}
+	registry, err := newPrometheusRegistry(res)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get the hostname for the instance label: %w", err)
+	}
+	// Grouping by instance keeps clients on different hosts from replacing each other's metrics.
+	pusher := push.New(*pushgatewayURL, serviceName).Gatherer(registry).Grouping("instance", instance)
+
+	return func(doneCtx context.Context) {
+		if err := pusher.PushContext(doneCtx); err != nil {
+			otel.Handle(fmt.Errorf("failed to push metrics to %s: %w", *pushgatewayURL, err))
+			return
+		}
+		logger.Info("pushed metrics", zap.String("url", *pushgatewayURL))
+	}, nil
+}
+
+// initStatsdMetrics sends the request counters and timers to the StatsD server at -statsd-addr.
+// With -metrics-exporter=dogstatsd, the host and status class are sent as tags. The other metrics, like
+// the export queue depth, are only available from the OpenTelemetry exporters.
+func initStatsdMetrics() (func(context.Context), error) {
+	dogstatsd := strings.ToLower(*metricsExporter) == "dogstatsd"
+	s, err := newStatsdClient(*statsdAddr, "demo_client.", dogstatsd)
+	if err != nil {
+		return nil, err
+	}
+	statsd = s
+	logger.Info("sending metrics to StatsD", zap.String("addr", *statsdAddr), zap.Bool("dogstatsd", dogstatsd))
+
+	return func(context.Context) {
+		if err := s.close(); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
+}
+
+// newPrometheusRegistry registers the global meter provider with a Prometheus registry, with the -latency-buckets,
+// and returns it. The registry also has the histogram of latencyExemplars.
+func newPrometheusRegistry(res *resource.Resource) (*prom.Registry, error) {
+	buckets, err := latencyBucketsFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	registry, err := promexport.NewRegistry(res, buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	// The OTel SDK can't record exemplars, so latency is also recorded in a Prometheus histogram that can.
+	latencyExemplars = prom.NewHistogramVec(
+		prom.HistogramOpts{
+			Name:    "demo_client_request_latency_exemplars",
+			Help:    "The latency of requests to the server in milliseconds, with the trace ID of a request in each bucket",
+			Buckets: buckets,
+		},
+		[]string{"http_host", "http_status_class"},
+	)
+	if err := registry.Register(latencyExemplars); err != nil {
+		return nil, fmt.Errorf("failed to register exemplar histogram: %w", err)
+	}
+	return registry, nil
+}
+
+// latencyExemplars is set when metrics are exported to Prometheus. It records request latency with the
+// trace ID of the request as an exemplar, so a dashboard can jump from a latency bucket to a trace.
+var latencyExemplars *prom.HistogramVec
+
+// recordLatencyExemplar records ms in latencyExemplars with the trace ID in ctx as an exemplar.
+// Traces that aren't sampled are never exported, so they are recorded without one.
+func recordLatencyExemplar(ctx context.Context, ms float64, host, class string) {
+	if latencyExemplars == nil {
+		return
+	}
+	obs := latencyExemplars.WithLabelValues(host, class)
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		obs.Observe(ms)
+		return
+	}
+	obs.(prom.ExemplarObserver).ObserveWithExemplar(ms, prom.Labels{"trace_id": sc.TraceID().String()})
+}
+
+// latencyBucketsFromFlags returns the histogram bucket boundaries set by -latency-buckets.
+func latencyBucketsFromFlags() ([]float64, error) {
+	var buckets []float64
+	for _, b := range strings.Split(*latencyBuckets, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if err != nil {
+			return nil, fmt.Errorf("-latency-buckets: %q is not a number", b)
+		}
+		if len(buckets) > 0 && v <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("-latency-buckets must be in increasing order")
+		}
+		buckets = append(buckets, v)
+	}
+	return buckets, nil
+}
+
+// ClientInstruments is a collection of instruments used to measure client requests to the server.
+type ClientInstruments struct {
+	// RED records the rate, errors and duration of requests.
+	RED *redmetrics.Metrics
+	// ErrorsByStatus counts failed requests by status code and endpoint.
+	ErrorsByStatus metric.Int64Counter
+	// Connections counts the connections requests were sent on, by whether they were new or reused.
+	Connections metric.Int64Counter
+}
+
+// NewClientInstruments takes a meter and builds a set of instruments to be used to measure client requests to the server.
+func NewClientInstruments(meter metric.Meter) (ClientInstruments, error) {
+	red, err := redmetrics.New(meter, "demo_client/request")
+	if err != nil {
+		return ClientInstruments{}, err
+	}
+	errorsByStatus, err := meter.NewInt64Counter(
+		"demo_client/request_errors_by_status",
+		metric.WithDescription("The number of failed requests by HTTP status code and endpoint, 0 when there was no response"),
+	)
+	if err != nil {
+		return ClientInstruments{}, err
+	}
+	connections, err := meter.NewInt64Counter(
+		"demo_client/connections",
+		metric.WithDescription("The number of connections requests were sent on, by whether the connection was reused from the pool"),
+	)
+	if err != nil {
+		return ClientInstruments{}, err
+	}
+	return ClientInstruments{RED: red, ErrorsByStatus: errorsByStatus, Connections: connections}, nil
+}
+
+// queueDepth tracks the number of spans waiting in a batch span processor's queue to be exported, and
+// the number dropped because it was full.
+type queueDepth struct {
+	spans   int64
+	dropped int64
+	// max is the size of the queue.
+	max int64
+	// name is the exporter the queue is for.
+	name string
+}
+
+// add counts a span going into the queue, and returns false if the queue is full.
+func (q *queueDepth) add() bool {
+	for {
+		n := atomic.LoadInt64(&q.spans)
+		if n >= q.max {
+			atomic.AddInt64(&q.dropped, 1)
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&q.spans, n, n+1) {
+			return true
+		}
+	}
+}
+
+// exportQueues are the queues of the batch span processors, for the dashboard.
+var exportQueues []*queueDepth
+
+// queueTrackingProcessor counts the sampled spans handed to a batch span processor. It drops spans
+// itself when the queue is full, as the processor would, so the drops can be counted; the processor
+// doesn't report its own.
+type queueTrackingProcessor struct {
+	sdktrace.SpanProcessor
+	depth *queueDepth
+}
+
+// OnEnd implements sdktrace.SpanProcessor.OnEnd.
+func (p queueTrackingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	// The batch span processor ignores spans that aren't sampled.
+	if s.SpanContext().IsSampled() && !p.depth.add() {
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// queueTrackingExporter counts the spans a batch span processor takes out of its queue to export.
+type queueTrackingExporter struct {
+	sdktrace.SpanExporter
+	depth *queueDepth
+}
+
+// ExportSpans implements sdktrace.SpanExporter.ExportSpans.
+func (e queueTrackingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	atomic.AddInt64(&e.depth.spans, -int64(len(spans)))
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	clientHealth.recordExport(err)
+	return err
+}
+
+// newTrackedBatchSpanProcessor returns a batch span processor for traceExp whose queue depth and drops are
+// reported as the demo_client/export_queue_depth and demo_client/export_queue_dropped metrics, with the
+// exporter attribute set to name.
+func newTrackedBatchSpanProcessor(name string, traceExp sdktrace.SpanExporter, opts ...sdktrace.BatchSpanProcessorOption) sdktrace.SpanProcessor {
+	o := sdktrace.BatchSpanProcessorOptions{MaxQueueSize: sdktrace.DefaultMaxQueueSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	depth := &queueDepth{max: int64(o.MaxQueueSize), name: name}
+	exportQueues = append(exportQueues, depth)
+	meter := metric.Must(global.Meter(meterName))
+	meter.NewInt64GaugeObserver(
+		"demo_client/export_queue_depth",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(atomic.LoadInt64(&depth.spans), attribute.String("exporter", name))
+		},
+		metric.WithDescription("The number of spans waiting to be exported"),
+	)
+	meter.NewInt64CounterObserver(
+		"demo_client/export_queue_dropped",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(atomic.LoadInt64(&depth.dropped), attribute.String("exporter", name))
+		},
+		metric.WithDescription("The number of spans dropped because the export queue was full"),
+	)
+	bsp := sdktrace.NewBatchSpanProcessor(queueTrackingExporter{SpanExporter: traceExp, depth: depth}, opts...)
+	return queueTrackingProcessor{SpanProcessor: bsp, depth: depth}
+}
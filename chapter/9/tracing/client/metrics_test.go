@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// countingProcessor is a sdktrace.SpanProcessor that counts the spans it is handed.
+type countingProcessor struct {
+	sdktrace.SpanProcessor
+	ended int
+}
+
+func (p *countingProcessor) OnEnd(sdktrace.ReadOnlySpan) { p.ended++ }
+
+func TestQueueTracking(t *testing.T) {
+	sampled := tracetest.SpanStub{SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	})}.Snapshot()
+	unsampled := tracetest.SpanStub{SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{2},
+		SpanID:  trace.SpanID{2},
+	})}.Snapshot()
+
+	depth := &queueDepth{max: 2}
+	next := &countingProcessor{}
+	p := queueTrackingProcessor{SpanProcessor: next, depth: depth}
+	for _, s := range []sdktrace.ReadOnlySpan{sampled, unsampled, sampled, sampled} {
+		p.OnEnd(s)
+	}
+
+	// The third sampled span doesn't fit in the queue. The span that isn't sampled isn't queued at all.
+	if next.ended != 3 {
+		t.Errorf("TestQueueTracking: got %d spans passed on, want 3", next.ended)
+	}
+	if depth.spans != 2 || depth.dropped != 1 {
+		t.Errorf("TestQueueTracking: got %d queued and %d dropped, want 2 and 1", depth.spans, depth.dropped)
+	}
+
+	e := queueTrackingExporter{SpanExporter: tracetest.NewNoopExporter(), depth: depth}
+	if err := e.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{sampled, sampled}); err != nil {
+		t.Fatalf("TestQueueTracking: got err == %s, want err == nil", err)
+	}
+	p.OnEnd(sampled)
+	if depth.spans != 1 || depth.dropped != 1 {
+		t.Errorf("TestQueueTracking(after export): got %d queued and %d dropped, want 1 and 1", depth.spans, depth.dropped)
+	}
+}
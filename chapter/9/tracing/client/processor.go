@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// batchOptions returns the options for the batch span processors set by the -bsp-* flags.
+func batchOptions() ([]sdktrace.BatchSpanProcessorOption, error) {
+	if *bspMaxQueueSize < 1 || *bspMaxExportBatchSize < 1 {
+		return nil, fmt.Errorf("-bsp-max-queue-size and -bsp-max-export-batch-size must be at least 1")
+	}
+	if *bspMaxExportBatchSize > *bspMaxQueueSize {
+		return nil, fmt.Errorf("-bsp-max-export-batch-size(%d) cannot be larger than -bsp-max-queue-size(%d)", *bspMaxExportBatchSize, *bspMaxQueueSize)
+	}
+	if *bspScheduleDelay <= 0 || *bspExportTimeout <= 0 {
+		return nil, fmt.Errorf("-bsp-schedule-delay and -bsp-export-timeout must be positive")
+	}
+
+	return []sdktrace.BatchSpanProcessorOption{
+		sdktrace.WithBatchTimeout(*bspScheduleDelay),
+		sdktrace.WithExportTimeout(*bspExportTimeout),
+		sdktrace.WithMaxQueueSize(*bspMaxQueueSize),
+		sdktrace.WithMaxExportBatchSize(*bspMaxExportBatchSize),
+	}, nil
+}
+
+// spanFilter is a condition that drops a span when it matches. key is either "name" for the span name or
+// an attribute key like "http.target". pattern is a path.Match pattern for the name or attribute value.
+type spanFilter struct {
+	key, pattern string
+}
+
+// matches returns true if s matches the filter.
+func (f spanFilter) matches(s sdktrace.ReadOnlySpan) bool {
+	if f.key == "name" {
+		ok, _ := path.Match(f.pattern, s.Name())
+		return ok
+	}
+	for _, kv := range s.Attributes() {
+		if string(kv.Key) != f.key {
+			continue
+		}
+		ok, _ := path.Match(f.pattern, kv.Value.Emit())
+		return ok
+	}
+	return false
+}
+
+// parseSpanFilters parses -drop-spans, a comma separated list of key=pattern conditions like
+// "name=HealthCheck,http.target=/healthz*".
+func parseSpanFilters(s string) ([]spanFilter, error) {
+	var filters []spanFilter
+	for _, cond := range strings.Split(s, ",") {
+		cond = strings.TrimSpace(cond)
+		if cond == "" {
+			continue
+		}
+		kv := strings.SplitN(cond, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("-drop-spans: %q is not in key=pattern format", cond)
+		}
+		if _, err := path.Match(kv[1], ""); err != nil {
+			return nil, fmt.Errorf("-drop-spans: %q has a bad pattern: %w", cond, err)
+		}
+		filters = append(filters, spanFilter{key: kv[0], pattern: kv[1]})
+	}
+	return filters, nil
+}
+
+// filterProcessor is a sdktrace.SpanProcessor that drops spans matching any of its filters and passes
+// the rest to the next processors. This keeps noise like health check probes from reaching the collector.
+// Dropping a span that has children leaves those children pointing at a parent that was never exported.
+type filterProcessor struct {
+	next    []sdktrace.SpanProcessor
+	filters []spanFilter
+}
+
+// OnStart implements sdktrace.SpanProcessor.OnStart.
+func (f *filterProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, sp := range f.next {
+		sp.OnStart(parent, s)
+	}
+}
+
+// OnEnd implements sdktrace.SpanProcessor.OnEnd.
+func (f *filterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, filter := range f.filters {
+		if filter.matches(s) {
+			return
+		}
+	}
+	for _, sp := range f.next {
+		sp.OnEnd(s)
+	}
+}
+
+// ForceFlush implements sdktrace.SpanProcessor.ForceFlush. All the next processors are flushed, even if
+// one fails.
+func (f *filterProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, sp := range f.next {
+		errs = append(errs, sp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown implements sdktrace.SpanProcessor.Shutdown. All the next processors are shut down, even if
+// one fails.
+func (f *filterProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, sp := range f.next {
+		errs = append(errs, sp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBatchOptions(t *testing.T) {
+	tests := []struct {
+		desc    string
+		flags   map[string]string
+		want    sdktrace.BatchSpanProcessorOptions
+		wantErr bool
+	}{
+		{
+			desc: "Values from flags",
+			flags: map[string]string{
+				"bsp-schedule-delay":        "2s",
+				"bsp-export-timeout":        "10s",
+				"bsp-max-queue-size":        "100",
+				"bsp-max-export-batch-size": "10",
+			},
+			want: sdktrace.BatchSpanProcessorOptions{
+				BatchTimeout:       2 * time.Second,
+				ExportTimeout:      10 * time.Second,
+				MaxQueueSize:       100,
+				MaxExportBatchSize: 10,
+			},
+		},
+		{
+			desc:    "Empty queue",
+			flags:   map[string]string{"bsp-max-queue-size": "0"},
+			wantErr: true,
+		},
+		{
+			desc:    "Batch larger than the queue",
+			flags:   map[string]string{"bsp-max-queue-size": "10", "bsp-max-export-batch-size": "11"},
+			wantErr: true,
+		},
+		{
+			desc:    "Zero schedule delay",
+			flags:   map[string]string{"bsp-schedule-delay": "0s"},
+			wantErr: true,
+		},
+		{
+			desc:    "Negative export timeout",
+			flags:   map[string]string{"bsp-export-timeout": "-1s"},
+			wantErr: true,
+		},
+	}
+
+	oldDelay, oldTimeout, oldQueue, oldBatch := *bspScheduleDelay, *bspExportTimeout, *bspMaxQueueSize, *bspMaxExportBatchSize
+	reset := func() {
+		*bspScheduleDelay, *bspExportTimeout, *bspMaxQueueSize, *bspMaxExportBatchSize = oldDelay, oldTimeout, oldQueue, oldBatch
+	}
+	defer reset()
+
+	for _, test := range tests {
+		reset()
+		for name, value := range test.flags {
+			if err := Flags.Set(name, value); err != nil {
+				t.Fatalf("TestBatchOptions(%s): Flags.Set(%s, %s): %s", test.desc, name, value, err)
+			}
+		}
+
+		opts, err := batchOptions()
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestBatchOptions(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestBatchOptions(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		var got sdktrace.BatchSpanProcessorOptions
+		for _, opt := range opts {
+			opt(&got)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestBatchOptions(%s): got %+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestParseSpanFilters(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    []spanFilter
+		wantErr bool
+	}{
+		{
+			desc: "Empty",
+			s:    "",
+		},
+		{
+			desc: "Name and attribute with spaces",
+			s:    "name=HealthCheck, http.target=/healthz* ,",
+			want: []spanFilter{{key: "name", pattern: "HealthCheck"}, {key: "http.target", pattern: "/healthz*"}},
+		},
+		{
+			desc: "Pattern may contain an equals sign",
+			s:    "http.target=/?a=b",
+			want: []spanFilter{{key: "http.target", pattern: "/?a=b"}},
+		},
+		{
+			desc:    "Missing pattern",
+			s:       "name",
+			wantErr: true,
+		},
+		{
+			desc:    "Missing key",
+			s:       "=HealthCheck",
+			wantErr: true,
+		},
+		{
+			desc:    "Bad pattern",
+			s:       "name=[",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseSpanFilters(test.s)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseSpanFilters(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseSpanFilters(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestParseSpanFilters(%s): got %+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestFilterProcessor(t *testing.T) {
+	filters, err := parseSpanFilters("name=HealthCheck,http.target=/healthz*")
+	if err != nil {
+		t.Fatalf("TestFilterProcessor: parseSpanFilters: %s", err)
+	}
+
+	tests := []struct {
+		desc     string
+		span     tracetest.SpanStub
+		wantKept bool
+	}{
+		{
+			desc: "Name matches",
+			span: tracetest.SpanStub{Name: "HealthCheck"},
+		},
+		{
+			desc: "Attribute matches",
+			span: tracetest.SpanStub{Name: "GET", Attributes: []attribute.KeyValue{attribute.String("http.target", "/healthz?verbose=1")}},
+		},
+		{
+			desc:     "Nothing matches",
+			span:     tracetest.SpanStub{Name: "SayHello", Attributes: []attribute.KeyValue{attribute.String("http.target", "/hello")}},
+			wantKept: true,
+		},
+		{
+			desc:     "Star doesn't match past a slash",
+			span:     tracetest.SpanStub{Name: "GET", Attributes: []attribute.KeyValue{attribute.String("http.target", "/healthz/ready")}},
+			wantKept: true,
+		},
+		{
+			desc:     "Attribute value matching another key's pattern",
+			span:     tracetest.SpanStub{Name: "GET", Attributes: []attribute.KeyValue{attribute.String("http.route", "/healthz")}},
+			wantKept: true,
+		},
+	}
+
+	for _, test := range tests {
+		next := &recordingProcessor{}
+		f := &filterProcessor{next: []sdktrace.SpanProcessor{next}, filters: filters}
+		f.OnEnd(test.span.Snapshot())
+
+		if got := next.count() == 1; got != test.wantKept {
+			t.Errorf("TestFilterProcessor(%s): got kept == %v, want %v", test.desc, got, test.wantKept)
+		}
+	}
+}
+
+func TestFilterProcessorErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	a, b := &failingProcessor{err: errA}, &failingProcessor{err: errB}
+	f := &filterProcessor{next: []sdktrace.SpanProcessor{a, b}}
+
+	err := f.ForceFlush(context.Background())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("TestFilterProcessorErrors: ForceFlush: got err == %v, want both processors' errors", err)
+	}
+	err = f.Shutdown(context.Background())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("TestFilterProcessorErrors: Shutdown: got err == %v, want both processors' errors", err)
+	}
+	if a.calls != 2 || b.calls != 2 {
+		t.Errorf("TestFilterProcessorErrors: got %d and %d calls, want 2 each", a.calls, b.calls)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadProfile returns the rate, in requests per second, requests are sent at elapsed after the client
+// started.
+type loadProfile func(elapsed time.Duration) float64
+
+// parseLoadProfile parses a -load-profile, which is one of:
+//
+//	ramp:FROM-TO:DURATION      the rate rises evenly from FROM to TO over DURATION, then stays at TO
+//	step:R1,R2,...:DURATION    each rate is kept for DURATION, then the last rate is kept
+//	spike:BASE,PEAK:EVERY:FOR  the rate is BASE, except for FOR out of every EVERY when it is PEAK
+//
+// like "ramp:1-50:5m", "step:10,20,50:1m" or "spike:5,100:2m:10s", or '@' and the path of a file with
+// one, like a mounted ConfigMap. An empty string returns a nil loadProfile, for a constant rate.
+func parseLoadProfile(s string) (loadProfile, error) {
+	if s != "" && s[0] == '@' {
+		b, err := os.ReadFile(s[1:])
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile: %w", err)
+		}
+		s = strings.TrimSpace(string(b))
+	}
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ":")
+	switch parts[0] {
+	case "ramp":
+		if len(parts) != 3 {
+			break
+		}
+		rates, err := parseRates(strings.Split(parts[1], "-"), 2)
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		d, err := parsePositiveDuration(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		from, to := rates[0], rates[1]
+		return func(elapsed time.Duration) float64 {
+			if elapsed >= d {
+				return to
+			}
+			return from + (to-from)*float64(elapsed)/float64(d)
+		}, nil
+	case "step":
+		if len(parts) != 3 {
+			break
+		}
+		rates, err := parseRates(strings.Split(parts[1], ","), 0)
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		d, err := parsePositiveDuration(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		return func(elapsed time.Duration) float64 {
+			i := int(elapsed / d)
+			if i >= len(rates) {
+				i = len(rates) - 1
+			}
+			return rates[i]
+		}, nil
+	case "spike":
+		if len(parts) != 4 {
+			break
+		}
+		rates, err := parseRates(strings.Split(parts[1], ","), 2)
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		every, err := parsePositiveDuration(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		length, err := parsePositiveDuration(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("-load-profile=%s: %w", s, err)
+		}
+		if length >= every {
+			return nil, fmt.Errorf("-load-profile=%s: the spike must be shorter than the time between spikes", s)
+		}
+		base, peak := rates[0], rates[1]
+		return func(elapsed time.Duration) float64 {
+			// Spikes come at the end of each period, so the base rate is seen first.
+			if elapsed%every >= every-length {
+				return peak
+			}
+			return base
+		}, nil
+	}
+	return nil, fmt.Errorf("-load-profile=%s is not a valid value", s)
+}
+
+// parseRates parses rates in requests per second. If n isn't 0, there must be n of them.
+func parseRates(ss []string, n int) ([]float64, error) {
+	if n != 0 && len(ss) != n {
+		return nil, fmt.Errorf("expected %d rates, got %d", n, len(ss))
+	}
+	rates := make([]float64, 0, len(ss))
+	for _, s := range ss {
+		r, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || r < 0 {
+			return nil, fmt.Errorf("%q is not a valid rate", s)
+		}
+		rates = append(rates, r)
+	}
+	return rates, nil
+}
+
+// parsePositiveDuration parses a duration like "5m", which must be greater than 0.
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%q is not a valid duration", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	type point struct {
+		elapsed time.Duration
+		want    float64
+	}
+	tests := []struct {
+		desc    string
+		s       string
+		points  []point
+		wantErr bool
+	}{
+		{
+			desc:   "Ramp",
+			s:      "ramp:10-50:4m",
+			points: []point{{0, 10}, {time.Minute, 20}, {2 * time.Minute, 30}, {4 * time.Minute, 50}, {time.Hour, 50}},
+		},
+		{
+			desc:   "Step",
+			s:      "step:10,20,50:1m",
+			points: []point{{0, 10}, {59 * time.Second, 10}, {time.Minute, 20}, {2 * time.Minute, 50}, {time.Hour, 50}},
+		},
+		{
+			desc:   "Spike",
+			s:      "spike:5,100:2m:10s",
+			points: []point{{0, 5}, {time.Minute, 5}, {115 * time.Second, 100}, {2 * time.Minute, 5}, {235 * time.Second, 100}},
+		},
+		{
+			desc:    "Unknown profile",
+			s:       "sine:1-10:1m",
+			wantErr: true,
+		},
+		{
+			desc:    "Ramp with one rate",
+			s:       "ramp:10:1m",
+			wantErr: true,
+		},
+		{
+			desc:    "Negative rate",
+			s:       "step:10,-1:1m",
+			wantErr: true,
+		},
+		{
+			desc:    "Spike longer than period",
+			s:       "spike:5,100:10s:1m",
+			wantErr: true,
+		},
+		{
+			desc:    "Zero duration",
+			s:       "step:10:0s",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		p, err := parseLoadProfile(test.s)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseLoadProfile(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseLoadProfile(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		for _, pt := range test.points {
+			if got := p(pt.elapsed); got != pt.want {
+				t.Errorf("TestParseLoadProfile(%s): at %s got %v, want %v", test.desc, pt.elapsed, got, pt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Reloadable lists the flags Reload can change while the client runs.
+var Reloadable = map[string]bool{
+	"rate":                  true,
+	"request-interval":      true,
+	"max-qps":               true,
+	"sampler-arg":           true,
+	"log-level":             true,
+	"server-endpoint":       true,
+	"server-endpoints-file": true,
+	"scenario":              true,
+	"load-profile":          true,
+}
+
+// rateChange is a new rate for the request loop.
+type rateChange struct {
+	interval time.Duration
+	maxQPS   float64
+	profile  loadProfile
+	// restart is true if the profile changed, so it starts over from the next request.
+	restart bool
+}
+
+// rateChanges passes rate changes from Reload to the request loop, which applies them from the next
+// request. It only holds the latest.
+var rateChanges = make(chan rateChange, 1)
+
+// liveTargets picks the target of each request. It is set in Main.
+var liveTargets *targetPicker
+
+// liveScenario is the scenario requests run, set in Main if there is a -scenario.
+var liveScenario *swappableScenario
+
+// ratioSampler is set when -sampler is ratio based, so Reload can change the ratio.
+var ratioSampler *swappableSampler
+
+// reloadMu makes sure reloads are applied one at a time.
+var reloadMu sync.Mutex
+
+// Reload changes the settings of the running client to values, keyed by the name of a Reloadable flag.
+// The new values are validated like at startup, and if any is invalid none is applied. Each change is a
+// "configuration changed" event on a "Reload configuration" span, which is always sampled.
+func Reload(values map[string]string) (err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	ctx, span := otel.Tracer("demo-client-tracer").Start(forcesample.With(context.Background()), "Reload configuration")
+	defer span.End()
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			logger.Error("failed to reload configuration", zap.Error(err), Ctx(ctx))
+		}
+	}()
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	old := map[string]string{}
+	restore := func() {
+		for name, value := range old {
+			lookupFlag(name).Value.Set(value)
+		}
+	}
+	for _, name := range names {
+		f := lookupFlag(name)
+		if f == nil || !Reloadable[name] {
+			restore()
+			return fmt.Errorf("-%s can't be changed while the client runs", name)
+		}
+		old[name] = f.Value.String()
+		if err := f.Value.Set(values[name]); err != nil {
+			restore()
+			return fmt.Errorf("-%s=%s is not a valid value: %w", name, values[name], err)
+		}
+	}
+	apply, err := reloadFromFlags(values)
+	if err != nil {
+		restore()
+		return err
+	}
+	apply()
+
+	for _, name := range names {
+		span.AddEvent("configuration changed", trace.WithAttributes(
+			attribute.String("demo.config.setting", name),
+			attribute.String("demo.config.old", old[name]),
+			attribute.String("demo.config.new", values[name]),
+		))
+	}
+	logger.Info("configuration changed", zap.Strings("settings", names), Ctx(ctx))
+	return nil
+}
+
+// lookupFlag returns the client's flag, or shared telemetry flag, called name, or nil if there is none.
+func lookupFlag(name string) *flag.Flag {
+	if f := Flags.Lookup(name); f != nil {
+		return f
+	}
+	return telemetryflags.FlagSet.Lookup(name)
+}
+
+// reloadFromFlags returns a func that applies the flags named in changed to the running client, or an
+// error if they aren't valid.
+func reloadFromFlags(changed map[string]string) (func(), error) {
+	has := func(names ...string) bool {
+		for _, name := range names {
+			if _, ok := changed[name]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	var applies []func()
+
+	if has("rate", "request-interval", "max-qps", "load-profile") {
+		interval, err := loadInterval(*requestInterval, *requestRate, *maxQPS)
+		if err != nil {
+			return nil, err
+		}
+		profile, err := parseLoadProfile(*loadProfileSpec)
+		if err != nil {
+			return nil, err
+		}
+		c := rateChange{interval: interval, maxQPS: *maxQPS, profile: profile, restart: has("load-profile")}
+		applies = append(applies, func() {
+			select {
+			case <-rateChanges:
+			default:
+			}
+			rateChanges <- c
+		})
+	}
+	if has("sampler-arg") {
+		if ratioSampler == nil {
+			return nil, fmt.Errorf("-sampler=%s doesn't have a ratio that can be changed while the client runs", *samplerName)
+		}
+		s, err := baseSampler()
+		if err != nil {
+			return nil, err
+		}
+		applies = append(applies, func() { ratioSampler.set(s) })
+	}
+	if has("log-level") {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+			return nil, fmt.Errorf("-log-level=%s is not a valid value", *logLevel)
+		}
+		applies = append(applies, func() { logAtomicLevel.SetLevel(level) })
+	}
+	if has("server-endpoint", "server-endpoints-file") {
+		if liveTargets == nil {
+			return nil, fmt.Errorf("the client isn't sending requests yet")
+		}
+		targets, err := targetsFromFlags()
+		if err != nil {
+			return nil, err
+		}
+		applies = append(applies, func() { liveTargets.set(targets) })
+	}
+	if has("scenario") {
+		if liveScenario == nil || *scenarioFile == "" {
+			return nil, fmt.Errorf("-scenario can only be changed while the client runs one")
+		}
+		s, err := LoadScenario(*scenarioFile)
+		if err != nil {
+			return nil, err
+		}
+		applies = append(applies, func() { liveScenario.set(s) })
+	}
+
+	return func() {
+		for _, apply := range applies {
+			apply()
+		}
+	}, nil
+}
+
+// swappableScenario holds the scenario requests run, which can be replaced while they run. Runs that
+// started keep the scenario they started with.
+type swappableScenario struct {
+	mu       sync.RWMutex
+	scenario *Scenario
+}
+
+func newSwappableScenario(s *Scenario) *swappableScenario {
+	return &swappableScenario{scenario: s}
+}
+
+func (s *swappableScenario) get() *Scenario {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scenario
+}
+
+// set replaces the scenario. The new one's runs are linked to the load run's span like the old one's.
+func (s *swappableScenario) set(scenario *Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scenario.anchor = s.scenario.anchor
+	s.scenario = scenario
+}
+
+// swappableSampler is a sdktrace.Sampler that delegates to one that can be replaced while it is used.
+type swappableSampler struct {
+	mu      sync.RWMutex
+	sampler sdktrace.Sampler
+}
+
+func newSwappableSampler(s sdktrace.Sampler) *swappableSampler {
+	return &swappableSampler{sampler: s}
+}
+
+func (s *swappableSampler) get() sdktrace.Sampler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sampler
+}
+
+func (s *swappableSampler) set(sampler sdktrace.Sampler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampler = sampler
+}
+
+// ShouldSample implements sdktrace.Sampler.ShouldSample.
+func (s *swappableSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.get().ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler.Description.
+func (s *swappableSampler) Description() string {
+	return s.get().Description()
+}
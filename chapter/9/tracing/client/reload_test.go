@@ -0,0 +1,95 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	oldRate, oldInterval, oldMaxQPS, oldProfile := *requestRate, *requestInterval, *maxQPS, *loadProfileSpec
+	t.Cleanup(func() {
+		*requestRate, *requestInterval, *maxQPS, *loadProfileSpec = oldRate, oldInterval, oldMaxQPS, oldProfile
+	})
+
+	tests := []struct {
+		desc         string
+		values       map[string]string
+		wantErr      bool
+		wantInterval time.Duration
+		wantRestart  bool
+	}{
+		{
+			desc:         "Rate",
+			values:       map[string]string{"rate": "5"},
+			wantInterval: 200 * time.Millisecond,
+		},
+		{
+			desc:         "Rate capped by max QPS",
+			values:       map[string]string{"rate": "50", "max-qps": "10"},
+			wantInterval: 100 * time.Millisecond,
+		},
+		{
+			desc:         "Load profile",
+			values:       map[string]string{"load-profile": "ramp:1-50:5m"},
+			wantInterval: time.Second,
+			wantRestart:  true,
+		},
+		{
+			desc:    "Invalid load profile",
+			values:  map[string]string{"load-profile": "ramp:fast"},
+			wantErr: true,
+		},
+		{
+			desc:    "Invalid rate",
+			values:  map[string]string{"rate": "-1"},
+			wantErr: true,
+		},
+		{
+			desc:    "Invalid value",
+			values:  map[string]string{"max-qps": "fast"},
+			wantErr: true,
+		},
+		{
+			desc:    "Not reloadable",
+			values:  map[string]string{"rate": "20", "concurrency": "10"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		*requestRate, *requestInterval, *maxQPS, *loadProfileSpec = 1, time.Second, 0, ""
+
+		err := Reload(test.values)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestReload(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestReload(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			// Nothing is applied when a value is invalid.
+			if *requestRate != 1 || *maxQPS != 0 {
+				t.Errorf("TestReload(%s): got -rate=%v -max-qps=%v, want the values before the reload", test.desc, *requestRate, *maxQPS)
+			}
+			select {
+			case c := <-rateChanges:
+				t.Errorf("TestReload(%s): got rate change %+v, want none", test.desc, c)
+			default:
+			}
+			continue
+		}
+
+		select {
+		case c := <-rateChanges:
+			if c.interval != test.wantInterval {
+				t.Errorf("TestReload(%s): got interval %s, want %s", test.desc, c.interval, test.wantInterval)
+			}
+			if c.restart != test.wantRestart {
+				t.Errorf("TestReload(%s): got restart %t, want %t", test.desc, c.restart, test.wantRestart)
+			}
+		default:
+			t.Errorf("TestReload(%s): got no rate change, want one", test.desc)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// accessLogEntry is a request read from an access log.
+type accessLogEntry struct {
+	Time   time.Time
+	Method string
+	// Path is the path and query of the request, like /hello?name=a.
+	Path string
+}
+
+// clfRE matches a line in the Common Log Format, or the Combined Log Format which adds fields to it, like:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /hello HTTP/1.0" 200 2326
+var clfRE = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*"`)
+
+// clfTime is the layout of times in the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// parseAccessLogLine parses a line of an access log in the Common Log Format or as a JSON object. JSON
+// lines have the time in RFC 3339 format in "time" or "timestamp", the method in "method" and the path
+// in "path", "uri" or "url". Or the method and path can be in "request", like in the Common Log Format.
+func parseAccessLogLine(line string) (accessLogEntry, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		m := clfRE.FindStringSubmatch(line)
+		if m == nil {
+			return accessLogEntry{}, fmt.Errorf("line is not in the Common Log Format")
+		}
+		t, err := time.Parse(clfTime, m[1])
+		if err != nil {
+			return accessLogEntry{}, fmt.Errorf("invalid time %q", m[1])
+		}
+		return accessLogEntry{Time: t, Method: m[2], Path: m[3]}, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return accessLogEntry{}, fmt.Errorf("line is not a JSON object: %w", err)
+	}
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			if v, ok := fields[k].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	var e accessLogEntry
+	ts := str("time", "timestamp")
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return accessLogEntry{}, fmt.Errorf("invalid time %q", ts)
+	}
+	e.Time = t
+	e.Method, e.Path = str("method"), str("path", "uri", "url")
+	if req := strings.Fields(str("request")); len(req) >= 2 && e.Path == "" {
+		e.Method, e.Path = req[0], req[1]
+	}
+	if e.Method == "" {
+		e.Method = http.MethodGet
+	}
+	if e.Path == "" {
+		return accessLogEntry{}, fmt.Errorf("line has no path")
+	}
+	return e, nil
+}
+
+// replayAccessLog calls dispatch with each entry of the access log read from r, at the times between them
+// in the log divided by speed. Lines that can't be parsed are skipped. It returns when the log ends, ctx is
+// cancelled, dispatch returns false or limit entries have been dispatched, if limit isn't 0.
+func replayAccessLog(ctx context.Context, log *zap.Logger, r io.Reader, speed float64, limit int, dispatch func(*accessLogEntry) bool) error {
+	var first time.Time
+	start := time.Now()
+	sent, skipped := 0, 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() && (limit == 0 || sent < limit) {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		e, err := parseAccessLogLine(scanner.Text())
+		if err != nil {
+			skipped++
+			log.Debug("skipping access log line", zap.Error(err))
+			continue
+		}
+
+		if first.IsZero() {
+			first = e.Time
+		}
+		// Entries out of order in the log are sent right away.
+		at := start.Add(time.Duration(float64(e.Time.Sub(first)) / speed))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(at)):
+		}
+		if !dispatch(&e) {
+			return nil
+		}
+		sent++
+	}
+	if skipped > 0 {
+		log.Warn("skipped access log lines that could not be parsed", zap.Int("skipped", skipped))
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParseAccessLogLine(t *testing.T) {
+	tests := []struct {
+		desc    string
+		line    string
+		want    accessLogEntry
+		wantErr bool
+	}{
+		{
+			desc: "Common Log Format",
+			line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /hello?name=a HTTP/1.0" 200 2326`,
+			want: accessLogEntry{
+				Time:   time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC),
+				Method: "GET",
+				Path:   "/hello?name=a",
+			},
+		},
+		{
+			desc: "Combined Log Format",
+			line: `10.0.0.1 - - [10/Oct/2000:13:55:36 +0000] "POST /hello HTTP/1.1" 201 12 "-" "curl/7.68.0"`,
+			want: accessLogEntry{
+				Time:   time.Date(2000, 10, 10, 13, 55, 36, 0, time.UTC),
+				Method: "POST",
+				Path:   "/hello",
+			},
+		},
+		{
+			desc: "JSON",
+			line: `{"time": "2022-01-02T03:04:05.5Z", "method": "PUT", "uri": "/hello", "status": 200}`,
+			want: accessLogEntry{
+				Time:   time.Date(2022, 1, 2, 3, 4, 5, 500000000, time.UTC),
+				Method: "PUT",
+				Path:   "/hello",
+			},
+		},
+		{
+			desc: "JSON with request line",
+			line: `{"timestamp": "2022-01-02T03:04:05Z", "request": "DELETE /hello HTTP/1.1"}`,
+			want: accessLogEntry{
+				Time:   time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+				Method: "DELETE",
+				Path:   "/hello",
+			},
+		},
+		{
+			desc:    "JSON without a path",
+			line:    `{"time": "2022-01-02T03:04:05Z", "method": "GET"}`,
+			wantErr: true,
+		},
+		{
+			desc:    "Not an access log",
+			line:    "hello world",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseAccessLogLine(test.line)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseAccessLogLine(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseAccessLogLine(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if !got.Time.Equal(test.want.Time) {
+			t.Errorf("TestParseAccessLogLine(%s): got time %s, want %s", test.desc, got.Time, test.want.Time)
+		}
+		got.Time, test.want.Time = time.Time{}, time.Time{}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestParseAccessLogLine(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryPolicy decides whether and when failed requests are sent again.
+type retryPolicy struct {
+	// maxAttempts is the most times a request is sent. 1 means requests aren't retried.
+	maxAttempts int
+	// initialBackoff is the wait before the first retry. It doubles for each retry after that, up
+	// to maxBackoff.
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	// on5xx retries responses with a 5xx status.
+	on5xx bool
+	// onConnection retries requests that didn't get a response, like when the connection is refused.
+	onConnection bool
+}
+
+// retries is the retry policy set by the -retry-* flags.
+var retries = retryPolicy{maxAttempts: 1}
+
+// retryPolicyFromFlags returns the retryPolicy set by the -retry-* flags.
+func retryPolicyFromFlags() (retryPolicy, error) {
+	p := retryPolicy{
+		maxAttempts:    *retryMaxAttempts,
+		initialBackoff: *retryInitialBackoff,
+		maxBackoff:     *retryMaxBackoff,
+	}
+	if p.maxAttempts < 1 {
+		return retryPolicy{}, fmt.Errorf("-retry-max-attempts must be at least 1, was %d", p.maxAttempts)
+	}
+	if p.initialBackoff < 0 || p.maxBackoff < p.initialBackoff {
+		return retryPolicy{}, fmt.Errorf("-retry-initial-backoff cannot be negative or more than -retry-max-backoff")
+	}
+	for _, on := range strings.Split(*retryOn, ",") {
+		switch strings.ToLower(strings.TrimSpace(on)) {
+		case "":
+		case "5xx":
+			p.on5xx = true
+		case "connection":
+			p.onConnection = true
+		default:
+			return retryPolicy{}, fmt.Errorf("-retry-on: %q is not a valid value", on)
+		}
+	}
+	return p, nil
+}
+
+// shouldRetry returns whether a request with the response res or error err should be sent again.
+func (p retryPolicy) shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		// A request that ran out of time won't do better when sent again.
+		var timeout interface{ Timeout() bool }
+		if errors.As(err, &timeout) && timeout.Timeout() {
+			return false
+		}
+		return p.onConnection
+	}
+	return p.on5xx && res.StatusCode >= 500
+}
+
+// shouldRetryCall returns whether a gRPC call that failed with err should be made again. Unavailable,
+// the status of calls that couldn't reach the server, is retried like connection errors, and the codes
+// of server errors like 5xx responses.
+func (p retryPolicy) shouldRetryCall(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return p.onConnection || p.on5xx
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		return p.on5xx
+	}
+	return false
+}
+
+// backoff returns how long to wait after the attempt'th attempt, counting from 0, before the next.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialBackoff
+	for i := 0; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d
+}
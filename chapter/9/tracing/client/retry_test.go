@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	p := retryPolicy{maxAttempts: 5, initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second, on5xx: true}
+
+	wantBackoff := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, want := range wantBackoff {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("TestRetryPolicy(backoff %d): got %s, want %s", attempt, got, want)
+		}
+	}
+
+	tests := []struct {
+		desc         string
+		onConnection bool
+		res          *http.Response
+		err          error
+		want         bool
+	}{
+		{desc: "200", res: &http.Response{StatusCode: 200}},
+		{desc: "404", res: &http.Response{StatusCode: 404}},
+		{desc: "503", res: &http.Response{StatusCode: 503}, want: true},
+		{desc: "Connection error not retried", err: errors.New("connection refused")},
+		{desc: "Connection error retried", onConnection: true, err: errors.New("connection refused"), want: true},
+		{desc: "Timeout", onConnection: true, err: context.DeadlineExceeded},
+	}
+	for _, test := range tests {
+		p.onConnection = test.onConnection
+		if got := p.shouldRetry(test.res, test.err); got != test.want {
+			t.Errorf("TestRetryPolicy(%s): got %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyCall(t *testing.T) {
+	tests := []struct {
+		desc         string
+		on5xx        bool
+		onConnection bool
+		err          error
+		want         bool
+	}{
+		{desc: "OK", on5xx: true, onConnection: true},
+		{desc: "Unavailable", onConnection: true, err: status.Error(codes.Unavailable, "connection refused"), want: true},
+		{desc: "Unavailable not retried", err: status.Error(codes.Unavailable, "connection refused")},
+		{desc: "Internal", on5xx: true, err: status.Error(codes.Internal, "query failed"), want: true},
+		{desc: "Internal not retried", onConnection: true, err: status.Error(codes.Internal, "query failed")},
+		{desc: "Deadline exceeded", on5xx: true, onConnection: true, err: status.Error(codes.DeadlineExceeded, "deadline exceeded")},
+		{desc: "Invalid argument", on5xx: true, err: status.Error(codes.InvalidArgument, "no name")},
+	}
+	for _, test := range tests {
+		p := retryPolicy{maxAttempts: 3, on5xx: test.on5xx, onConnection: test.onConnection}
+		if got := p.shouldRetryCall(test.err); got != test.want {
+			t.Errorf("TestRetryPolicyCall(%s): got %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/client/internal/sampler"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// adaptiveSampler is set when -sampler=adaptive. The request loop reports each request's outcome to it.
+var adaptiveSampler *sampler.Adaptive
+
+// remoteSampler is set when -sampler=jaeger_remote, so its refreshing is stopped when the tracer provider
+// is shut down.
+var remoteSampler *sampler.Remote
+
+// samplerFromFlags returns the sdktrace.Sampler set by the sampling flags. If -sampling-rules is set, its
+// rules choose the ratio per endpoint and the -sampler is used for requests no rule matches.
+// Requests started with forcesample.With() are always sampled.
+func samplerFromFlags() (sdktrace.Sampler, error) {
+	base, err := baseSampler()
+	if err != nil {
+		return nil, err
+	}
+	if telemetryflags.IsRatioSampler() {
+		// The ratio can be changed by Reload.
+		ratioSampler = newSwappableSampler(base)
+		base = ratioSampler
+	}
+	if *samplingRules == "" {
+		return forcesample.New(base), nil
+	}
+
+	rules, err := sampler.LoadRules(*samplingRules)
+	if err != nil {
+		return nil, err
+	}
+	e, err := sampler.NewEndpoint(rules, base)
+	if err != nil {
+		return nil, fmt.Errorf("-sampling-rules: %w", err)
+	}
+	return forcesample.New(sdktrace.ParentBased(e)), nil
+}
+
+// baseSampler returns the sdktrace.Sampler named by -sampler: one of those of telemetryflags.Sampler, or
+// 'ratelimiting' where -sampler-arg is the number of traces sampled per second, 'adaptive' where -sampler-arg
+// is the lowest ratio sampled, or 'jaeger_remote'.
+func baseSampler() (sdktrace.Sampler, error) {
+	switch strings.ToLower(*samplerName) {
+	case "adaptive":
+		min, err := telemetryflags.SamplerRatio()
+		if err != nil {
+			return nil, err
+		}
+		a, err := sampler.NewAdaptive(min, *adaptiveSamplerMax)
+		if err != nil {
+			return nil, fmt.Errorf("-sampler=adaptive: %w", err)
+		}
+		adaptiveSampler = a
+		return sdktrace.ParentBased(a), nil
+	case "jaeger_remote", "parentbased_jaeger_remote":
+		return jaegerRemoteSampler()
+	case "ratelimiting", "parentbased_ratelimiting":
+		// Rate limiting only makes sense for root spans, the children of a sampled span must be
+		// sampled too or the trace is broken. So both names are parent based.
+		perSecond, err := strconv.ParseFloat(*samplerArg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("-sampler-arg=%s is not a valid number of traces per second: %w", *samplerArg, err)
+		}
+		rl, err := sampler.NewRateLimited(perSecond)
+		if err != nil {
+			return nil, fmt.Errorf("-sampler-arg: %w", err)
+		}
+		registerRateLimitedMetrics(rl)
+		return sdktrace.ParentBased(rl), nil
+	}
+	return telemetryflags.Sampler()
+}
+
+// jaegerRemoteSampler returns a parent based sampler using the strategy a Jaeger agent serves for the
+// demo client. -sampler-arg is in the OTEL_TRACES_SAMPLER_ARG format for jaeger_remote, a comma separated
+// list of endpoint, pollingIntervalMs and initialSamplingRate, like
+// 'endpoint=http://localhost:5778/sampling,pollingIntervalMs=5000,initialSamplingRate=0.25'.
+func jaegerRemoteSampler() (sdktrace.Sampler, error) {
+	endpoint := "http://localhost:5778/sampling"
+	interval := time.Minute
+	initial := 0.001
+
+	for _, pair := range strings.Split(*samplerArg, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			// The default -sampler-arg is a ratio, which doesn't apply here.
+			continue
+		}
+		switch kv[0] {
+		case "endpoint":
+			endpoint = kv[1]
+		case "pollingIntervalMs":
+			ms, err := strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("-sampler-arg: pollingIntervalMs=%s is not a number", kv[1])
+			}
+			interval = time.Duration(ms) * time.Millisecond
+		case "initialSamplingRate":
+			r, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("-sampler-arg: initialSamplingRate=%s is not a number", kv[1])
+			}
+			initial = r
+		default:
+			return nil, fmt.Errorf("-sampler-arg: %q is not a valid jaeger_remote argument", kv[0])
+		}
+	}
+
+	r, err := sampler.NewRemote(endpoint, serviceName, interval, sdktrace.TraceIDRatioBased(initial))
+	if err != nil {
+		return nil, fmt.Errorf("-sampler=jaeger_remote: %w", err)
+	}
+	remoteSampler = r
+	return sdktrace.ParentBased(r), nil
+}
+
+// registerRateLimitedMetrics reports the decisions of rl as metrics, so the rate traces are sampled
+// at can be compared to the limit.
+func registerRateLimitedMetrics(rl *sampler.RateLimited) {
+	meter := metric.Must(global.Meter(meterName))
+
+	meter.NewInt64CounterObserver(
+		"demo_client/sampler/sampled",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(rl.Sampled())
+		},
+		metric.WithDescription("The number of traces sampled by the rate limiting sampler"),
+	)
+	meter.NewInt64CounterObserver(
+		"demo_client/sampler/dropped",
+		func(_ context.Context, result metric.Int64ObserverResult) {
+			result.Observe(rl.Dropped())
+		},
+		metric.WithDescription("The number of traces dropped by the rate limiting sampler"),
+	)
+	meter.NewFloat64GaugeObserver(
+		"demo_client/sampler/limit",
+		func(_ context.Context, result metric.Float64ObserverResult) {
+			result.Observe(rl.Limit())
+		},
+		metric.WithDescription("The most traces per second the rate limiting sampler samples"),
+	)
+}
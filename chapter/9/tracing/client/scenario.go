@@ -0,0 +1,226 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/spanlink"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// Scenario is a sequence of requests run in order, like a user logging in and then placing an order.
+// Each run of a Scenario is one trace, with a span for each step. Scenarios are read from YAML or JSON:
+//
+//	name: checkout
+//	steps:
+//	  - name: hello
+//	    url: /hello
+//	    think_time: 500ms
+//	    expect:
+//	      status: 200
+//	      body_contains: Hello
+//	      max_latency: 200ms
+//	  - name: order
+//	    method: POST
+//	    url: http://server:7080/hello?order={{seq}}
+//	    body: '{"id": "{{uuid}}"}'
+type Scenario struct {
+	// Name is the name of the scenario, recorded as the demo.scenario attribute.
+	Name string `yaml:"name"`
+	// Steps are the requests of the scenario.
+	Steps []Step `yaml:"steps"`
+
+	// anchor is the span of the load run the scenario is run in, set by startRun.
+	anchor trace.SpanContext
+}
+
+// Step is one request of a Scenario.
+type Step struct {
+	// Name is the name of the step's span.
+	Name string `yaml:"name"`
+	// Method is the HTTP method of the request. Defaults to GET.
+	Method string `yaml:"method"`
+	// URL is the URL of the request. A URL with only a path, like /hello, is sent to the target the
+	// scenario is run against. URLs are templates, like request bodies.
+	URL string `yaml:"url"`
+	// Body is a template rendered as the request body, see -request-body.
+	Body string `yaml:"body"`
+	// ThinkTime is how long to wait after the step before running the next one.
+	ThinkTime time.Duration `yaml:"think_time"`
+	// Expect are assertions about the response. A step whose assertions fail ends the scenario.
+	Expect Expect `yaml:"expect"`
+
+	url  *template.Template
+	body *template.Template
+}
+
+// LoadScenario reads a Scenario from the YAML or JSON file at p.
+func LoadScenario(p string) (*Scenario, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scenario{}
+	// JSON is YAML, so one parser reads both.
+	if err := yaml.UnmarshalStrict(b, s); err != nil {
+		return nil, fmt.Errorf("could not parse scenario file %s: %w", p, err)
+	}
+
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("scenario file %s has no steps", p)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if step.Method == "" {
+			step.Method = http.MethodGet
+		}
+		if step.URL == "" {
+			return nil, fmt.Errorf("scenario %s: %s has no url", s.Name, step.Name)
+		}
+		if step.url, err = parseBody(step.URL); err != nil {
+			return nil, fmt.Errorf("scenario %s: %s has an invalid url: %w", s.Name, step.Name, err)
+		}
+		if step.Body != "" {
+			if step.body, err = parseBody(step.Body); err != nil {
+				return nil, fmt.Errorf("scenario %s: %s has an invalid body: %w", s.Name, step.Name, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// startRun starts the "Scenario run" span of a load run of s, which is always sampled and lasts until the
+// returned func is called with the run's totals. Every trace s.run starts until then links to it with the
+// spanlink.Run relation, so the whole run can be navigated from that one trace.
+func (s *Scenario) startRun(tracer trace.Tracer) func(stats *loadStats) {
+	_, span := tracer.Start(
+		forcesample.With(withBaggage(parentCtx)),
+		"Scenario run "+s.Name,
+		trace.WithAttributes(attribute.String("demo.scenario", s.Name)),
+	)
+	s.anchor = span.SpanContext()
+	return func(stats *loadStats) {
+		stats.mu.Lock()
+		span.SetAttributes(
+			attribute.Int("demo.run.sent", stats.sent),
+			attribute.Int("demo.run.failed", stats.failed),
+			attribute.Int("demo.run.dropped", stats.dropped),
+		)
+		if stats.failed > 0 {
+			span.SetStatus(codes.Error, fmt.Sprintf("%d of %d runs failed", stats.failed, stats.sent))
+		}
+		stats.mu.Unlock()
+		span.End()
+		s.anchor = trace.SpanContext{}
+	}
+}
+
+// run runs the steps of s against target in a new trace, stopping at the first that fails. The error of
+// the failed step is returned. During a load run started by startRun, the trace links to the run's span.
+func (s *Scenario) run(tracer trace.Tracer, log *zap.Logger, instruments ClientInstruments, target Target) error {
+	// Like single requests, scenarios finish when a signal arrives so their spans are exported.
+	ctx := withBaggage(parentCtx)
+	if *debugTrace {
+		ctx = forcesample.With(ctx)
+	}
+	ctx, span := tracer.Start(
+		ctx,
+		"Scenario "+s.Name,
+		trace.WithAttributes(
+			attribute.String("demo.scenario", s.Name),
+			attribute.String("demo.target", target.URL),
+		),
+		trace.WithAttributes(baggageAttributes(ctx)...),
+		trace.WithAttributes(propagators.MeshAttributes(ctx, nil)...),
+		trace.WithLinks(s.links()...),
+	)
+	defer span.End()
+
+	for i, step := range s.Steps {
+		if err := step.run(ctx, tracer, log, instruments, target); err != nil {
+			span.SetStatus(codes.Error, fmt.Sprintf("%s failed", step.Name))
+			if adaptiveSampler != nil {
+				adaptiveSampler.Record(true)
+			}
+			return err
+		}
+		if step.ThinkTime > 0 && i < len(s.Steps)-1 {
+			time.Sleep(step.ThinkTime)
+		}
+	}
+	if adaptiveSampler != nil {
+		adaptiveSampler.Record(false)
+	}
+	return nil
+}
+
+// links returns the link of a run of s to the span of its load run, if there is one.
+func (s *Scenario) links() []trace.Link {
+	if !s.anchor.IsValid() {
+		return nil
+	}
+	return []trace.Link{spanlink.To(s.anchor, spanlink.Run)}
+}
+
+// run sends the step's request to target in a child span of ctx's span.
+func (s Step) run(ctx context.Context, tracer trace.Tracer, log *zap.Logger, instruments ClientInstruments, target Target) error {
+	rawURL, err := renderBody(s.url)
+	if err != nil {
+		return err
+	}
+	u, err := stepURL(target.URL, string(bytes.TrimSpace(rawURL)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+
+	ctx, span := tracer.Start(
+		ctx,
+		s.Name,
+		trace.WithAttributes(
+			semconv.HTTPURLKey.String(u),
+			attribute.String("demo.step", s.Name),
+		),
+	)
+	defer span.End()
+
+	err = makeRequest(ctx, log.With(Ctx(ctx)), instruments, request{method: s.Method, url: u, body: s.body, check: s.Expect.check})
+	if err != nil {
+		recordRequestError(ctx, log.With(zap.String("step", s.Name)), u, err)
+		return err
+	}
+	return nil
+}
+
+// stepURL returns step resolved against target, so a step with only a path is sent to target's host.
+func stepURL(target, step string) (string, error) {
+	base, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(step)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid URL", step)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestLoadScenario(t *testing.T) {
+	// step is the part of a Step that is read from the file.
+	type step struct {
+		Name, Method, URL, Body string
+		ThinkTime               time.Duration
+	}
+
+	tests := []struct {
+		desc string
+		file string
+		data string
+		// wantName is the name of the scenario, and wantSteps its steps in the order they run.
+		wantName  string
+		wantSteps []step
+		wantErr   bool
+	}{
+		{
+			desc: "YAML",
+			file: "checkout.yaml",
+			data: `
+name: checkout
+steps:
+  - name: hello
+    url: /hello
+    think_time: 500ms
+  - name: order
+    method: POST
+    url: http://server:7080/hello?order={{seq}}
+    body: '{"id": "{{uuid}}"}'
+  - name: confirm
+    url: /hello?confirm=1
+`,
+			wantName: "checkout",
+			wantSteps: []step{
+				{Name: "hello", Method: "GET", URL: "/hello", ThinkTime: 500 * time.Millisecond},
+				{Name: "order", Method: "POST", URL: "http://server:7080/hello?order={{seq}}", Body: `{"id": "{{uuid}}"}`},
+				{Name: "confirm", Method: "GET", URL: "/hello?confirm=1"},
+			},
+		},
+		{
+			desc:     "JSON",
+			file:     "browse.json",
+			data:     `{"name": "browse", "steps": [{"name": "first", "url": "/a"}, {"name": "second", "url": "/b"}]}`,
+			wantName: "browse",
+			wantSteps: []step{
+				{Name: "first", Method: "GET", URL: "/a"},
+				{Name: "second", Method: "GET", URL: "/b"},
+			},
+		},
+		{
+			desc: "Names default to the file and the step's position",
+			file: "login.yaml",
+			data: `
+steps:
+  - url: /login
+  - url: /hello
+`,
+			wantName: "login",
+			wantSteps: []step{
+				{Name: "step 1", Method: "GET", URL: "/login"},
+				{Name: "step 2", Method: "GET", URL: "/hello"},
+			},
+		},
+		{
+			desc:    "No steps",
+			file:    "empty.yaml",
+			data:    "name: empty\n",
+			wantErr: true,
+		},
+		{
+			desc:    "Step without a url",
+			file:    "nourl.yaml",
+			data:    "steps:\n  - name: hello\n",
+			wantErr: true,
+		},
+		{
+			desc:    "Unknown field",
+			file:    "typo.yaml",
+			data:    "steps:\n  - url: /hello\n    thinktime: 1s\n",
+			wantErr: true,
+		},
+		{
+			desc:    "Invalid body template",
+			file:    "template.yaml",
+			data:    "steps:\n  - url: /hello\n    body: '{{uuid'\n",
+			wantErr: true,
+		},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		p := filepath.Join(dir, test.file)
+		if err := os.WriteFile(p, []byte(test.data), 0o644); err != nil {
+			t.Fatalf("TestLoadScenario(%s): %s", test.desc, err)
+		}
+
+		s, err := LoadScenario(p)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestLoadScenario(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestLoadScenario(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if s.Name != test.wantName {
+			t.Errorf("TestLoadScenario(%s): got name %q, want %q", test.desc, s.Name, test.wantName)
+		}
+		var got []step
+		for _, st := range s.Steps {
+			got = append(got, step{Name: st.Name, Method: st.Method, URL: st.URL, Body: st.Body, ThinkTime: st.ThinkTime})
+			if st.url == nil || (st.Body != "") != (st.body != nil) {
+				t.Errorf("TestLoadScenario(%s): %s: the url and body templates weren't parsed", test.desc, st.Name)
+			}
+		}
+		if diff := pretty.Compare(test.wantSteps, got); diff != "" {
+			t.Errorf("TestLoadScenario(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestStepURL(t *testing.T) {
+	tests := []struct {
+		desc   string
+		target string
+		step   string
+		want   string
+	}{
+		{desc: "Path is sent to the target", target: "http://server:7080/hello", step: "/order?id=1", want: "http://server:7080/order?id=1"},
+		{desc: "Absolute URL is kept", target: "http://server:7080", step: "https://other:8443/hello", want: "https://other:8443/hello"},
+	}
+
+	for _, test := range tests {
+		got, err := stepURL(test.target, test.step)
+		if err != nil {
+			t.Errorf("TestStepURL(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestStepURL(%s): got %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
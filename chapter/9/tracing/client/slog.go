@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SlogCorrelationHandler is a slog.Handler that adds the span and trace IDs of the span in a record's
+// context, like WithCorrelation does for zap. Use the Context variants of the slog functions, like
+// slog.InfoContext(), so the handler can find the span.
+type SlogCorrelationHandler struct {
+	slog.Handler
+}
+
+// NewSlogCorrelationHandler returns a SlogCorrelationHandler that passes records to h.
+func NewSlogCorrelationHandler(h slog.Handler) *SlogCorrelationHandler {
+	return &SlogCorrelationHandler{Handler: h}
+}
+
+// Handle implements slog.Handler.Handle.
+func (h *SlogCorrelationHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("span_id", convertTraceID(sc.SpanID().String())),
+			slog.String("trace_id", convertTraceID(sc.TraceID().String())),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.WithAttrs.
+func (h *SlogCorrelationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SlogCorrelationHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.WithGroup.
+func (h *SlogCorrelationHandler) WithGroup(name string) slog.Handler {
+	return &SlogCorrelationHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// setDefaultSlog makes the default slog logger write JSON to stderr at the zap logger's level, with the
+// span and trace IDs added by SlogCorrelationHandler. Libraries that log with slog get the same
+// correlation as the client's zap logs.
+func setDefaultSlog() {
+	h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slogLevel{}})
+	slog.SetDefault(slog.New(NewSlogCorrelationHandler(h)))
+}
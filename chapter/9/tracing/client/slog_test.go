@@ -0,0 +1,84 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		level zapcore.Level
+		want  slog.Level
+	}{
+		{level: zapcore.DebugLevel, want: slog.LevelDebug},
+		{level: zapcore.InfoLevel, want: slog.LevelInfo},
+		{level: zapcore.WarnLevel, want: slog.LevelWarn},
+		{level: zapcore.ErrorLevel, want: slog.LevelError},
+		// slog has no levels above error.
+		{level: zapcore.DPanicLevel, want: slog.LevelError},
+		{level: zapcore.FatalLevel, want: slog.LevelError},
+	}
+
+	old := logAtomicLevel.Level()
+	defer logAtomicLevel.SetLevel(old)
+
+	for _, test := range tests {
+		logAtomicLevel.SetLevel(test.level)
+		if got := (slogLevel{}).Level(); got != test.want {
+			t.Errorf("TestSlogLevel(%s): got %s, want %s", test.level, got, test.want)
+		}
+	}
+}
+
+func TestSlogCorrelationHandler(t *testing.T) {
+	// The IDs are logged as the decimal value of their low 64 bits, see convertTraceID.
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{15: 42}, SpanID: trace.SpanID{7: 7}})
+
+	tests := []struct {
+		desc string
+		ctx  context.Context
+		want map[string]interface{}
+	}{
+		{
+			desc: "No span",
+			ctx:  context.Background(),
+			want: map[string]interface{}{"msg": "request sent", "worker": float64(1)},
+		},
+		{
+			desc: "Span in the context",
+			ctx:  trace.ContextWithSpanContext(context.Background(), sc),
+			want: map[string]interface{}{"msg": "request sent", "worker": float64(1), "span_id": "7", "trace_id": "42"},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		h := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+			// Drop the time and level, which aren't under test.
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})
+		// The attributes added by With go through WithAttrs, which must keep the correlation.
+		log := slog.New(NewSlogCorrelationHandler(h)).With("worker", 1)
+		log.InfoContext(test.ctx, "request sent")
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("TestSlogCorrelationHandler(%s): got invalid JSON %q: %s", test.desc, buf.String(), err)
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestSlogCorrelationHandler(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
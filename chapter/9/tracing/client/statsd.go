@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// statsd is set when -metrics-exporter is 'statsd' or 'dogstatsd'. The request loop sends request
+// counters and timers to it.
+var statsd *statsdClient
+
+// recordStatsd sends the count, failure and latency of a request to statsd, if it is set.
+func recordStatsd(host, class string, latency time.Duration, failed bool) {
+	if statsd == nil {
+		return
+	}
+	tags := []string{"http_host:" + host, "http_status_class:" + class}
+	statsd.count("request.count", 1, tags...)
+	if failed {
+		statsd.count("request.errors", 1, tags...)
+	}
+	statsd.timing("request.latency", latency, tags...)
+}
+
+// statsdClient sends metrics over UDP in the StatsD line format. In DogStatsD mode, tags are appended
+// to each line. Plain StatsD has no tags, so they are dropped.
+type statsdClient struct {
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool
+}
+
+// newStatsdClient creates a statsdClient that sends to addr, a host:port. UDP is connectionless, so this
+// only fails if addr can't be resolved.
+func newStatsdClient(addr, prefix string, dogstatsd bool) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("-statsd-addr: %w", err)
+	}
+	return &statsdClient{conn: conn, prefix: prefix, dogstatsd: dogstatsd}, nil
+}
+
+// count adds v to the counter name.
+func (s *statsdClient) count(name string, v int64, tags ...string) {
+	s.send(s.line(name, fmt.Sprintf("%d", v), "c", tags))
+}
+
+// timing records d in the timer name.
+func (s *statsdClient) timing(name string, d time.Duration, tags ...string) {
+	s.send(s.line(name, fmt.Sprintf("%g", float64(d)/float64(time.Millisecond)), "ms", tags))
+}
+
+// line formats a metric in the StatsD line format, like "demo_client.requests:1|c|#host:a".
+// tags are key:value pairs.
+func (s *statsdClient) line(name, value, typ string, tags []string) string {
+	l := fmt.Sprintf("%s%s:%s|%s", s.prefix, name, value, typ)
+	if s.dogstatsd && len(tags) > 0 {
+		l += "|#" + strings.Join(tags, ",")
+	}
+	return l
+}
+
+// send writes a line to the StatsD server. A lost metric isn't worth failing a request over, so errors
+// are only reported.
+func (s *statsdClient) send(line string) {
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		otel.Handle(fmt.Errorf("failed to send StatsD metric: %w", err))
+	}
+}
+
+// close closes the connection to the StatsD server.
+func (s *statsdClient) close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestStatsdLine(t *testing.T) {
+	tests := []struct {
+		desc      string
+		dogstatsd bool
+		name      string
+		value     string
+		typ       string
+		tags      []string
+		want      string
+	}{
+		{
+			desc:  "Counter",
+			name:  "requests",
+			value: "1",
+			typ:   "c",
+			want:  "demo_client.requests:1|c",
+		},
+		{
+			desc:  "Tags are dropped for StatsD",
+			name:  "latency",
+			value: "12.5",
+			typ:   "ms",
+			tags:  []string{"host:server"},
+			want:  "demo_client.latency:12.5|ms",
+		},
+		{
+			desc:      "Tags are added for DogStatsD",
+			dogstatsd: true,
+			name:      "requests",
+			value:     "1",
+			typ:       "c",
+			tags:      []string{"host:server", "status_class:2xx"},
+			want:      "demo_client.requests:1|c|#host:server,status_class:2xx",
+		},
+		{
+			desc:      "DogStatsD without tags",
+			dogstatsd: true,
+			name:      "requests",
+			value:     "1",
+			typ:       "c",
+			want:      "demo_client.requests:1|c",
+		},
+	}
+
+	for _, test := range tests {
+		s := &statsdClient{prefix: "demo_client.", dogstatsd: test.dogstatsd}
+		if got := s.line(test.name, test.value, test.typ, test.tags); got != test.want {
+			t.Errorf("TestStatsdLine(%s): got %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// tailSampler is a sdktrace.SpanProcessor that holds the spans of each trace until the trace's local
+// root span ends. The trace is then passed on to the next processors only if one of its spans has an
+// error status or the root span took longer than latency, or its trace ID is sampled by a ratio of the traces,
+// so the normal traces they stand out from are kept too. This is tail based sampling: the decision is made
+// with the whole trace in hand, so the interesting traces a head sampler would mostly drop are kept.
+//
+// Spans are grouped by their local root rather than by trace ID alone, so requests that continue the
+// same remote parent, and so share a trace ID, are decided on separately.
+//
+// For this to see every trace, the tracer provider's sampler must sample everything (always_on).
+type tailSampler struct {
+	next      []sdktrace.SpanProcessor
+	latency   time.Duration
+	wait      time.Duration
+	ratio     sdktrace.Sampler
+	maxTraces int
+
+	mu     sync.Mutex
+	traces map[spanKey]*pendingTrace
+	// open are the spans that have started and not ended yet.
+	open map[spanKey]openSpan
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// spanKey identifies a span. The key of a local root span is also the key of its pendingTrace.
+type spanKey struct {
+	traceID trace.TraceID
+	spanID  trace.SpanID
+}
+
+// openSpan is a span that has started and not ended yet.
+type openSpan struct {
+	// root is the span ID of the local root span the span is under.
+	root    trace.SpanID
+	started time.Time
+}
+
+// pendingTrace is the part of a trace under a local root span that has not ended yet.
+type pendingTrace struct {
+	spans   []sdktrace.ReadOnlySpan
+	keep    bool
+	created time.Time
+}
+
+// newTailSampler creates a tailSampler that forwards kept traces to next. Traces whose root span
+// doesn't end within wait are decided on with the spans received so far. ratio of the other traces are
+// kept, the same ones sdktrace.TraceIDRatioBased samples. At most maxTraces are held, spans for new traces
+// are dropped after that.
+func newTailSampler(next []sdktrace.SpanProcessor, latency, wait time.Duration, ratio float64, maxTraces int) *tailSampler {
+	t := &tailSampler{
+		next:      next,
+		latency:   latency,
+		wait:      wait,
+		ratio:     sdktrace.TraceIDRatioBased(ratio),
+		maxTraces: maxTraces,
+		traces:    map[spanKey]*pendingTrace{},
+		open:      map[spanKey]openSpan{},
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go t.evictLoop()
+	return t
+}
+
+// OnStart implements sdktrace.SpanProcessor.OnStart. The local root the span is under is looked up from its
+// parent, so OnEnd knows which trace the span belongs to.
+func (t *tailSampler) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	sc := s.SpanContext()
+	root := sc.SpanID()
+	t.mu.Lock()
+	if p := s.Parent(); p.IsValid() && !p.IsRemote() {
+		root = t.rootOf(p)
+	}
+	t.open[spanKey{sc.TraceID(), sc.SpanID()}] = openSpan{root: root, started: time.Now()}
+	t.mu.Unlock()
+
+	for _, sp := range t.next {
+		sp.OnStart(parent, s)
+	}
+}
+
+// rootOf returns the span ID of the local root span that the local span p is, or is under. t.mu must be held.
+func (t *tailSampler) rootOf(p trace.SpanContext) trace.SpanID {
+	if o, ok := t.open[spanKey{p.TraceID(), p.SpanID()}]; ok {
+		return o.root
+	}
+	// The parent has already ended, or started before the tail sampler saw it. It is taken as the root,
+	// which it is in the common case of a request span and its children.
+	return p.SpanID()
+}
+
+// OnEnd implements sdktrace.SpanProcessor.OnEnd.
+func (t *tailSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	sc := s.SpanContext()
+	isRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+
+	t.mu.Lock()
+	self := spanKey{sc.TraceID(), sc.SpanID()}
+	root := sc.SpanID()
+	if o, ok := t.open[self]; ok {
+		root = o.root
+		delete(t.open, self)
+	} else if !isRoot {
+		root = t.rootOf(s.Parent())
+	}
+	id := spanKey{sc.TraceID(), root}
+	pt, ok := t.traces[id]
+	if !ok {
+		if len(t.traces) >= t.maxTraces {
+			t.mu.Unlock()
+			logger.Debug("tail sampler is full, dropping span", zap.String("trace_id", id.traceID.String()))
+			return
+		}
+		keep := t.ratio.ShouldSample(sdktrace.SamplingParameters{TraceID: id.traceID}).Decision == sdktrace.RecordAndSample
+		pt = &pendingTrace{created: time.Now(), keep: keep}
+		t.traces[id] = pt
+	}
+	pt.spans = append(pt.spans, s)
+	if s.Status().Code == codes.Error {
+		pt.keep = true
+	}
+	if isRoot && s.EndTime().Sub(s.StartTime()) > t.latency {
+		pt.keep = true
+	}
+	if !isRoot {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.traces, id)
+	t.mu.Unlock()
+
+	t.forward(pt)
+}
+
+// forward passes the spans of pt to the next processors if the trace is being kept.
+func (t *tailSampler) forward(pt *pendingTrace) {
+	if !pt.keep {
+		return
+	}
+	for _, s := range pt.spans {
+		for _, sp := range t.next {
+			sp.OnEnd(s)
+		}
+	}
+}
+
+// minEvictInterval is the least time between the checks of evictLoop, however short the wait is.
+const minEvictInterval = 10 * time.Millisecond
+
+// evictLoop decides on traces that have been pending longer than wait, which happens when the root
+// span is never ended or ends in another process.
+func (t *tailSampler) evictLoop() {
+	defer close(t.stopped)
+
+	interval := t.wait / 2
+	if interval < minEvictInterval {
+		interval = minEvictInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			olderThan := time.Now().Add(-t.wait)
+			t.flush(olderThan)
+			t.forgetOpen(olderThan)
+		}
+	}
+}
+
+// flush decides on all traces created before olderThan.
+func (t *tailSampler) flush(olderThan time.Time) {
+	var expired []*pendingTrace
+
+	t.mu.Lock()
+	for id, pt := range t.traces {
+		if pt.created.Before(olderThan) {
+			expired = append(expired, pt)
+			delete(t.traces, id)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, pt := range expired {
+		t.forward(pt)
+	}
+}
+
+// forgetOpen forgets the open spans started before olderThan, so spans that are never ended aren't held
+// forever. Their traces have been decided on by then.
+func (t *tailSampler) forgetOpen(olderThan time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, o := range t.open {
+		if o.started.Before(olderThan) {
+			delete(t.open, k)
+		}
+	}
+}
+
+// ForceFlush implements sdktrace.SpanProcessor.ForceFlush. Pending traces are decided on with the
+// spans received so far. All the next processors are flushed, even if one fails.
+func (t *tailSampler) ForceFlush(ctx context.Context) error {
+	t.flush(time.Now().Add(time.Hour))
+	var errs []error
+	for _, sp := range t.next {
+		errs = append(errs, sp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown implements sdktrace.SpanProcessor.Shutdown. All the next processors are shut down, even if
+// one fails.
+func (t *tailSampler) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.stop) })
+	<-t.stopped
+
+	t.flush(time.Now().Add(time.Hour))
+	var errs []error
+	for _, sp := range t.next {
+		errs = append(errs, sp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
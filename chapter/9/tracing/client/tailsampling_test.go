@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingProcessor is a sdktrace.SpanProcessor that records the spans it is handed.
+type recordingProcessor struct {
+	sdktrace.SpanProcessor
+
+	mu    sync.Mutex
+	ended []sdktrace.ReadOnlySpan
+}
+
+func (p *recordingProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *recordingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ended = append(p.ended, s)
+}
+
+func (p *recordingProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *recordingProcessor) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ended)
+}
+
+// failingProcessor is a sdktrace.SpanProcessor whose ForceFlush and Shutdown fail with err. calls counts them.
+type failingProcessor struct {
+	sdktrace.SpanProcessor
+
+	err   error
+	calls int
+}
+
+func (p *failingProcessor) ForceFlush(context.Context) error {
+	p.calls++
+	return p.err
+}
+
+func (p *failingProcessor) Shutdown(context.Context) error {
+	p.calls++
+	return p.err
+}
+
+// testSpan returns an ended span of the trace id that took d. It is the trace's root if parent is 0.
+func testSpan(id trace.TraceID, spanID, parent byte, d time.Duration, code codes.Code) sdktrace.ReadOnlySpan {
+	start := time.Now()
+	stub := tracetest.SpanStub{
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: id, SpanID: trace.SpanID{spanID}, TraceFlags: trace.FlagsSampled}),
+		StartTime:   start,
+		EndTime:     start.Add(d),
+		Status:      sdktrace.Status{Code: code},
+	}
+	if parent != 0 {
+		stub.Parent = trace.NewSpanContext(trace.SpanContextConfig{TraceID: id, SpanID: trace.SpanID{parent}, TraceFlags: trace.FlagsSampled})
+	}
+	return stub.Snapshot()
+}
+
+func TestTailSampler(t *testing.T) {
+	tests := []struct {
+		desc string
+		// spans end in order, the last is the root.
+		spans []sdktrace.ReadOnlySpan
+		ratio float64
+		want  int
+	}{
+		{
+			desc:  "Fast trace without errors is dropped",
+			spans: []sdktrace.ReadOnlySpan{testSpan(trace.TraceID{1}, 2, 1, time.Millisecond, codes.Ok), testSpan(trace.TraceID{1}, 1, 0, time.Millisecond, codes.Unset)},
+			want:  0,
+		},
+		{
+			desc:  "Trace with an error is kept",
+			spans: []sdktrace.ReadOnlySpan{testSpan(trace.TraceID{2}, 2, 1, time.Millisecond, codes.Error), testSpan(trace.TraceID{2}, 1, 0, time.Millisecond, codes.Unset)},
+			want:  2,
+		},
+		{
+			desc:  "Slow trace is kept",
+			spans: []sdktrace.ReadOnlySpan{testSpan(trace.TraceID{3}, 2, 1, time.Millisecond, codes.Unset), testSpan(trace.TraceID{3}, 1, 0, time.Second, codes.Unset)},
+			want:  2,
+		},
+		{
+			desc:  "Slow child of a fast root doesn't keep the trace",
+			spans: []sdktrace.ReadOnlySpan{testSpan(trace.TraceID{4}, 2, 1, time.Second, codes.Unset), testSpan(trace.TraceID{4}, 1, 0, time.Millisecond, codes.Unset)},
+			want:  0,
+		},
+		{
+			desc:  "Ratio of 1 keeps a fast trace",
+			spans: []sdktrace.ReadOnlySpan{testSpan(trace.TraceID{5}, 2, 1, time.Millisecond, codes.Unset), testSpan(trace.TraceID{5}, 1, 0, time.Millisecond, codes.Unset)},
+			ratio: 1,
+			want:  2,
+		},
+	}
+
+	for _, test := range tests {
+		next := &recordingProcessor{}
+		ts := newTailSampler([]sdktrace.SpanProcessor{next}, 500*time.Millisecond, time.Minute, test.ratio, 10)
+		for _, s := range test.spans {
+			ts.OnEnd(s)
+		}
+		if got := next.count(); got != test.want {
+			t.Errorf("TestTailSampler(%s): got %d spans exported, want %d", test.desc, got, test.want)
+		}
+		ts.Shutdown(context.Background())
+	}
+}
+
+func TestTailSamplerRatio(t *testing.T) {
+	const traces = 2000
+
+	next := &recordingProcessor{}
+	ts := newTailSampler([]sdktrace.SpanProcessor{next}, time.Hour, time.Minute, 0.25, traces)
+	defer ts.Shutdown(context.Background())
+
+	for i := 0; i < traces; i++ {
+		var id trace.TraceID
+		rand.Read(id[:])
+		ts.OnEnd(testSpan(id, 1, 0, time.Millisecond, codes.Unset))
+	}
+
+	// The trace IDs are random, so the kept share is close to the ratio, not exactly it.
+	if got := float64(next.count()) / traces; got < 0.2 || got > 0.3 {
+		t.Errorf("TestTailSamplerRatio: got %v of the traces kept, want about 0.25", got)
+	}
+}
+
+func TestTailSamplerEviction(t *testing.T) {
+	next := &recordingProcessor{}
+	// A wait this short used to make a ticker with a period of 0, which panics.
+	ts := newTailSampler([]sdktrace.SpanProcessor{next}, time.Hour, time.Nanosecond, 0, 10)
+	defer ts.Shutdown(context.Background())
+
+	// The root span never ends here, like one that ends in another process.
+	ts.OnEnd(testSpan(trace.TraceID{1}, 2, 1, time.Millisecond, codes.Error))
+	ts.OnEnd(testSpan(trace.TraceID{2}, 2, 1, time.Millisecond, codes.Unset))
+
+	// Only the trace with an error is kept when they are evicted.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ts.mu.Lock()
+		pending := len(ts.traces)
+		ts.mu.Unlock()
+		exported := next.count()
+		if pending == 0 && exported == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("TestTailSamplerEviction: got %d traces pending and %d spans exported, want 0 and 1", pending, exported)
+		}
+		time.Sleep(minEvictInterval)
+	}
+}
+
+func TestTailSamplerLocalRoots(t *testing.T) {
+	next := &recordingProcessor{}
+	ts := newTailSampler([]sdktrace.SpanProcessor{next}, time.Hour, time.Minute, 0, 10)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()), sdktrace.WithSpanProcessor(ts))
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	// Both requests continue the same remote parent, so they share a trace ID.
+	remote := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ctxA, a := tracer.Start(remote, "A")
+	ctxB, b := tracer.Start(remote, "B")
+	_, a1 := tracer.Start(ctxA, "A1")
+	_, b1 := tracer.Start(ctxB, "B1")
+
+	// Only B has an error, so only its spans are kept, although A ends while B's error is pending.
+	b1.SetStatus(codes.Error, "failed")
+	b1.End()
+	a1.End()
+	a.End()
+	b.End()
+
+	var got []string
+	for _, s := range next.ended {
+		got = append(got, s.Name())
+	}
+	if len(got) != 2 || got[0] != "B1" || got[1] != "B" {
+		t.Errorf("TestTailSamplerLocalRoots: got spans %v exported, want [B1 B]", got)
+	}
+}
+
+func TestTailSamplerShutdownErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	a, b := &failingProcessor{err: errA}, &failingProcessor{err: errB}
+	ts := newTailSampler([]sdktrace.SpanProcessor{a, b}, time.Hour, time.Minute, 0, 10)
+
+	err := ts.ForceFlush(context.Background())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("TestTailSamplerShutdownErrors: ForceFlush: got err == %v, want both processors' errors", err)
+	}
+	err = ts.Shutdown(context.Background())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("TestTailSamplerShutdownErrors: Shutdown: got err == %v, want both processors' errors", err)
+	}
+	if a.calls != 2 || b.calls != 2 {
+		t.Errorf("TestTailSamplerShutdownErrors: got %d and %d calls, want 2 each", a.calls, b.calls)
+	}
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Target is a URL requests are sent to.
+type Target struct {
+	// URL is the URL requests are sent to.
+	URL string `yaml:"url"`
+	// Weight is the target's share of the requests relative to the other targets. 0 is treated as 1.
+	Weight int `yaml:"weight"`
+}
+
+// Targets is the format of the -server-endpoints-file, like:
+//
+//	targets:
+//	  - url: http://server:7080/hello
+//	    weight: 3
+//	  - url: http://server:7080/hello?name=canary
+type Targets struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// targetsFromFlags returns the Targets in the -server-endpoints-file, or if it isn't set, in
+// -server-endpoint.
+func targetsFromFlags() ([]Target, error) {
+	if *serverEndpointsFile == "" {
+		t, err := parseTargets(*serverEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("-server-endpoint: %w", err)
+		}
+		return t, nil
+	}
+
+	b, err := os.ReadFile(*serverEndpointsFile)
+	if err != nil {
+		return nil, err
+	}
+	var t Targets
+	if err := yaml.UnmarshalStrict(b, &t); err != nil {
+		return nil, fmt.Errorf("could not parse targets file %s: %w", *serverEndpointsFile, err)
+	}
+	for _, target := range t.Targets {
+		if err := target.validate(); err != nil {
+			return nil, fmt.Errorf("-server-endpoints-file: %w", err)
+		}
+	}
+	if len(t.Targets) == 0 {
+		return nil, fmt.Errorf("-server-endpoints-file: %s has no targets", *serverEndpointsFile)
+	}
+	return t.Targets, nil
+}
+
+// parseTargets parses a comma separated list of URLs, each optionally followed by ";weight=N", like
+// "http://a:7080/hello;weight=3,http://b:7080/hello".
+func parseTargets(s string) ([]Target, error) {
+	var targets []Target
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		t := Target{URL: entry}
+		if i := strings.LastIndex(entry, ";weight="); i != -1 {
+			w, err := strconv.Atoi(entry[i+len(";weight="):])
+			if err != nil {
+				return nil, fmt.Errorf("%q has an invalid weight", entry)
+			}
+			t = Target{URL: entry[:i], Weight: w}
+		}
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no URLs")
+	}
+	return targets, nil
+}
+
+// validate returns an error if t's URL isn't an absolute URL or its weight is negative.
+func (t Target) validate() error {
+	u, err := url.Parse(t.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%q is not a valid URL", t.URL)
+	}
+	if t.Weight < 0 {
+		return fmt.Errorf("%q has a negative weight", t.URL)
+	}
+	return nil
+}
+
+// targetPicker picks the Target for each request by smooth weighted round robin, which interleaves the
+// targets instead of sending a target all of its requests in a row. It is safe for concurrent use.
+type targetPicker struct {
+	mu      sync.Mutex
+	targets []Target
+	current []int
+	total   int
+}
+
+// newTargetPicker returns a targetPicker for targets, which must not be empty.
+func newTargetPicker(targets []Target) *targetPicker {
+	p := &targetPicker{}
+	p.set(targets)
+	return p
+}
+
+// set replaces the targets, which must not be empty, and starts the round robin over.
+func (p *targetPicker) set(targets []Target) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.targets = make([]Target, len(targets))
+	p.current = make([]int, len(targets))
+	p.total = 0
+	for i, t := range targets {
+		if t.Weight == 0 {
+			t.Weight = 1
+		}
+		p.targets[i] = t
+		p.total += t.Weight
+	}
+}
+
+// next returns the Target the next request is sent to.
+func (p *targetPicker) next() Target {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	best := 0
+	for i, t := range p.targets {
+		p.current[i] += t.Weight
+		if p.current[i] > p.current[best] {
+			best = i
+		}
+	}
+	p.current[best] -= p.total
+	return p.targets[best]
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    []Target
+		wantErr bool
+	}{
+		{
+			desc: "Single URL",
+			s:    "http://0.0.0.0:7080/hello",
+			want: []Target{{URL: "http://0.0.0.0:7080/hello"}},
+		},
+		{
+			desc: "Weights and spaces",
+			s:    "http://a:7080/hello;weight=3, http://b:7080/hello?x=1 ,",
+			want: []Target{{URL: "http://a:7080/hello", Weight: 3}, {URL: "http://b:7080/hello?x=1"}},
+		},
+		{
+			desc:    "Invalid weight",
+			s:       "http://a:7080/hello;weight=x",
+			wantErr: true,
+		},
+		{
+			desc:    "Negative weight",
+			s:       "http://a:7080/hello;weight=-1",
+			wantErr: true,
+		},
+		{
+			desc:    "Not a URL",
+			s:       "a:7080",
+			wantErr: true,
+		},
+		{
+			desc:    "Empty",
+			s:       " , ",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseTargets(test.s)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseTargets(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseTargets(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestParseTargets(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestTargetPicker(t *testing.T) {
+	p := newTargetPicker([]Target{{URL: "a", Weight: 3}, {URL: "b"}, {URL: "c", Weight: 2}})
+
+	var got []string
+	for i := 0; i < 12; i++ {
+		got = append(got, p.next().URL)
+	}
+	want := []string{"a", "c", "a", "b", "c", "a", "a", "c", "a", "b", "c", "a"}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestTargetPicker: -want/+got:\n%s", diff)
+	}
+}
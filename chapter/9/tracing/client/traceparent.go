@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// parentCtx is the context requests' traces start from. With -traceparent it has the remote span the
+// client's spans are children of. It is set in Main.
+var parentCtx = context.Background()
+
+// initParentContext sets parentCtx from -traceparent and -tracestate.
+func initParentContext() error {
+	ctx, err := remoteParent(*traceParent, *traceState)
+	if err != nil {
+		return err
+	}
+	parentCtx = ctx
+	return nil
+}
+
+// remoteParent returns a context with the remote span of a W3C traceparent and tracestate, like the
+// TRACEPARENT and TRACESTATE environment variables CI systems and shell scripts set for the processes
+// they start. If parent is empty, it returns context.Background().
+func remoteParent(parent, state string) (context.Context, error) {
+	if parent == "" {
+		return context.Background(), nil
+	}
+	carrier := propagation.MapCarrier{"traceparent": parent}
+	if state != "" {
+		carrier["tracestate"] = state
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil, fmt.Errorf("-traceparent=%s is not a valid value", parent)
+	}
+	return ctx, nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRemoteParent(t *testing.T) {
+	tests := []struct {
+		desc        string
+		parent      string
+		state       string
+		wantTraceID string
+		wantSampled bool
+		wantErr     bool
+	}{
+		{
+			desc: "No parent",
+		},
+		{
+			desc:        "Sampled parent with state",
+			parent:      "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			state:       "ci=job-42",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSampled: true,
+		},
+		{
+			desc:        "Unsampled parent",
+			parent:      "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			desc:    "Invalid parent",
+			parent:  "00-xyz-00f067aa0ba902b7-01",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		ctx, err := remoteParent(test.parent, test.state)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestRemoteParent(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestRemoteParent(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		sc := trace.SpanContextFromContext(ctx)
+		if test.wantTraceID == "" {
+			if sc.IsValid() {
+				t.Errorf("TestRemoteParent(%s): got a parent span, want none", test.desc)
+			}
+			continue
+		}
+		if got := sc.TraceID().String(); got != test.wantTraceID {
+			t.Errorf("TestRemoteParent(%s): got trace ID %s, want %s", test.desc, got, test.wantTraceID)
+		}
+		if sc.IsSampled() != test.wantSampled {
+			t.Errorf("TestRemoteParent(%s): got sampled %v, want %v", test.desc, sc.IsSampled(), test.wantSampled)
+		}
+		if !sc.IsRemote() {
+			t.Errorf("TestRemoteParent(%s): got a local parent, want a remote one", test.desc)
+		}
+		if got := sc.TraceState().String(); got != test.state {
+			t.Errorf("TestRemoteParent(%s): got trace state %q, want %q", test.desc, got, test.state)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/http2"
+)
+
+// httpClient sends the requests to the server. It is replaced in Main with one set up by the
+// -http-* flags.
+var httpClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
+// newHTTPClient returns a client that sends requests with a transport whose connection pool and HTTP
+// version are set by the -http-* flags, and that uses the proxy set by -proxy, instrumented with otelhttp.
+func newHTTPClient() (*http.Client, error) {
+	if *httpMaxIdleConns < 0 || *httpMaxIdleConnsPerHost < 0 || *httpMaxConnsPerHost < 0 || *httpIdleConnTimeout < 0 {
+		return nil, fmt.Errorf("the -http-* connection pool flags cannot be negative")
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = *httpMaxIdleConns
+	t.MaxIdleConnsPerHost = *httpMaxIdleConnsPerHost
+	t.MaxConnsPerHost = *httpMaxConnsPerHost
+	t.IdleConnTimeout = *httpIdleConnTimeout
+	t.DisableKeepAlives = *httpDisableKeepAlives
+	proxy, err := proxyFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	t.Proxy = proxy
+
+	version := strings.ToLower(*httpVersion)
+	if *proxyURL != "" && (version == "2" || version == "h2c") {
+		// The HTTP/2 transport doesn't support proxies.
+		return nil, fmt.Errorf("-proxy cannot be used with -http-version=%s", *httpVersion)
+	}
+	var rt http.RoundTripper = t
+	switch version {
+	case "auto":
+	case "1.1":
+		// A non-nil, empty TLSNextProto turns off HTTP/2.
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case "2":
+		// Only HTTP/2 over TLS. The connection pool flags don't apply, requests to a host share a connection.
+		rt = &http2.Transport{TLSClientConfig: t.TLSClientConfig, DisableCompression: t.DisableCompression}
+	case "h2c":
+		// HTTP/2 without TLS, which servers must support with prior knowledge, like the demo server.
+		rt = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}
+	default:
+		return nil, fmt.Errorf("-http-version=%s is not a valid value", *httpVersion)
+	}
+	return &http.Client{Transport: otelhttp.NewTransport(rt)}, nil
+}
+
+// proxyFromFlags returns the Proxy of the transport, which sends requests through -proxy, except those to
+// the hosts in -no-proxy, or without -proxy through the proxies in the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// environment variables. The proxy a request is sent through is the net.proxy attribute of its span, so
+// the proxy hop can be told apart in the connect timings.
+func proxyFromFlags() (func(*http.Request) (*url.URL, error), error) {
+	proxy := http.ProxyFromEnvironment
+	if *proxyURL != "" {
+		u, err := url.Parse(*proxyURL)
+		if err != nil || u.Host == "" {
+			// Don't include the URL in the error, it can have a password.
+			return nil, fmt.Errorf("-proxy is not a valid URL")
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("-proxy scheme %q is not http, https or socks5", u.Scheme)
+		}
+		// Like with the environment variables, requests to localhost are never sent through the proxy.
+		proxyFunc := (&httpproxy.Config{HTTPProxy: *proxyURL, HTTPSProxy: *proxyURL, NoProxy: *noProxy}).ProxyFunc()
+		proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if u != nil {
+			trace.SpanFromContext(req.Context()).SetAttributes(attribute.String("net.proxy", u.Scheme+"://"+u.Host))
+		}
+		return u, err
+	}, nil
+}
+
+// withClientTrace returns ctx with an httptrace.ClientTrace that records the phases of the request on the
+// span in ctx, counts the connection the request is sent on in instruments.Connections, and records
+// whether it was reused from the pool.
+//
+// The DNS lookup, TCP connect and TLS handshake durations are the http.dns_ms, http.connect_ms and
+// http.tls_ms attributes, which requests on reused connections don't have, and the time from getting a
+// connection to the first byte of the response is http.ttfb_ms. The end of each phase is also an event,
+// so trace viewers show where in the span the time went.
+func withClientTrace(ctx context.Context, instruments ClientInstruments) context.Context {
+	span := trace.SpanFromContext(ctx)
+
+	// The hooks can be called from different goroutines, like ConnectStart for each address of a host.
+	var (
+		mu       sync.Mutex
+		start    time.Time
+		dnsStart time.Time
+		tlsStart time.Time
+		connects = map[string]time.Time{}
+	)
+	// phase records that the phase that began at begin ended, with its duration in the attribute key.
+	phase := func(key, event string, begin time.Time, err error, attrs ...attribute.KeyValue) {
+		ms := attribute.Float64(key, float64(time.Since(begin))/float64(time.Millisecond))
+		span.SetAttributes(ms)
+		attrs = append(attrs, ms)
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		}
+		span.AddEvent(event, trace.WithAttributes(attrs...))
+	}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			mu.Lock()
+			defer mu.Unlock()
+			start = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			span.SetAttributes(
+				attribute.Bool("net.conn.reused", info.Reused),
+				attribute.Bool("net.conn.was_idle", info.WasIdle),
+				attribute.Int64("net.conn.idle_time_ms", info.IdleTime.Milliseconds()),
+			)
+			instruments.Connections.Add(ctx, 1, attribute.Bool("reused", info.Reused))
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			phase("http.dns_ms", "dns lookup done", dnsStart, info.Err, attribute.Int("net.dns.addrs", len(info.Addrs)))
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connects[network+" "+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			phase("http.connect_ms", "connect done", connects[network+" "+addr], err, attribute.String("net.peer.addr", addr))
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			phase("http.tls_ms", "tls handshake done", tlsStart, err, attribute.String("tls.negotiated_protocol", state.NegotiatedProtocol))
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			phase("http.ttfb_ms", "first response byte", start, nil)
+		},
+	})
+}
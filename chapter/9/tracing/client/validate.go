@@ -0,0 +1,156 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
+)
+
+// Validate checks the endpoints, durations and ratios set by Flags and telemetryflags.FlagSet, and the
+// environment variables they default to, and returns a *validate.Error with every problem found, or nil.
+// Main calls it first, so a misconfigured client exits before sending anything rather than part way
+// through, or not at all with a default in place of a value it couldn't parse.
+func Validate() error {
+	var p validate.Problems
+	telemetryflags.Check(&p)
+	p.Env(Flags)
+
+	// Where requests are sent.
+	_, err := targetsFromFlags()
+	p.Add(err)
+	switch strings.ToLower(*protocol) {
+	case "", "http":
+	case "grpc":
+		if *grpcEndpoint == "" {
+			p.Addf("-grpc-endpoint must be set with -protocol=grpc")
+		}
+	default:
+		p.Addf("-protocol=%s is not a valid value, use 'http' or 'grpc'", *protocol)
+	}
+	if *grpcEndpoint != "" {
+		p.HostPort("grpc-endpoint", *grpcEndpoint)
+	}
+	if *grpcStreamMessages < 0 {
+		p.Addf("-grpc-stream-messages cannot be negative, was %d", *grpcStreamMessages)
+	}
+	if *kubeAPI {
+		if useGRPC() {
+			p.Addf("-kube-api cannot be used with -protocol=grpc")
+		}
+		p.NonNegative("kube-watch", *kubeWatch)
+		if *requestTimeout > 0 && *kubeWatch >= *requestTimeout {
+			p.Addf("-kube-watch=%s must be shorter than -request-timeout=%s, which would cancel every watch", *kubeWatch, *requestTimeout)
+		}
+	}
+
+	// How fast and for how long.
+	_, err = loadInterval(*requestInterval, *requestRate, *maxQPS)
+	p.Add(err)
+	_, err = parseLoadProfile(*loadProfileSpec)
+	p.Add(err)
+	p.NonNegative("file-reload-interval", *fileReloadInterval)
+	switch strings.ToLower(*trafficModel) {
+	case "closed":
+	case "poisson":
+		if *maxInFlight < 1 {
+			p.Addf("-max-in-flight must be at least 1, was %d", *maxInFlight)
+		}
+	default:
+		p.Addf("-traffic-model=%s is not a valid value, use 'closed' or 'poisson'", *trafficModel)
+	}
+	p.Add(pacer{distribution: strings.ToLower(*requestDistribution), jitter: *requestJitter}.validate())
+	if *requestCount < 0 {
+		p.Addf("-requests cannot be negative, was %d", *requestCount)
+	}
+	p.NonNegative("duration", *runDuration)
+	if *redisAddr != "" {
+		p.HostPort("redis-addr", *redisAddr)
+		if *redisKey == "" {
+			p.Addf("-redis-key must be set with -redis-addr")
+		}
+		if *globalQPS <= 0 {
+			p.Addf("-global-qps must be positive with -redis-addr, was %v", *globalQPS)
+		}
+		if *globalBurst < 1 {
+			p.Addf("-global-burst must be at least 1, was %d", *globalBurst)
+		}
+	}
+	if *concurrency < 1 {
+		p.Addf("-concurrency must be at least 1, was %d", *concurrency)
+	}
+	if *replayLog != "" && *replaySpeed <= 0 {
+		p.Addf("-replay-speed must be positive, was %v", *replaySpeed)
+	}
+
+	// How requests are sent, retried and checked.
+	p.Positive("request-timeout", *requestTimeout)
+	p.NonNegative("hedge-delay", *hedgeDelay)
+	_, err = retryPolicyFromFlags()
+	p.Add(err)
+	if *breakerFailures < 0 {
+		p.Addf("-circuit-breaker-failures cannot be negative, was %d", *breakerFailures)
+	}
+	p.Positive("circuit-breaker-cooldown", *breakerCooldown)
+	_, err = newHTTPClient()
+	p.Add(err)
+	_, err = expectFromFlags()
+	p.Add(err)
+	p.Ratio("max-error-rate", *maxErrorRate)
+
+	// How telemetry is sampled and exported, unless the standard environment variables alone set it up, and
+	// their own values are checked by telemetryflags.Check.
+	if !*otelEnvOnly {
+		checkTelemetry(&p)
+	}
+	p.NonNegative("health-max-request-age", *healthMaxRequestAge)
+	p.NonNegative("livez-max-dispatch-wait", *livenessMaxDispatchWait)
+	if *leaderElect {
+		if *leaderElectLease == "" {
+			p.Addf("-leader-elect-lease must be set with -leader-elect")
+		}
+		p.Positive("leader-elect-lease-duration", *leaderElectLeaseDuration)
+	}
+	if *tui && (*dryRun || strings.Contains(*exporterName, "stdout")) {
+		p.Addf("-tui cannot be used with -dry-run or -exporter=stdout, which print spans over the dashboard")
+	}
+	if !strings.Contains(*traceURL, "{traceID}") {
+		p.Addf("-trace-url=%s must contain {traceID}", *traceURL)
+	}
+
+	return p.Err()
+}
+
+// checkTelemetry adds the problems with the flags that configure the exporters and sampling to p.
+func checkTelemetry(p *validate.Problems) {
+	exporters, err := exportersFromFlags()
+	p.Add(err)
+	for _, e := range exporters {
+		if _, ok := e.(OTLPHTTP); !ok {
+			continue
+		}
+		if strings.Contains(*otlpHTTPEndpoint, "://") {
+			p.URL("otlp-http-endpoint", *otlpHTTPEndpoint)
+		} else {
+			p.HostPort("otlp-http-endpoint", *otlpHTTPEndpoint)
+		}
+		break
+	}
+	if strings.Contains(*exporterName, "zipkin") {
+		p.URL("zipkin-endpoint", *zipkinEndpoint)
+	}
+	switch strings.ToLower(*metricsExporter) {
+	case "pushgateway":
+		p.URL("pushgateway-url", *pushgatewayURL)
+	case "statsd", "dogstatsd":
+		p.HostPort("statsd-addr", *statsdAddr)
+	}
+	p.Ratio("adaptive-sampler-max", *adaptiveSamplerMax)
+	p.Positive("tail-sampling-latency", *tailSamplingLatency)
+	p.Positive("tail-sampling-decision-wait", *tailSamplingDecisionWait)
+	p.Ratio("tail-sampling-ratio", *tailSamplingRatio)
+	_, err = batchOptions()
+	p.Add(err)
+	_, err = spanLimits()
+	p.Add(err)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// watchFiles reads the files of the -scenario, -load-profile and -server-endpoints-file flags every
+// interval until ctx is done, and reloads the flags whose file changed. Files are compared by content,
+// not by modification time or events, because a mounted ConfigMap is updated by replacing a symlink to
+// the directory the files are in.
+func watchFiles(ctx context.Context, interval time.Duration) {
+	w := newFileWatcher(os.ReadFile)
+	w.changes()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if changes := w.changes(); len(changes) > 0 {
+			// Reload reports its own errors, and the client keeps running with the settings it has.
+			Reload(changes)
+		}
+	}
+}
+
+// fileWatcher finds the flags whose file changed since it last looked.
+type fileWatcher struct {
+	readFile func(name string) ([]byte, error)
+	// sums are the SHA-256 of the files, by path.
+	sums map[string][sha256.Size]byte
+}
+
+func newFileWatcher(readFile func(name string) ([]byte, error)) *fileWatcher {
+	return &fileWatcher{readFile: readFile, sums: map[string][sha256.Size]byte{}}
+}
+
+// changes returns the values of the flags whose file changed, keyed by flag name, for Reload. The first
+// time a path is seen, like after a flag is changed to a new file, it is only recorded. A file that
+// can't be read, like one being replaced, is looked at again next time.
+func (w *fileWatcher) changes() map[string]string {
+	changes := map[string]string{}
+	for name, path := range watchedFiles() {
+		b, err := w.readFile(path)
+		if err != nil {
+			logger.Warn("failed to read watched file", zap.String("flag", name), zap.String("path", path), zap.Error(err))
+			continue
+		}
+		sum := sha256.Sum256(b)
+		old, seen := w.sums[path]
+		w.sums[path] = sum
+		if seen && sum != old {
+			logger.Info("watched file changed", zap.String("flag", name), zap.String("path", path))
+			changes[name] = lookupFlag(name).Value.String()
+		}
+	}
+	return changes
+}
+
+// watchedFiles returns the paths of the files set by flags, keyed by flag name.
+func watchedFiles() map[string]string {
+	// Reload changes the flags.
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	files := map[string]string{}
+	if *scenarioFile != "" {
+		files["scenario"] = *scenarioFile
+	}
+	if s := *loadProfileSpec; s != "" && s[0] == '@' {
+		files["load-profile"] = s[1:]
+	}
+	if *serverEndpointsFile != "" {
+		files["server-endpoints-file"] = *serverEndpointsFile
+	}
+	return files
+}
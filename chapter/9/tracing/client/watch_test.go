@@ -0,0 +1,70 @@
+package client
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileWatcher(t *testing.T) {
+	oldScenario, oldProfile, oldEndpoints := *scenarioFile, *loadProfileSpec, *serverEndpointsFile
+	t.Cleanup(func() {
+		*scenarioFile, *loadProfileSpec, *serverEndpointsFile = oldScenario, oldProfile, oldEndpoints
+	})
+	*scenarioFile, *loadProfileSpec, *serverEndpointsFile = "/etc/demo/scenario.yaml", "@/etc/demo/load-profile", ""
+
+	files := map[string]string{
+		"/etc/demo/scenario.yaml": "name: checkout",
+		"/etc/demo/load-profile":  "ramp:1-50:5m",
+	}
+	w := newFileWatcher(func(name string) ([]byte, error) {
+		s, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(s), nil
+	})
+
+	steps := []struct {
+		desc  string
+		apply func()
+		want  map[string]string
+	}{
+		{desc: "First look", apply: func() {}},
+		{desc: "Unchanged", apply: func() {}},
+		{
+			desc:  "Load profile changed",
+			apply: func() { files["/etc/demo/load-profile"] = "step:10,20:1m" },
+			want:  map[string]string{"load-profile": "@/etc/demo/load-profile"},
+		},
+		{
+			desc:  "Scenario being replaced",
+			apply: func() { delete(files, "/etc/demo/scenario.yaml") },
+		},
+		{
+			desc:  "Scenario replaced",
+			apply: func() { files["/etc/demo/scenario.yaml"] = "name: browse" },
+			want:  map[string]string{"scenario": "/etc/demo/scenario.yaml"},
+		},
+		{
+			desc: "New file",
+			apply: func() {
+				*serverEndpointsFile = "/etc/demo/targets.yaml"
+				files["/etc/demo/targets.yaml"] = "targets: []"
+			},
+		},
+	}
+
+	for _, step := range steps {
+		step.apply()
+		got := w.changes()
+		if len(got) != len(step.want) {
+			t.Errorf("TestFileWatcher(%s): got %v, want %v", step.desc, got, step.want)
+			continue
+		}
+		for name, value := range step.want {
+			if got[name] != value {
+				t.Errorf("TestFileWatcher(%s): got %v, want %v", step.desc, got, step.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Package buildinfo describes the build of the running binary, its version, the commit it was built from and
+when, so telemetry can be tied to the code that produced it.
+
+The values are set at build time with -ldflags:
+
+	go build -ldflags "-X github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/buildinfo.Version=v1.2.0 \
+		-X github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+		-X github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+Values that aren't set are taken from the module and version control information the go command embeds
+with runtime/debug.ReadBuildInfo, when there is some.
+*/
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// Set with -ldflags, see the package documentation.
+var (
+	// Version is the version of the build, like v1.2.0.
+	Version string
+	// Commit is the git SHA the build is from.
+	Commit string
+	// Date is when the build was made, like 2022-01-02T15:04:05Z.
+	Date string
+)
+
+// Info describes a build.
+type Info struct {
+	// Version is the version of the build, "dev" if it isn't known.
+	Version string
+	// Commit is the git SHA the build is from, if it is known.
+	Commit string
+	// Date is when the build was made, or when Commit was made if only that is known.
+	Date string
+	// Modified is true if the build had changes that aren't in Commit.
+	Modified bool
+	// GoVersion is the version of Go the build was made with.
+	GoVersion string
+}
+
+// Get returns the Info of the running binary.
+func Get() Info {
+	i := Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		i = fill(i, bi)
+	}
+	if i.Version == "" {
+		i.Version = "dev"
+	}
+	return i
+}
+
+// fill returns i with the values it doesn't have taken from bi.
+func fill(i Info, bi *debug.BuildInfo) Info {
+	// Binaries built from a checkout, rather than installed at a version, have the version "(devel)".
+	if i.Version == "" && bi.Main.Version != "(devel)" {
+		i.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if i.Commit == "" {
+				i.Commit = s.Value
+			}
+		case "vcs.time":
+			if i.Date == "" {
+				i.Date = s.Value
+			}
+		case "vcs.modified":
+			i.Modified = s.Value == "true"
+		}
+	}
+	return i
+}
+
+// Attributes returns i as resource attributes: service.version, and demo.build.commit, demo.build.date and
+// demo.build.modified when they are known.
+func (i Info) Attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.ServiceVersionKey.String(i.Version)}
+	if i.Commit != "" {
+		attrs = append(attrs, attribute.String("demo.build.commit", i.Commit), attribute.Bool("demo.build.modified", i.Modified))
+	}
+	if i.Date != "" {
+		attrs = append(attrs, attribute.String("demo.build.date", i.Date))
+	}
+	return attrs
+}
+
+// String returns i like "v1.2.0 (commit 3f2a9c1, built 2022-01-02T15:04:05Z, go1.21.0)".
+func (i Info) String() string {
+	s := i.Version + " ("
+	if i.Commit != "" {
+		s += "commit " + i.Commit
+		if i.Modified {
+			s += "-modified"
+		}
+		s += ", "
+	}
+	if i.Date != "" {
+		s += "built " + i.Date + ", "
+	}
+	return s + i.GoVersion + ")"
+}
@@ -0,0 +1,72 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestFill(t *testing.T) {
+	bi := &debug.BuildInfo{
+		Main: debug.Module{Version: "v1.2.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "3f2a9c1"},
+			{Key: "vcs.time", Value: "2022-01-02T15:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	tests := []struct {
+		desc string
+		info Info
+		bi   *debug.BuildInfo
+		want Info
+	}{
+		{
+			desc: "From build info",
+			bi:   bi,
+			want: Info{Version: "v1.2.0", Commit: "3f2a9c1", Date: "2022-01-02T15:04:05Z", Modified: true},
+		},
+		{
+			desc: "From ldflags",
+			info: Info{Version: "v1.3.0", Commit: "abcdef0", Date: "2022-02-01T00:00:00Z"},
+			bi:   bi,
+			want: Info{Version: "v1.3.0", Commit: "abcdef0", Date: "2022-02-01T00:00:00Z", Modified: true},
+		},
+		{
+			desc: "Built from a checkout",
+			bi:   &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}},
+			want: Info{},
+		},
+	}
+
+	for _, test := range tests {
+		if got := fill(test.info, test.bi); got != test.want {
+			t.Errorf("TestFill(%s): got %+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		desc string
+		info Info
+		want string
+	}{
+		{
+			desc: "Version only",
+			info: Info{Version: "dev", GoVersion: "go1.21.0"},
+			want: "dev (go1.21.0)",
+		},
+		{
+			desc: "Everything",
+			info: Info{Version: "v1.2.0", Commit: "3f2a9c1", Date: "2022-01-02T15:04:05Z", Modified: true, GoVersion: "go1.21.0"},
+			want: "v1.2.0 (commit 3f2a9c1-modified, built 2022-01-02T15:04:05Z, go1.21.0)",
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.info.String(); got != test.want {
+			t.Errorf("TestString(%s): got %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
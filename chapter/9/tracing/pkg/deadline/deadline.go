@@ -0,0 +1,86 @@
+/*
+Package deadline propagates the deadline of a request across the services it passes through in the
+X-Request-Deadline header, like gRPC does in grpc-timeout, so no service keeps working on a request the
+caller has given up on.
+
+Clients set the header from the deadline of the request's context:
+
+	deadline.Inject(req.Context(), req.Header)
+
+and servers make it the deadline of the context of the requests they handle:
+
+	http.Handle("/hello", otelhttp.NewHandler(deadline.Handler(helloHandler), "/hello"))
+
+Each hop records the budget it has left in the demo.deadline.remaining_ms attribute of its span.
+*/
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header is the HTTP header with the deadline of a request, in milliseconds since the Unix epoch.
+const Header = "X-Request-Deadline"
+
+// RemainingKey is the span attribute with the milliseconds left until the deadline.
+const RemainingKey = attribute.Key("demo.deadline.remaining_ms")
+
+// Inject sets Header in h to the deadline of ctx, if it has one.
+func Inject(ctx context.Context, h http.Header) {
+	if d, ok := ctx.Deadline(); ok {
+		h.Set(Header, strconv.FormatInt(d.UnixNano()/int64(time.Millisecond), 10))
+	}
+}
+
+// Extract returns ctx with the deadline in h's Header, if it has one that is earlier than ctx's, and
+// the func that releases it. ok is false if h has no valid Header.
+func Extract(ctx context.Context, h http.Header) (_ context.Context, cancel context.CancelFunc, ok bool) {
+	ms, err := strconv.ParseInt(h.Get(Header), 10, 64)
+	if err != nil {
+		return ctx, func() {}, false
+	}
+	d := time.Unix(0, ms*int64(time.Millisecond))
+	if current, has := ctx.Deadline(); has && current.Before(d) {
+		return ctx, func() {}, true
+	}
+	ctx, cancel = context.WithDeadline(ctx, d)
+	return ctx, cancel, true
+}
+
+// Remaining returns the RemainingKey attribute of ctx's deadline, or nothing if ctx has no deadline.
+func Remaining(ctx context.Context) []attribute.KeyValue {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	return []attribute.KeyValue{RemainingKey.Int64(time.Until(d).Milliseconds())}
+}
+
+// Handler wraps next so the deadline in a request's Header is the deadline of its context, and is
+// recorded on its span. Requests whose deadline has passed are answered with a 504 status without
+// calling next. It must be wrapped by otelhttp, so the request's span is in its context.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel, ok := Extract(r.Context(), r.Header)
+		defer cancel()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(Remaining(ctx)...)
+		if ctx.Err() != nil {
+			span.AddEvent("deadline exceeded")
+			http.Error(w, "deadline exceeded", http.StatusGatewayTimeout)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
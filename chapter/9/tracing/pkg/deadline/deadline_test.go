@@ -0,0 +1,103 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		desc         string
+		header       string
+		ctxTimeout   time.Duration
+		wantOK       bool
+		wantDeadline bool
+		// wantBefore is how far from now the deadline must be at most.
+		wantBefore time.Duration
+	}{
+		{
+			desc: "No header",
+		},
+		{
+			desc:   "Invalid header",
+			header: "soon",
+		},
+		{
+			desc:         "Header",
+			header:       "in 1s",
+			wantOK:       true,
+			wantDeadline: true,
+			wantBefore:   time.Second,
+		},
+		{
+			desc:         "Earlier context deadline is kept",
+			header:       "in 1h",
+			ctxTimeout:   time.Second,
+			wantOK:       true,
+			wantDeadline: true,
+			wantBefore:   time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		h := http.Header{}
+		switch test.header {
+		case "in 1s":
+			Inject(deadlineIn(t, time.Second), h)
+		case "in 1h":
+			Inject(deadlineIn(t, time.Hour), h)
+		case "":
+		default:
+			h.Set(Header, test.header)
+		}
+		ctx := context.Background()
+		if test.ctxTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.ctxTimeout)
+			defer cancel()
+		}
+
+		got, cancel, ok := Extract(ctx, h)
+		cancel()
+		if ok != test.wantOK {
+			t.Errorf("TestExtract(%s): got ok %v, want %v", test.desc, ok, test.wantOK)
+		}
+		d, has := got.Deadline()
+		if has != test.wantDeadline {
+			t.Errorf("TestExtract(%s): got deadline %v, want %v", test.desc, has, test.wantDeadline)
+			continue
+		}
+		if has && time.Until(d) > test.wantBefore {
+			t.Errorf("TestExtract(%s): got deadline in %v, want at most %v", test.desc, time.Until(d), test.wantBefore)
+		}
+	}
+}
+
+func TestHandlerExpired(t *testing.T) {
+	called := false
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	Inject(deadlineIn(t, -time.Second), req.Header)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if called {
+		t.Errorf("TestHandlerExpired: got the handler called, want it not called")
+	}
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("TestHandlerExpired: got status %d, want %d", rec.Code, http.StatusGatewayTimeout)
+	}
+}
+
+// deadlineIn returns a context whose deadline is d from now, which is cancelled when the test ends.
+func deadlineIn(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(d))
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,67 @@
+package detectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// azureMetadataURL is the instance metadata service's description of the virtual machine.
+const azureMetadataURL = "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01&format=json"
+
+// Azure is a resource.Detector for an Azure virtual machine, which it describes with the cloud.* and host.*
+// attributes from the instance metadata service.
+type Azure struct {
+	client   *http.Client
+	endpoint string
+}
+
+// NewAzure returns an Azure detector.
+func NewAzure() Azure {
+	return Azure{client: http.DefaultClient, endpoint: azureMetadataURL}
+}
+
+// azureCompute is the part of the instance metadata the detector uses.
+type azureCompute struct {
+	Location       string `json:"location"`
+	Name           string `json:"name"`
+	VMID           string `json:"vmId"`
+	VMSize         string `json:"vmSize"`
+	SubscriptionID string `json:"subscriptionId"`
+}
+
+// Detect implements resource.Detector.Detect.
+func (a Azure) Detect(ctx context.Context) (*resource.Resource, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The service only answers requests with this header, which a proxied request wouldn't have.
+	req.Header.Set("Metadata", "true")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the instance metadata service returned %s", resp.Status)
+	}
+	var c azureCompute
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("could not decode the instance metadata: %w", err)
+	}
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.CloudProviderAzure,
+		semconv.CloudPlatformAzureVM,
+		semconv.CloudRegionKey.String(c.Location),
+		semconv.CloudAccountIDKey.String(c.SubscriptionID),
+		semconv.HostIDKey.String(c.VMID),
+		semconv.HostNameKey.String(c.Name),
+		semconv.HostTypeKey.String(c.VMSize),
+	), nil
+}
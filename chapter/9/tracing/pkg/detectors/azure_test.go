@@ -0,0 +1,53 @@
+package detectors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Metadata") != "true" {
+			http.Error(w, "missing Metadata header", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"location": "westeurope", "name": "demo-vm", "vmId": "02aab8a4", "vmSize": "Standard_D2s_v3", "subscriptionId": "8d10da13"}`))
+	}))
+	defer srv.Close()
+
+	res, err := Azure{client: srv.Client(), endpoint: srv.URL}.Detect(context.Background())
+	if err != nil {
+		t.Fatalf("TestAzure: got err == %s, want err == nil", err)
+	}
+	want := map[string]string{
+		"cloud.provider":   "azure",
+		"cloud.platform":   "azure_vm",
+		"cloud.region":     "westeurope",
+		"cloud.account.id": "8d10da13",
+		"host.id":          "02aab8a4",
+		"host.name":        "demo-vm",
+		"host.type":        "Standard_D2s_v3",
+	}
+	got := map[string]string{}
+	for _, kv := range res.Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("TestAzure: got %s=%q, want %q", k, got[k], v)
+		}
+	}
+
+	// Off Azure, the bestEffort wrapper detects nothing.
+	failing := bestEffort{name: "azure", detector: Azure{client: srv.Client(), endpoint: srv.URL + "/missing"}}
+	res, err = failing.Detect(context.Background())
+	if err != nil || res.Len() != 0 {
+		t.Errorf("TestAzure(bestEffort): got %v, %v, want an empty resource", res, err)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Package detectors builds the resource detectors that describe the cloud infrastructure the demo runs on,
+from a list of names, so spans and metrics from managed infrastructure carry its metadata:
+
+	dets, err := detectors.New("ec2,eks")
+	if err != nil {
+		// Do something
+	}
+	res, err := resource.New(ctx, resource.WithDetectors(dets...))
+
+The detectors are:
+
+	ec2: an AWS EC2 instance, from the instance metadata service
+	ecs: an AWS ECS task, from the task metadata endpoint
+	eks: an AWS EKS cluster, from the Kubernetes API
+	gcp: a GCP Compute Engine instance, GKE cluster or Cloud Run service, from the metadata server
+	azure: an Azure virtual machine, from the instance metadata service
+	none: no detection
+
+The detectors call services that are only there on their platform, so they should be given a context with
+a timeout. A detector that fails is reported to the otel error handler, and detects nothing, so running off
+the platform doesn't stop the demo.
+*/
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/contrib/detectors/aws/ec2"
+	"go.opentelemetry.io/contrib/detectors/aws/ecs"
+	"go.opentelemetry.io/contrib/detectors/aws/eks"
+	"go.opentelemetry.io/contrib/detectors/gcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// New returns the detectors in names, a comma separated list.
+func New(names string) ([]resource.Detector, error) {
+	var dets []resource.Detector
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "ec2":
+			dets = append(dets, bestEffort{name: name, detector: ec2.NewResourceDetector()})
+		case "ecs":
+			dets = append(dets, bestEffort{name: name, detector: ecs.NewResourceDetector()})
+		case "eks":
+			dets = append(dets, bestEffort{name: name, detector: eks.NewResourceDetector()})
+		case "gcp":
+			dets = append(
+				dets,
+				bestEffort{name: name, detector: &gcp.GCE{}},
+				bestEffort{name: name, detector: &gcp.GKE{}},
+				bestEffort{name: name, detector: gcp.NewCloudRun()},
+			)
+		case "azure":
+			dets = append(dets, bestEffort{name: name, detector: NewAzure()})
+		case "none", "":
+		default:
+			return nil, fmt.Errorf("%q is not a known resource detector", name)
+		}
+	}
+	return dets, nil
+}
+
+// bestEffort is a resource.Detector whose errors are reported to the otel error handler rather than
+// returned, as a detector off its platform fails, and resource.New would then fail.
+type bestEffort struct {
+	name     string
+	detector resource.Detector
+}
+
+// Detect implements resource.Detector.Detect.
+func (b bestEffort) Detect(ctx context.Context) (*resource.Resource, error) {
+	res, err := b.detector.Detect(ctx)
+	if err != nil {
+		otel.Handle(fmt.Errorf("the %s resource detector failed: %w", b.name, err))
+		return resource.Empty(), nil
+	}
+	if res == nil {
+		return resource.Empty(), nil
+	}
+	// The detectors use a different version of the semantic conventions than the rest of the resource,
+	// whose schema URLs can't be merged.
+	return resource.NewSchemaless(res.Attributes()...), nil
+}
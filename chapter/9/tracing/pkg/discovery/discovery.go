@@ -0,0 +1,219 @@
+/*
+Package discovery finds the addresses of a gRPC service, like the OTLP collector, from DNS, so that load is
+spread over every instance and follows them as they are added and removed:
+
+	target, opts, err := discovery.Target("srv", "_otlp._tcp.otel-collector.observability.svc.cluster.local", 30*time.Second)
+	if err != nil {
+		// Do something
+	}
+	conn, err := grpc.Dial(target, append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+
+The modes are:
+
+	static: the endpoint is a host:port dialed as it is, and one connection is used
+	dns: the endpoint is a host:port whose host's A and AAAA records are the addresses, like a Kubernetes
+	     headless service
+	srv: the endpoint is the name of SRV records, whose targets and ports are the addresses
+
+With dns and srv, the records are looked up again every interval, and when gRPC asks after losing a
+connection, and requests are balanced round robin over a connection to each address.
+*/
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+// roundRobin is the service config that spreads requests over every address.
+const roundRobin = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+
+// Target returns the gRPC dial target of endpoint for mode, and the dial options that resolve and balance it.
+// With static, the target is endpoint and there are no options.
+func Target(mode, endpoint string, interval time.Duration) (string, []grpc.DialOption, error) {
+	var lookup func(ctx context.Context, endpoint string) ([]string, error)
+	switch strings.ToLower(mode) {
+	case "static", "":
+		return endpoint, nil, nil
+	case "dns":
+		if _, _, err := net.SplitHostPort(endpoint); err != nil {
+			return "", nil, fmt.Errorf("dns discovery needs a host:port, like otel-collector-headless:4317: %w", err)
+		}
+		lookup = lookupHost
+	case "srv":
+		if strings.Contains(endpoint, ":") {
+			return "", nil, fmt.Errorf("srv discovery needs the name of SRV records without a port, like _otlp._tcp.otel-collector, was %s", endpoint)
+		}
+		lookup = lookupSRV
+	default:
+		return "", nil, fmt.Errorf("%q is not a known discovery mode", mode)
+	}
+	if interval <= 0 {
+		return "", nil, fmt.Errorf("the discovery interval must be positive, was %s", interval)
+	}
+	scheme := "discovery-" + strings.ToLower(mode)
+	b := &builder{scheme: scheme, lookup: lookup, interval: interval}
+	opts := []grpc.DialOption{grpc.WithResolvers(b), grpc.WithDefaultServiceConfig(roundRobin)}
+	return scheme + ":///" + endpoint, opts, nil
+}
+
+// lookupHost returns the addresses of the host of endpoint, a host:port, with its port.
+func lookupHost(ctx context.Context, endpoint string) ([]string, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, port)
+	}
+	return addrs, nil
+}
+
+// lookupSRV returns the addresses of the SRV records named name.
+func lookupSRV(ctx context.Context, name string) ([]string, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	return srvAddresses(srvs), nil
+}
+
+// srvAddresses returns the target:port of each of srvs.
+func srvAddresses(srvs []*net.SRV) []string {
+	addrs := make([]string, len(srvs))
+	for i, srv := range srvs {
+		addrs[i] = net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+	}
+	return addrs
+}
+
+// builder is a resolver.Builder whose resolvers look up the addresses of their target with lookup every
+// interval.
+type builder struct {
+	scheme   string
+	lookup   func(ctx context.Context, endpoint string) ([]string, error)
+	interval time.Duration
+}
+
+// Build implements resolver.Builder.Build.
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &pollingResolver{
+		cc:         cc,
+		endpoint:   targetEndpoint(target),
+		lookup:     b.lookup,
+		interval:   b.interval,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch(ctx)
+	return r, nil
+}
+
+// targetEndpoint returns the endpoint of target, which grpc versions up to 1.45 expose as a field and later
+// ones as a method, from its URL like both do.
+func targetEndpoint(target resolver.Target) string {
+	endpoint := target.URL.Path
+	if endpoint == "" {
+		endpoint = target.URL.Opaque
+	}
+	return strings.TrimPrefix(endpoint, "/")
+}
+
+// Scheme implements resolver.Builder.Scheme.
+func (b *builder) Scheme() string {
+	return b.scheme
+}
+
+// pollingResolver is a resolver.Resolver that updates the addresses of a connection when its lookup finds
+// different ones.
+type pollingResolver struct {
+	cc       resolver.ClientConn
+	endpoint string
+	lookup   func(ctx context.Context, endpoint string) ([]string, error)
+	interval time.Duration
+
+	cancel     context.CancelFunc
+	resolveNow chan struct{}
+	wg         sync.WaitGroup
+}
+
+// watch looks up the addresses until ctx is done.
+func (r *pollingResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+
+	var last []string
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-r.resolveNow:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		addrs, err := r.lookup(ctx, r.endpoint)
+		switch {
+		case err != nil:
+			r.cc.ReportError(fmt.Errorf("could not look up %s: %w", r.endpoint, err))
+		case len(addrs) == 0:
+			r.cc.ReportError(fmt.Errorf("%s has no addresses", r.endpoint))
+		case !sameAddresses(addrs, last):
+			last = addrs
+			state := resolver.State{}
+			for _, addr := range addrs {
+				state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+			}
+			r.cc.UpdateState(state)
+		}
+		timer.Reset(r.interval)
+	}
+}
+
+// sameAddresses reports if a and b have the same addresses, in any order. It sorts them.
+func sameAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveNow implements resolver.Resolver.ResolveNow.
+func (r *pollingResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+// Close implements resolver.Resolver.Close.
+func (r *pollingResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		desc     string
+		mode     string
+		endpoint string
+		interval time.Duration
+		want     string
+		wantOpts bool
+		err      bool
+	}{
+		{desc: "Static", mode: "static", endpoint: "otel-collector:4317", interval: time.Second, want: "otel-collector:4317"},
+		{desc: "DNS", mode: "dns", endpoint: "otel-collector-headless:4317", interval: time.Second, want: "discovery-dns:///otel-collector-headless:4317", wantOpts: true},
+		{desc: "SRV", mode: "SRV", endpoint: "_otlp._tcp.otel-collector", interval: time.Second, want: "discovery-srv:///_otlp._tcp.otel-collector", wantOpts: true},
+		{desc: "DNS without a port", mode: "dns", endpoint: "otel-collector-headless", interval: time.Second, err: true},
+		{desc: "SRV with a port", mode: "srv", endpoint: "otel-collector:4317", interval: time.Second, err: true},
+		{desc: "No interval", mode: "dns", endpoint: "otel-collector-headless:4317", err: true},
+		{desc: "Unknown mode", mode: "consul", endpoint: "otel-collector:4317", interval: time.Second, err: true},
+	}
+
+	for _, test := range tests {
+		got, opts, err := Target(test.mode, test.endpoint, test.interval)
+		switch {
+		case err == nil && test.err:
+			t.Errorf("TestTarget(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.err:
+			t.Errorf("TestTarget(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestTarget(%s): got %q, want %q", test.desc, got, test.want)
+		}
+		if (len(opts) > 0) != test.wantOpts {
+			t.Errorf("TestTarget(%s): got %d dial options, want options == %t", test.desc, len(opts), test.wantOpts)
+		}
+	}
+}
+
+func TestSRVAddresses(t *testing.T) {
+	srvs := []*net.SRV{
+		{Target: "otel-collector-0.otel-collector.observability.svc.cluster.local.", Port: 4317},
+		{Target: "10.0.0.7", Port: 55680},
+	}
+	want := []string{"otel-collector-0.otel-collector.observability.svc.cluster.local:4317", "10.0.0.7:55680"}
+
+	got := srvAddresses(srvs)
+	if len(got) != len(want) {
+		t.Fatalf("TestSRVAddresses: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestSRVAddresses: got %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestSameAddresses(t *testing.T) {
+	tests := []struct {
+		desc string
+		a, b []string
+		want bool
+	}{
+		{desc: "Same order", a: []string{"10.0.0.1:4317", "10.0.0.2:4317"}, b: []string{"10.0.0.1:4317", "10.0.0.2:4317"}, want: true},
+		{desc: "Other order", a: []string{"10.0.0.2:4317", "10.0.0.1:4317"}, b: []string{"10.0.0.1:4317", "10.0.0.2:4317"}, want: true},
+		{desc: "Added", a: []string{"10.0.0.1:4317", "10.0.0.2:4317"}, b: []string{"10.0.0.1:4317"}, want: false},
+		{desc: "Replaced", a: []string{"10.0.0.3:4317"}, b: []string{"10.0.0.1:4317"}, want: false},
+		{desc: "First lookup", a: []string{"10.0.0.1:4317"}, want: false},
+	}
+
+	for _, test := range tests {
+		if got := sameAddresses(test.a, test.b); got != test.want {
+			t.Errorf("TestSameAddresses(%s): got %t, want %t", test.desc, got, test.want)
+		}
+	}
+}
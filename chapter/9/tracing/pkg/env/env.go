@@ -0,0 +1,93 @@
+/*
+Package env reads the environment variables the demo's flags default to:
+
+	interval = flag.Duration("request-interval", env.Duration("REQUEST_INTERVAL", 5*time.Second), "...")
+
+A variable that is not set, or that can't be parsed, gives the default. validate.Problems.Env reports the
+ones that can't be parsed, so they aren't replaced without a word.
+*/
+package env
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Or returns the value of the environment variable key, or def if it is not set.
+func Or(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// Bool returns the value of the environment variable key parsed as a bool, or def if it is
+// not set or can't be parsed.
+func Bool(key string, def bool) bool {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// Int returns the value of the environment variable key parsed as an int, or def if it is
+// not set or can't be parsed.
+func Int(key string, def int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+// Float returns the value of the environment variable key parsed as a float64, or def if it is
+// not set or can't be parsed.
+func Float(key string, def float64) float64 {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+// Duration returns the value of the environment variable key parsed with time.ParseDuration, like
+// "500ms", or def if it is not set or can't be parsed.
+func Duration(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// Millis returns the value of the environment variable key, which holds a number of milliseconds,
+// as a time.Duration. If it is not set or can't be parsed, def is returned.
+func Millis(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return time.Duration(i) * time.Millisecond
+}
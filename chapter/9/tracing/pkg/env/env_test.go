@@ -0,0 +1,39 @@
+package env
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	tests := []struct {
+		desc  string
+		value string
+		set   bool
+		get   func(key string) interface{}
+		want  interface{}
+	}{
+		{desc: "Or unset", get: func(k string) interface{} { return Or(k, "def") }, want: "def"},
+		{desc: "Or empty", set: true, get: func(k string) interface{} { return Or(k, "def") }, want: ""},
+		{desc: "Bool", value: "true", set: true, get: func(k string) interface{} { return Bool(k, false) }, want: true},
+		{desc: "Bool invalid", value: "yes", set: true, get: func(k string) interface{} { return Bool(k, false) }, want: false},
+		{desc: "Int", value: "42", set: true, get: func(k string) interface{} { return Int(k, 1) }, want: 42},
+		{desc: "Int invalid", value: "4.2", set: true, get: func(k string) interface{} { return Int(k, 1) }, want: 1},
+		{desc: "Float", value: "0.25", set: true, get: func(k string) interface{} { return Float(k, 1) }, want: 0.25},
+		{desc: "Duration", value: "500ms", set: true, get: func(k string) interface{} { return Duration(k, time.Second) }, want: 500 * time.Millisecond},
+		{desc: "Duration without a unit", value: "5", set: true, get: func(k string) interface{} { return Duration(k, time.Second) }, want: time.Second},
+		{desc: "Millis", value: "5", set: true, get: func(k string) interface{} { return Millis(k, time.Second) }, want: 5 * time.Millisecond},
+		{desc: "Millis unset", get: func(k string) interface{} { return Millis(k, time.Second) }, want: time.Second},
+	}
+
+	for i, test := range tests {
+		key := fmt.Sprintf("DEMO_ENV_TEST_%d", i)
+		if test.set {
+			t.Setenv(key, test.value)
+		}
+		if got := test.get(key); got != test.want {
+			t.Errorf("TestDefaults(%s): got %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Package forcesample lets a request be traced regardless of sampling, which is useful to reproduce an
+issue under ratio sampling.
+
+A service samples the spans started with a Context from With by wrapping its sampler:
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(forcesample.New(sampler)))
+
+Clients ask the services they call to do the same by setting Header on the request, and servers honor
+it by wrapping their instrumented handler, so the Context is marked before the server span starts:
+
+	mux.Handle("/hello", forcesample.Handler(otelhttp.NewHandler(helloHandler, "/hello")))
+*/
+package forcesample
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/tracestate"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header is the HTTP header that asks for a request to be traced regardless of sampling, when set to "1".
+const Header = "x-debug-trace"
+
+type forceKey struct{}
+
+// With returns a Context that makes Sampler sample spans started with it.
+func With(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey{}, true)
+}
+
+// IsForced reports if ctx was returned by With().
+func IsForced(ctx context.Context) bool {
+	v, _ := ctx.Value(forceKey{}).(bool)
+	return v
+}
+
+// Handler returns a http.Handler that calls next with the request's Context returned by With() if the
+// request has Header set to "1".
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(Header) == "1" {
+			r = r.WithContext(With(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Sampler is a sdktrace.Sampler that samples spans started with a Context from With(), no matter what
+// the child Sampler decides. Forced spans have the attribute sampler.forced=true.
+//
+// The sampling tier of a sampled trace, TierDebug if it was forced and TierStandard if not, is set by its
+// root span in the tracestate.TierField of the demo's tracestate entry, so every service the trace reaches knows it.
+type Sampler struct {
+	child sdktrace.Sampler
+}
+
+// New creates a Sampler that uses child for spans that aren't forced.
+func New(child sdktrace.Sampler) *Sampler {
+	return &Sampler{child: child}
+}
+
+// The sampling tiers Sampler sets in the tracestate.
+const (
+	TierDebug    = "debug"
+	TierStandard = "standard"
+)
+
+// ShouldSample implements sdktrace.Sampler.ShouldSample.
+func (s *Sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(p.ParentContext)
+	if !IsForced(p.ParentContext) {
+		res := s.child.ShouldSample(p)
+		if !parent.IsValid() && res.Decision == sdktrace.RecordAndSample {
+			res.Tracestate = withTier(res.Tracestate, TierStandard)
+		}
+		return res
+	}
+	ts := parent.TraceState()
+	if tracestate.GetField(ts, tracestate.Vendor, tracestate.TierField) != TierDebug {
+		ts = withTier(ts, TierDebug)
+	}
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordAndSample,
+		Attributes: []attribute.KeyValue{attribute.Bool("sampler.forced", true)},
+		Tracestate: ts,
+	}
+}
+
+// withTier returns ts with the sampling tier set, or ts if it can't be set.
+func withTier(ts trace.TraceState, tier string) trace.TraceState {
+	if updated, err := tracestate.SetField(ts, tracestate.Vendor, tracestate.TierField, tier); err == nil {
+		return updated
+	}
+	return ts
+}
+
+// Description implements sdktrace.Sampler.Description.
+func (s *Sampler) Description() string {
+	return "ForceSample{" + s.child.Description() + "}"
+}
@@ -0,0 +1,72 @@
+package forcesample
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		desc        string
+		header      string
+		wantSampled bool
+	}{
+		{desc: "Header set", header: "1", wantSampled: true},
+		{desc: "No header"},
+		{desc: "Header not 1", header: "true"},
+	}
+
+	for _, test := range tests {
+		// The server samples nothing unless it is forced to.
+		rec := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(New(sdktrace.NeverSample())), sdktrace.WithSpanProcessor(rec))
+		srv := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := tp.Tracer("server").Start(r.Context(), "/hello")
+			span.End()
+		})))
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("TestHandler(%s): NewRequest: %s", test.desc, err)
+		}
+		if test.header != "" {
+			req.Header.Set(Header, test.header)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("TestHandler(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		res.Body.Close()
+		srv.Close()
+		tp.Shutdown(context.Background())
+
+		if got := len(rec.Ended()) == 1; got != test.wantSampled {
+			t.Errorf("TestHandler(%s): got sampled == %v, want %v", test.desc, got, test.wantSampled)
+		}
+	}
+}
+
+func TestSamplerTier(t *testing.T) {
+	tests := []struct {
+		desc     string
+		ctx      context.Context
+		child    sdktrace.Sampler
+		wantTier string
+	}{
+		{desc: "Forced", ctx: With(context.Background()), child: sdktrace.NeverSample(), wantTier: "st:debug"},
+		{desc: "Sampled by the child", ctx: context.Background(), child: sdktrace.AlwaysSample(), wantTier: "st:standard"},
+		{desc: "Dropped by the child", ctx: context.Background(), child: sdktrace.NeverSample()},
+	}
+
+	for _, test := range tests {
+		res := New(test.child).ShouldSample(sdktrace.SamplingParameters{ParentContext: test.ctx, Name: "span"})
+		if got := res.Tracestate.Get("demo"); got != test.wantTier {
+			t.Errorf("TestSamplerTier(%s): got tracestate entry %q, want %q", test.desc, got, test.wantTier)
+		}
+	}
+}
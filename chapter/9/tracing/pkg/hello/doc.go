@@ -0,0 +1,8 @@
+// Package hello is the Greeter gRPC service of hello.proto, which the demo client and server use to show
+// trace propagation over gRPC as well as HTTP.
+//
+// hello.pb.go and hello_grpc.pb.go are generated with protoc-gen-go v1.28.1 and protoc-gen-go-grpc
+// v1.2.0; run go generate after changing hello.proto.
+package hello
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative hello.proto
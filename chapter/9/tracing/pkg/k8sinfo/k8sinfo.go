@@ -0,0 +1,96 @@
+/*
+Package k8sinfo describes the Kubernetes pod the running binary is in, so telemetry can be tied to the pod,
+namespace, node and container that produced it.
+
+The values come from environment variables set with the downward API in the pod spec:
+
+	env:
+	- name: K8S_POD_NAME
+	  valueFrom: {fieldRef: {fieldPath: metadata.name}}
+	- name: K8S_POD_UID
+	  valueFrom: {fieldRef: {fieldPath: metadata.uid}}
+	- name: K8S_NAMESPACE_NAME
+	  valueFrom: {fieldRef: {fieldPath: metadata.namespace}}
+	- name: K8S_NODE_NAME
+	  valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+	- name: K8S_CONTAINER_NAME
+	  value: client
+
+The shorter POD_NAME, POD_UID, POD_NAMESPACE, NODE_NAME and CONTAINER_NAME are also read. Without them, the
+namespace is read from the service account's files, and the pod name is the hostname, which is the pod's
+name unless the pod spec sets one.
+*/
+package k8sinfo
+
+import (
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// namespaceFile is where the namespace of the pod's service account is mounted.
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Info describes a pod. Values that aren't known are empty.
+type Info struct {
+	PodName       string
+	PodUID        string
+	Namespace     string
+	NodeName      string
+	ContainerName string
+}
+
+// Get returns the Info of the pod the running binary is in. Outside Kubernetes it is empty.
+func Get() Info {
+	return fill(os.Getenv, os.ReadFile)
+}
+
+// fill returns the Info from the environment variables of getenv and the files of readFile.
+func fill(getenv func(string) string, readFile func(string) ([]byte, error)) Info {
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := getenv(k); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	i := Info{
+		PodName:       first("K8S_POD_NAME", "POD_NAME"),
+		PodUID:        first("K8S_POD_UID", "POD_UID"),
+		Namespace:     first("K8S_NAMESPACE_NAME", "POD_NAMESPACE"),
+		NodeName:      first("K8S_NODE_NAME", "NODE_NAME"),
+		ContainerName: first("K8S_CONTAINER_NAME", "CONTAINER_NAME"),
+	}
+	// Kubernetes sets KUBERNETES_SERVICE_HOST in every container, so the fallbacks aren't used elsewhere.
+	if getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return i
+	}
+	if i.Namespace == "" {
+		if b, err := readFile(namespaceFile); err == nil {
+			i.Namespace = strings.TrimSpace(string(b))
+		}
+	}
+	if i.PodName == "" {
+		i.PodName = getenv("HOSTNAME")
+	}
+	return i
+}
+
+// Attributes returns the values of i that are known as the k8s.* resource attributes.
+func (i Info) Attributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	add := func(key attribute.Key, value string) {
+		if value != "" {
+			attrs = append(attrs, key.String(value))
+		}
+	}
+	add(semconv.K8SPodNameKey, i.PodName)
+	add(semconv.K8SPodUIDKey, i.PodUID)
+	add(semconv.K8SNamespaceNameKey, i.Namespace)
+	add(semconv.K8SNodeNameKey, i.NodeName)
+	add(semconv.K8SContainerNameKey, i.ContainerName)
+	return attrs
+}
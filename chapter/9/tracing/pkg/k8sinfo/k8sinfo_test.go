@@ -0,0 +1,73 @@
+package k8sinfo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		desc  string
+		env   map[string]string
+		files map[string]string
+		want  Info
+	}{
+		{
+			desc: "Not in Kubernetes",
+			env:  map[string]string{"HOSTNAME": "laptop"},
+			want: Info{},
+		},
+		{
+			desc: "Downward API",
+			env: map[string]string{
+				"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+				"HOSTNAME":                "client-7d4b9c-x2x9q",
+				"K8S_POD_NAME":            "client-7d4b9c-abcde",
+				"K8S_POD_UID":             "f1e2d3",
+				"K8S_NAMESPACE_NAME":      "demo",
+				"K8S_NODE_NAME":           "node-1",
+				"K8S_CONTAINER_NAME":      "client",
+			},
+			files: map[string]string{namespaceFile: "other\n"},
+			want: Info{
+				PodName:       "client-7d4b9c-abcde",
+				PodUID:        "f1e2d3",
+				Namespace:     "demo",
+				NodeName:      "node-1",
+				ContainerName: "client",
+			},
+		},
+		{
+			desc: "Short names",
+			env:  map[string]string{"POD_NAME": "server-0", "POD_NAMESPACE": "demo", "NODE_NAME": "node-2"},
+			want: Info{PodName: "server-0", Namespace: "demo", NodeName: "node-2"},
+		},
+		{
+			desc:  "Service account and hostname",
+			env:   map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "HOSTNAME": "client-7d4b9c-x2x9q"},
+			files: map[string]string{namespaceFile: "demo\n"},
+			want:  Info{PodName: "client-7d4b9c-x2x9q", Namespace: "demo"},
+		},
+	}
+
+	for _, test := range tests {
+		getenv := func(k string) string { return test.env[k] }
+		readFile := func(name string) ([]byte, error) {
+			s, ok := test.files[name]
+			if !ok {
+				return nil, errors.New("no such file")
+			}
+			return []byte(s), nil
+		}
+		if got := fill(getenv, readFile); got != test.want {
+			t.Errorf("TestFill(%s): got %+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	got := Info{PodName: "client-0", Namespace: "demo"}.Attributes()
+	if len(got) != 2 || got[0].Key != "k8s.pod.name" || got[1].Key != "k8s.namespace.name" {
+		t.Errorf("TestAttributes: got %v, want k8s.pod.name and k8s.namespace.name", got)
+	}
+}
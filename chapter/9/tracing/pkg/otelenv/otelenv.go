@@ -0,0 +1,233 @@
+/*
+Package otelenv sets up OpenTelemetry from the standard OTEL_* environment variables alone, like those the
+OpenTelemetry Operator injects into a pod, so the demo behaves like any other instrumented program rather
+than one configured by its own flags:
+
+	c, err := otelenv.Load(os.Getenv)
+	if err != nil {
+		// Do something
+	}
+	shutdown, err := otelenv.Setup(ctx, c)
+	if err != nil {
+		// Do something
+	}
+	defer shutdown(ctx)
+
+Load reads the variables the SDK doesn't read itself, and Setup leaves the rest to the SDK: OTEL_SERVICE_NAME
+and OTEL_RESOURCE_ATTRIBUTES for the resource, OTEL_EXPORTER_OTLP_* for the exporters' endpoint, headers,
+certificate, compression and timeout, and OTEL_BSP_* for batching spans.
+*/
+package otelenv
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Config is the configuration in the standard environment variables that the SDK doesn't read itself.
+type Config struct {
+	// TracesExporter is OTEL_TRACES_EXPORTER: "otlp", "console" or "none".
+	TracesExporter string
+	// TracesProtocol is OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL: "grpc" or
+	// "http/protobuf".
+	TracesProtocol string
+	// MetricsExporter is OTEL_METRICS_EXPORTER: "otlp" or "none".
+	MetricsExporter string
+	// MetricsProtocol is OTEL_EXPORTER_OTLP_METRICS_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL.
+	MetricsProtocol string
+	// MetricInterval is OTEL_METRIC_EXPORT_INTERVAL, in milliseconds.
+	MetricInterval time.Duration
+	// Sampler is OTEL_TRACES_SAMPLER, and SamplerRatio OTEL_TRACES_SAMPLER_ARG for the ratio based ones.
+	Sampler      string
+	SamplerRatio float64
+	// Propagators is OTEL_PROPAGATORS, see propagators.New.
+	Propagators string
+}
+
+// Load reads the Config from getenv, which is os.Getenv outside tests. Unset variables have the defaults of
+// the specification. As it says, a variable set to the empty string is unset, and a signal's own
+// variable, like OTEL_EXPORTER_OTLP_TRACES_PROTOCOL, takes precedence over the one for every signal.
+func Load(getenv func(key string) string) (Config, error) {
+	// get returns the value of the first of keys that is set, and its key, or def and the last key.
+	get := func(def string, keys ...string) (string, string) {
+		for _, key := range keys {
+			if v := strings.TrimSpace(getenv(key)); v != "" {
+				return v, key
+			}
+		}
+		return def, keys[len(keys)-1]
+	}
+
+	var c Config
+	var key string
+	c.TracesExporter, key = get("otlp", "OTEL_TRACES_EXPORTER")
+	c.TracesExporter = strings.ToLower(c.TracesExporter)
+	switch c.TracesExporter {
+	case "otlp", "console", "none":
+	default:
+		return Config{}, fmt.Errorf("%s=%s is not supported, use one of 'otlp', 'console' or 'none'", key, c.TracesExporter)
+	}
+	c.MetricsExporter, key = get("otlp", "OTEL_METRICS_EXPORTER")
+	c.MetricsExporter = strings.ToLower(c.MetricsExporter)
+	switch c.MetricsExporter {
+	case "otlp", "none":
+	default:
+		return Config{}, fmt.Errorf("%s=%s is not supported, use one of 'otlp' or 'none'", key, c.MetricsExporter)
+	}
+
+	var err error
+	if c.TracesProtocol, err = protocol(get("http/protobuf", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL")); err != nil {
+		return Config{}, err
+	}
+	if c.MetricsProtocol, err = protocol(get("http/protobuf", "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL")); err != nil {
+		return Config{}, err
+	}
+
+	interval, key := get("60000", "OTEL_METRIC_EXPORT_INTERVAL")
+	ms, err := strconv.Atoi(interval)
+	if err != nil || ms <= 0 {
+		return Config{}, fmt.Errorf("%s=%s must be a positive number of milliseconds", key, interval)
+	}
+	c.MetricInterval = time.Duration(ms) * time.Millisecond
+
+	c.Sampler, key = get("parentbased_always_on", "OTEL_TRACES_SAMPLER")
+	c.Sampler = strings.ToLower(c.Sampler)
+	switch c.Sampler {
+	case "always_on", "always_off", "parentbased_always_on", "parentbased_always_off":
+	case "traceidratio", "parentbased_traceidratio":
+		arg, key := get("1.0", "OTEL_TRACES_SAMPLER_ARG")
+		c.SamplerRatio, err = strconv.ParseFloat(arg, 64)
+		if err != nil || c.SamplerRatio < 0 || c.SamplerRatio > 1 {
+			return Config{}, fmt.Errorf("%s=%s must be a ratio between 0 and 1", key, arg)
+		}
+	default:
+		return Config{}, fmt.Errorf("%s=%s is not supported, use one of 'always_on', 'always_off', 'traceidratio', "+
+			"'parentbased_always_on', 'parentbased_always_off' or 'parentbased_traceidratio'", key, c.Sampler)
+	}
+
+	c.Propagators, key = get("tracecontext,baggage", "OTEL_PROPAGATORS")
+	if _, err := propagators.New(c.Propagators); err != nil {
+		return Config{}, fmt.Errorf("%s=%s is not supported: %w", key, c.Propagators, err)
+	}
+	return c, nil
+}
+
+// protocol returns value, the OTLP protocol set by key, if the Go exporters support it.
+func protocol(value, key string) (string, error) {
+	switch value {
+	case "grpc", "http/protobuf":
+		return value, nil
+	}
+	return "", fmt.Errorf("%s=%s is not supported, use 'grpc' or 'http/protobuf'", key, value)
+}
+
+// sampler returns the sampler named by c.Sampler.
+func (c Config) sampler() sdktrace.Sampler {
+	switch c.Sampler {
+	case "always_on":
+		return sdktrace.AlwaysSample()
+	case "always_off":
+		return sdktrace.NeverSample()
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(c.SamplerRatio)
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SamplerRatio))
+	}
+	return sdktrace.ParentBased(sdktrace.AlwaysSample())
+}
+
+// Setup installs the global tracer and meter providers and propagator configured by c and the environment,
+// see the package documentation. The returned func flushes and shuts them down.
+func Setup(ctx context.Context, c Config) (func(context.Context), error) {
+	prop, err := propagators.New(c.Propagators)
+	if err != nil {
+		return nil, err
+	}
+	// The default resource is the SDK's, with the attributes of OTEL_RESOURCE_ATTRIBUTES and, taking
+	// precedence over its service.name, OTEL_SERVICE_NAME.
+	res := resource.Default()
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res), sdktrace.WithSampler(c.sampler())}
+	if c.TracesExporter != "none" {
+		exp, err := newTraceExporter(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		// The batch span processor reads OTEL_BSP_*.
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	closeMetrics := func(context.Context) {}
+	if c.MetricsExporter == "none" {
+		global.SetMeterProvider(metric.NewNoopMeterProvider())
+	} else {
+		client := otlpmetricgrpc.NewClient()
+		if c.MetricsProtocol == "http/protobuf" {
+			client = otlpmetrichttp.NewClient()
+		}
+		exp, err := otlpmetric.New(ctx, client)
+		if err != nil {
+			tp.Shutdown(ctx)
+			return nil, fmt.Errorf("failed to create the OTLP metric exporter: %w", err)
+		}
+		pusher := controller.New(
+			processor.NewFactory(simple.NewWithHistogramDistribution(), exp),
+			controller.WithExporter(exp),
+			controller.WithCollectPeriod(c.MetricInterval),
+			controller.WithResource(res),
+		)
+		if err := pusher.Start(ctx); err != nil {
+			tp.Shutdown(ctx)
+			return nil, fmt.Errorf("failed to start metric pusher: %w", err)
+		}
+		global.SetMeterProvider(pusher)
+		closeMetrics = func(ctx context.Context) {
+			if err := pusher.Stop(ctx); err != nil {
+				otel.Handle(err)
+			}
+		}
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(prop)
+
+	return func(ctx context.Context) {
+		if err := tp.Shutdown(ctx); err != nil {
+			otel.Handle(err)
+		}
+		closeMetrics(ctx)
+	}, nil
+}
+
+// newTraceExporter returns the exporter of c.TracesExporter. The OTLP exporters are given no options, so
+// they read OTEL_EXPORTER_OTLP_*, and connect in the background.
+func newTraceExporter(ctx context.Context, c Config) (sdktrace.SpanExporter, error) {
+	switch {
+	case c.TracesExporter == "console":
+		return stdouttrace.New()
+	case c.TracesProtocol == "grpc":
+		return otlptracegrpc.New(ctx)
+	}
+	return otlptracehttp.New(ctx)
+}
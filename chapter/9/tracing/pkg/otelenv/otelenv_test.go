@@ -0,0 +1,111 @@
+package otelenv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		desc string
+		env  map[string]string
+		want Config
+		err  bool
+	}{
+		{
+			desc: "Defaults",
+			want: Config{
+				TracesExporter:  "otlp",
+				TracesProtocol:  "http/protobuf",
+				MetricsExporter: "otlp",
+				MetricsProtocol: "http/protobuf",
+				MetricInterval:  time.Minute,
+				Sampler:         "parentbased_always_on",
+				Propagators:     "tracecontext,baggage",
+			},
+		},
+		{
+			desc: "Injected by the operator",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+				"OTEL_TRACES_SAMPLER":         "parentbased_traceidratio",
+				"OTEL_TRACES_SAMPLER_ARG":     "0.25",
+				"OTEL_PROPAGATORS":            "tracecontext,baggage,b3",
+				"OTEL_METRICS_EXPORTER":       "none",
+			},
+			want: Config{
+				TracesExporter:  "otlp",
+				TracesProtocol:  "grpc",
+				MetricsExporter: "none",
+				MetricsProtocol: "grpc",
+				MetricInterval:  time.Minute,
+				Sampler:         "parentbased_traceidratio",
+				SamplerRatio:    0.25,
+				Propagators:     "tracecontext,baggage,b3",
+			},
+		},
+		{
+			desc: "Signal's protocol takes precedence",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": "http/protobuf",
+				"OTEL_METRIC_EXPORT_INTERVAL":        "10000",
+			},
+			want: Config{
+				TracesExporter:  "otlp",
+				TracesProtocol:  "http/protobuf",
+				MetricsExporter: "otlp",
+				MetricsProtocol: "grpc",
+				MetricInterval:  10 * time.Second,
+				Sampler:         "parentbased_always_on",
+				Propagators:     "tracecontext,baggage",
+			},
+		},
+		{
+			desc: "Empty is unset",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "", "OTEL_TRACES_SAMPLER": " "},
+			want: Config{
+				TracesExporter:  "otlp",
+				TracesProtocol:  "http/protobuf",
+				MetricsExporter: "otlp",
+				MetricsProtocol: "http/protobuf",
+				MetricInterval:  time.Minute,
+				Sampler:         "parentbased_always_on",
+				Propagators:     "tracecontext,baggage",
+			},
+		},
+		{desc: "Ratio defaults to 1", env: map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio"}, want: Config{
+			TracesExporter:  "otlp",
+			TracesProtocol:  "http/protobuf",
+			MetricsExporter: "otlp",
+			MetricsProtocol: "http/protobuf",
+			MetricInterval:  time.Minute,
+			Sampler:         "traceidratio",
+			SamplerRatio:    1,
+			Propagators:     "tracecontext,baggage",
+		}},
+		{desc: "Unsupported exporter", env: map[string]string{"OTEL_TRACES_EXPORTER": "jaeger"}, err: true},
+		{desc: "Unsupported protocol", env: map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"}, err: true},
+		{desc: "Unsupported sampler", env: map[string]string{"OTEL_TRACES_SAMPLER": "jaeger_remote"}, err: true},
+		{desc: "Ratio out of range", env: map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio", "OTEL_TRACES_SAMPLER_ARG": "10"}, err: true},
+		{desc: "Invalid interval", env: map[string]string{"OTEL_METRIC_EXPORT_INTERVAL": "10s"}, err: true},
+		{desc: "Unknown propagator", env: map[string]string{"OTEL_PROPAGATORS": "tracecontext,w3c"}, err: true},
+	}
+
+	for _, test := range tests {
+		got, err := Load(func(key string) string { return test.env[key] })
+		switch {
+		case err == nil && test.err:
+			t.Errorf("TestLoad(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.err:
+			t.Errorf("TestLoad(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestLoad(%s): got %+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package promexport
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// createdHandler serves the metrics of a prom.Gatherer in the OpenMetrics format with a _created sample for
+// every counter, histogram and summary, the time it started counting, which strict OpenMetrics scrapers
+// expect so rates are right across restarts. client_golang doesn't write _created samples, and the
+// OpenTelemetry exporter doesn't keep the start time of its sums, so the time the exporter started is used
+// for every metric: its sums are cumulative from then. Requests that don't accept OpenMetrics get the
+// Prometheus text format.
+type createdHandler struct {
+	gatherer prom.Gatherer
+	created  time.Time
+	// text serves the requests that don't accept OpenMetrics.
+	text http.Handler
+}
+
+// Handler returns an http.Handler that serves the metrics of g, which started at created, with their _created
+// samples, see createdHandler.
+func Handler(g prom.Gatherer, created time.Time) http.Handler {
+	return createdHandler{gatherer: g, created: created, text: promhttp.HandlerFor(g, promhttp.HandlerOpts{})}
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP.
+func (h createdHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if expfmt.NegotiateIncludingOpenMetrics(r.Header) != expfmt.FmtOpenMetrics {
+		h.text.ServeHTTP(w, r)
+		return
+	}
+	mfs, err := h.gatherer.Gather()
+	if err != nil {
+		http.Error(w, "error gathering metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var b bytes.Buffer
+	if err := writeOpenMetrics(&b, mfs, h.created); err != nil {
+		http.Error(w, "error encoding metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", string(expfmt.FmtOpenMetrics))
+	w.Write(b.Bytes())
+}
+
+// writeOpenMetrics writes mfs to w in the OpenMetrics text format, followed by the # EOF line. Each metric of
+// a counter, histogram or summary is followed by its _created sample with the time created.
+func writeOpenMetrics(w io.Writer, mfs []*dto.MetricFamily, created time.Time) error {
+	ts := strconv.FormatFloat(float64(created.UnixNano())/float64(time.Second), 'f', -1, 64)
+	for _, mf := range mfs {
+		name, ok := createdName(mf)
+		if !ok {
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, mf); err != nil {
+				return err
+			}
+			continue
+		}
+		// The metrics are written one at a time, so the _created sample can follow the samples of each.
+		for i, m := range mf.Metric {
+			one := &dto.MetricFamily{Name: mf.Name, Type: mf.Type, Metric: []*dto.Metric{m}}
+			if i == 0 {
+				one.Help = mf.Help
+			}
+			var b bytes.Buffer
+			if _, err := expfmt.MetricFamilyToOpenMetrics(&b, one); err != nil {
+				return err
+			}
+			out := b.String()
+			if i > 0 {
+				// Only the first metric keeps the family's # TYPE line.
+				out = out[strings.IndexByte(out, '\n')+1:]
+			}
+			if _, err := io.WriteString(w, out+name+openMetricsLabels(m.Label)+" "+ts+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := expfmt.FinalizeOpenMetrics(w)
+	return err
+}
+
+// createdName returns the name of the _created samples of mf, and false if mf's type doesn't have them.
+// Counters without the _total suffix are written with the unknown type, which doesn't.
+func createdName(mf *dto.MetricFamily) (string, bool) {
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		if !strings.HasSuffix(mf.GetName(), "_total") {
+			return "", false
+		}
+		return strings.TrimSuffix(mf.GetName(), "_total") + "_created", true
+	case dto.MetricType_HISTOGRAM, dto.MetricType_SUMMARY:
+		return mf.GetName() + "_created", true
+	}
+	return "", false
+}
+
+// labelValueEscaper escapes a label value as the OpenMetrics text format requires.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// openMetricsLabels returns labels as they are written in the OpenMetrics text format, like {code="200"}.
+func openMetricsLabels(labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, l.GetName()+`="`+labelValueEscaper.Replace(l.GetValue())+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
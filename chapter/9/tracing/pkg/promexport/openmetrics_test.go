@@ -0,0 +1,84 @@
+package promexport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestHandler(t *testing.T) {
+	registry := prom.NewRegistry()
+	requests := prom.NewCounterVec(prom.CounterOpts{Name: "demo_requests_total", Help: "Requests."}, []string{"code"})
+	requests.WithLabelValues("200").Inc()
+	requests.WithLabelValues("500").Inc()
+	latency := prom.NewHistogram(prom.HistogramOpts{Name: "demo_latency_seconds", Help: "Latency.", Buckets: []float64{1}})
+	latency.Observe(0.5)
+	inflight := prom.NewGauge(prom.GaugeOpts{Name: "demo_inflight", Help: "In flight."})
+	registry.MustRegister(requests, latency, inflight)
+
+	srv := httptest.NewServer(Handler(registry, time.Unix(1600000000, 500000000)))
+	defer srv.Close()
+
+	tests := []struct {
+		desc    string
+		accept  string
+		want    []string
+		notWant []string
+	}{
+		{
+			desc:   "OpenMetrics",
+			accept: string(expfmt.FmtOpenMetrics),
+			want: []string{
+				"# TYPE demo_requests counter\n" +
+					"demo_requests_total{code=\"200\"} 1.0\n" +
+					"demo_requests_created{code=\"200\"} 1600000000.5\n" +
+					"demo_requests_total{code=\"500\"} 1.0\n" +
+					"demo_requests_created{code=\"500\"} 1600000000.5\n",
+				"demo_latency_seconds_count 1\ndemo_latency_seconds_created 1600000000.5\n",
+				"demo_inflight 0.0\n",
+				"# EOF\n",
+			},
+			notWant: []string{"demo_inflight_created"},
+		},
+		{
+			desc:    "Prometheus text",
+			accept:  "text/plain",
+			want:    []string{"demo_requests_total{code=\"200\"} 1\n"},
+			notWant: []string{"_created", "# EOF"},
+		},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", test.accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("TestHandler(%s): %s", test.desc, err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("TestHandler(%s): %s", test.desc, err)
+		}
+		body := string(b)
+		for _, w := range test.want {
+			if !strings.Contains(body, w) {
+				t.Errorf("TestHandler(%s): got:\n%s\nwant it to contain:\n%s", test.desc, body, w)
+			}
+		}
+		for _, nw := range test.notWant {
+			if strings.Contains(body, nw) {
+				t.Errorf("TestHandler(%s): got:\n%s\nwant it not to contain %q", test.desc, body, nw)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Package promexport exports the metrics of the global meter provider to Prometheus, set up the same way for
+every command of the demo, so the client's and server's metrics can be scraped and compared:
+
+	registry, err := promexport.NewRegistry(res, promexport.LatencyBuckets)
+	if err != nil {
+		// Do something
+	}
+	http.Handle("/metrics", promexport.Handler(registry, time.Now()))
+*/
+package promexport
+
+import (
+	"fmt"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/sdk/export/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// LatencyBuckets are the default bucket boundaries, in milliseconds, of the latency histograms, like those
+// of redmetrics.
+var LatencyBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// NewRegistry registers the global meter provider with a new Prometheus registry and returns it. Histograms
+// have the bucket boundaries buckets, and the metrics have the attributes of res.
+func NewRegistry(res *resource.Resource, buckets []float64) (*prom.Registry, error) {
+	registry := prom.NewRegistry()
+	config := prometheus.Config{
+		DefaultHistogramBoundaries: buckets,
+		Registry:                   registry,
+	}
+	c := controller.New(
+		processor.NewFactory(
+			simple.NewWithHistogramDistribution(
+				histogram.WithExplicitBoundaries(config.DefaultHistogramBoundaries),
+			),
+			// Prometheus expects counters to be totals since the process started.
+			aggregation.CumulativeTemporalitySelector(),
+			processor.WithMemory(true),
+		),
+		controller.WithResource(res),
+	)
+	exporter, err := prometheus.New(config, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Prometheus exporter: %w", err)
+	}
+	global.SetMeterProvider(exporter.MeterProvider())
+	return registry, nil
+}
@@ -0,0 +1,55 @@
+package propagators
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// CorrelationIDHeader is the header CorrelationID propagates.
+	CorrelationIDHeader = "X-Correlation-ID"
+	// CorrelationIDKey is the baggage member CorrelationID keeps the ID in.
+	CorrelationIDKey = "correlation.id"
+)
+
+// CorrelationID is a propagation.TextMapPropagator of the X-Correlation-ID header of home-grown correlation
+// schemes, an example of a custom propagator for services migrating from one to tracing.
+//
+// The ID is kept in the correlation.id baggage member, so it is propagated to services that only know
+// tracing too, and can be copied onto spans like other baggage. List it after "baggage" in New, or the
+// baggage header replaces the member.
+type CorrelationID struct{}
+
+var _ propagation.TextMapPropagator = CorrelationID{}
+
+// Inject implements propagation.TextMapPropagator.Inject. It sets the header from the baggage in ctx.
+func (CorrelationID) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if id := baggage.FromContext(ctx).Member(CorrelationIDKey).Value(); id != "" {
+		carrier.Set(CorrelationIDHeader, id)
+	}
+}
+
+// Extract implements propagation.TextMapPropagator.Extract. It adds the header's ID to the baggage in ctx.
+// IDs that aren't valid baggage values are ignored.
+func (CorrelationID) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	id := carrier.Get(CorrelationIDHeader)
+	if id == "" {
+		return ctx
+	}
+	m, err := baggage.NewMember(CorrelationIDKey, id)
+	if err != nil {
+		return ctx
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// Fields implements propagation.TextMapPropagator.Fields.
+func (CorrelationID) Fields() []string {
+	return []string{CorrelationIDHeader}
+}
@@ -0,0 +1,52 @@
+package propagators
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestCorrelationID(t *testing.T) {
+	tests := []struct {
+		desc   string
+		header http.Header
+		wantID string
+	}{
+		{
+			desc:   "Correlation ID",
+			header: http.Header{"X-Correlation-Id": {"7f3c9a"}},
+			wantID: "7f3c9a",
+		},
+		{
+			desc:   "Correlation ID with baggage",
+			header: http.Header{"X-Correlation-Id": {"7f3c9a"}, "Baggage": {"user.id=user-1"}},
+			wantID: "7f3c9a",
+		},
+		{
+			desc:   "No correlation ID",
+			header: http.Header{},
+		},
+		{
+			desc:   "Invalid correlation ID",
+			header: http.Header{"X-Correlation-Id": {"a b"}},
+		},
+	}
+
+	prop := propagation.NewCompositeTextMapPropagator(propagation.Baggage{}, CorrelationID{})
+	for _, test := range tests {
+		ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(test.header))
+		if got := baggage.FromContext(ctx).Member(CorrelationIDKey).Value(); got != test.wantID {
+			t.Errorf("TestCorrelationID(%s): got extracted ID %q, want %q", test.desc, got, test.wantID)
+		}
+
+		// What was extracted is injected, so the ID is passed on to the next service.
+		out := http.Header{}
+		prop.Inject(ctx, propagation.HeaderCarrier(out))
+		if got := out.Get(CorrelationIDHeader); got != test.wantID {
+			t.Errorf("TestCorrelationID(%s): got injected ID %q, want %q", test.desc, got, test.wantID)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package propagators
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// RequestIDHeader is the header RequestID propagates.
+	RequestIDHeader = "X-Request-Id"
+	// RequestIDAttribute is the span attribute of the request ID, the tag Envoy puts it in on its own spans.
+	RequestIDAttribute = attribute.Key("guid:x-request-id")
+)
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// ContextWithRequestID returns ctx with the request ID id, which RequestID injects.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID in ctx, or "" if it has none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID is a propagation.TextMapPropagator of the X-Request-Id header Envoy, and so Istio and other
+// meshes built on it, uses to tie together the access logs and spans of a request. The sidecar generates
+// one when a request enters the mesh, but applications must pass it on to their own outgoing requests
+// for it to span more than one hop.
+//
+// Unlike CorrelationID, the ID isn't kept in baggage: it is only the mesh's concern, and is read with
+// RequestIDFromContext.
+type RequestID struct{}
+
+var _ propagation.TextMapPropagator = RequestID{}
+
+// Inject implements propagation.TextMapPropagator.Inject. It sets the header from the ID in ctx.
+func (RequestID) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		carrier.Set(RequestIDHeader, id)
+	}
+}
+
+// Extract implements propagation.TextMapPropagator.Extract. It adds the header's ID to ctx.
+func (RequestID) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if id := carrier.Get(RequestIDHeader); id != "" {
+		return ContextWithRequestID(ctx, id)
+	}
+	return ctx
+}
+
+// Fields implements propagation.TextMapPropagator.Fields.
+func (RequestID) Fields() []string {
+	return []string{RequestIDHeader}
+}
+
+// mesh returns the propagators of the headers Envoy and Istio ask applications to forward: the B3
+// multiple headers, which carry the sidecar's span as the parent when the mesh traces with Zipkin, and
+// X-Request-Id.
+func mesh() []propagation.TextMapPropagator {
+	return []propagation.TextMapPropagator{b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)), RequestID{}}
+}
+
+// MeshAttributes returns span attributes that line a span up with the telemetry of an Envoy sidecar: the
+// request ID in ctx, and what Envoy added to h, the headers of a request it forwarded (the attempt count
+// of its retries) or of a response it returned (how long the upstream took as Envoy measured it).
+func MeshAttributes(ctx context.Context, h http.Header) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, RequestIDAttribute.String(id))
+	}
+	if n, err := strconv.Atoi(h.Get("X-Envoy-Attempt-Count")); err == nil {
+		attrs = append(attrs, attribute.Int("envoy.attempt_count", n))
+	}
+	if ms, err := strconv.Atoi(h.Get("X-Envoy-Upstream-Service-Time")); err == nil {
+		attrs = append(attrs, attribute.Int("envoy.upstream_service_time_ms", ms))
+	}
+	return attrs
+}
@@ -0,0 +1,127 @@
+package propagators
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMesh(t *testing.T) {
+	tests := []struct {
+		desc        string
+		header      http.Header
+		wantID      string
+		wantTraceID string
+	}{
+		{
+			desc: "Request ID and B3",
+			header: http.Header{
+				"X-Request-Id": {"0b8d9f3e-3c1a-4b7e-9e1f-2a6d5c4b3a21"},
+				"X-B3-Traceid": {"4bf92f3577b34da6a3ce929d0e0e4736"},
+				"X-B3-Spanid":  {"00f067aa0ba902b7"},
+				"X-B3-Sampled": {"1"},
+			},
+			wantID:      "0b8d9f3e-3c1a-4b7e-9e1f-2a6d5c4b3a21",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			desc:   "Only a request ID",
+			header: http.Header{"X-Request-Id": {"0b8d9f3e"}},
+			wantID: "0b8d9f3e",
+		},
+		{
+			desc:   "No mesh headers",
+			header: http.Header{},
+		},
+	}
+
+	prop, err := New("tracecontext,mesh")
+	if err != nil {
+		t.Fatalf("TestMesh: got err == %s, want err == nil", err)
+	}
+	for _, test := range tests {
+		ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(test.header))
+		if got := RequestIDFromContext(ctx); got != test.wantID {
+			t.Errorf("TestMesh(%s): got extracted ID %q, want %q", test.desc, got, test.wantID)
+		}
+		var gotTraceID string
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			gotTraceID = sc.TraceID().String()
+		}
+		if gotTraceID != test.wantTraceID {
+			t.Errorf("TestMesh(%s): got trace ID %q, want %q", test.desc, gotTraceID, test.wantTraceID)
+		}
+
+		// What was extracted is injected, so the next hop's sidecar sees the same request and trace.
+		out := http.Header{}
+		prop.Inject(ctx, propagation.HeaderCarrier(out))
+		if got := out.Get(RequestIDHeader); got != test.wantID {
+			t.Errorf("TestMesh(%s): got injected ID %q, want %q", test.desc, got, test.wantID)
+		}
+		if got := out.Get("X-B3-TraceId"); got != test.wantTraceID {
+			t.Errorf("TestMesh(%s): got injected X-B3-TraceId %q, want %q", test.desc, got, test.wantTraceID)
+		}
+	}
+}
+
+func TestMeshAttributes(t *testing.T) {
+	tests := []struct {
+		desc   string
+		id     string
+		header http.Header
+		want   map[string]int64
+	}{
+		{
+			desc:   "Request forwarded by Envoy",
+			id:     "0b8d9f3e",
+			header: http.Header{"X-Envoy-Attempt-Count": {"2"}},
+			want:   map[string]int64{"envoy.attempt_count": 2},
+		},
+		{
+			desc:   "Response returned by Envoy",
+			header: http.Header{"X-Envoy-Upstream-Service-Time": {"37"}},
+			want:   map[string]int64{"envoy.upstream_service_time_ms": 37},
+		},
+		{
+			desc:   "Invalid header",
+			header: http.Header{"X-Envoy-Attempt-Count": {"two"}},
+			want:   map[string]int64{},
+		},
+		{
+			desc:   "No mesh",
+			header: http.Header{},
+			want:   map[string]int64{},
+		},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.id != "" {
+			ctx = ContextWithRequestID(ctx, test.id)
+		}
+		var gotID string
+		got := map[string]int64{}
+		for _, kv := range MeshAttributes(ctx, test.header) {
+			if kv.Key == RequestIDAttribute {
+				gotID = kv.Value.AsString()
+				continue
+			}
+			got[string(kv.Key)] = kv.Value.AsInt64()
+		}
+		if gotID != test.id {
+			t.Errorf("TestMeshAttributes(%s): got %s %q, want %q", test.desc, RequestIDAttribute, gotID, test.id)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("TestMeshAttributes(%s): got %v, want %v", test.desc, got, test.want)
+			continue
+		}
+		for k, v := range test.want {
+			if got[k] != v {
+				t.Errorf("TestMeshAttributes(%s): got %s == %d, want %d", test.desc, k, got[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Package propagators builds the propagator that injects and extracts trace context and baggage in requests,
+from a list of formats like the OTEL_PROPAGATORS environment variable's. Combining formats lets the demo
+exchange traces with services instrumented by older tracing libraries:
+
+	prop, err := propagators.New("tracecontext,baggage,b3")
+	if err != nil {
+		// Do something
+	}
+	otel.SetTextMapPropagator(prop)
+
+The formats are:
+
+	tracecontext: W3C Trace Context, the traceparent and tracestate headers
+	baggage: W3C Baggage, the baggage header
+	b3: Zipkin's B3 single header, b3
+	b3multi: Zipkin's B3 multiple headers, X-B3-TraceId and others
+	jaeger: Jaeger's uber-trace-id header
+	xray: AWS X-Ray's X-Amzn-Trace-Id header
+	correlationid: a legacy X-Correlation-ID header, kept in baggage (see CorrelationID)
+	mesh: the headers Envoy and Istio forward, b3multi's and X-Request-Id (see RequestID)
+	none: no propagation
+
+Each format is extracted in the order listed, and trace context found by a later one is used over an
+earlier one's. All of them are injected. Behind an Envoy sidecar that traces with Zipkin, list mesh after
+tracecontext, so the sidecar's span is the parent of the server's rather than the client's span.
+*/
+package propagators
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// New returns a propagator of the formats in names, a comma separated list.
+func New(names string) (propagation.TextMapPropagator, error) {
+	var props []propagation.TextMapPropagator
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "tracecontext":
+			props = append(props, propagation.TraceContext{})
+		case "baggage":
+			props = append(props, propagation.Baggage{})
+		case "b3":
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			props = append(props, jaeger.Jaeger{})
+		case "xray":
+			props = append(props, xray.Propagator{})
+		case "correlationid":
+			props = append(props, CorrelationID{})
+		case "mesh":
+			props = append(props, mesh()...)
+		case "none", "":
+		default:
+			return nil, fmt.Errorf("%q is not a known propagator", strings.TrimSpace(name))
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
@@ -0,0 +1,149 @@
+/*
+Package redmetrics records the Rate, Errors and Duration (RED) of operations, the three metrics that
+describe how a request driven service is doing.
+
+Every operation is counted in <name>_counts, failures in <name>_errors and the time taken in milliseconds
+in the <name>_latency histogram. Metrics can wrap an http.Handler, an http.RoundTripper or any function:
+
+	red, err := redmetrics.New(global.Meter("demo-server-meter"), "demo_server/request")
+	if err != nil {
+		// Do something
+	}
+	http.Handle("/hello", red.Handler(helloHandler, attribute.String("http.route", "/hello")))
+*/
+package redmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+// Metrics records the rate, errors and duration of operations.
+type Metrics struct {
+	count   metric.Int64Counter
+	errors  metric.Int64Counter
+	latency metric.Float64Histogram
+}
+
+// New creates the instruments for operations called name, like "demo_client/request", with meter.
+func New(meter metric.Meter, name string) (*Metrics, error) {
+	count, err := meter.NewInt64Counter(
+		name+"_counts",
+		metric.WithDescription("The number of operations"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	errors, err := meter.NewInt64Counter(
+		name+"_errors",
+		metric.WithDescription("The number of operations that failed"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	latency, err := meter.NewFloat64Histogram(
+		name+"_latency",
+		metric.WithDescription("The latency of operations in milliseconds"),
+		metric.WithUnit(unit.Milliseconds),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{count: count, errors: errors, latency: latency}, nil
+}
+
+// Record records an operation that took d. failed should be true if the operation failed.
+func (m *Metrics) Record(ctx context.Context, d time.Duration, failed bool, attrs ...attribute.KeyValue) {
+	m.count.Add(ctx, 1, attrs...)
+	if failed {
+		m.errors.Add(ctx, 1, attrs...)
+	}
+	m.latency.Record(ctx, float64(d)/float64(time.Millisecond), attrs...)
+}
+
+// Func calls f and records it as an operation, which failed if f returns an error.
+func (m *Metrics) Func(ctx context.Context, f func(context.Context) error, attrs ...attribute.KeyValue) error {
+	start := time.Now()
+	err := f(ctx)
+	m.Record(ctx, time.Since(start), err != nil, attrs...)
+	return err
+}
+
+// Handler wraps h so that every request it serves is recorded with attrs and the http.status_class of
+// the response. Responses with a 5xx status are errors.
+func (m *Metrics) Handler(h http.Handler, attrs ...attribute.KeyValue) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		h.ServeHTTP(sw, r)
+
+		all := append([]attribute.KeyValue{attribute.String("http.status_class", StatusClass(sw.status))}, attrs...)
+		m.Record(r.Context(), time.Since(start), sw.status >= 500, all...)
+	})
+}
+
+// Transport wraps rt so that every request it sends is recorded with the http.host it was sent to and
+// the http.status_class of the response. Requests that get no response or a 5xx status are errors,
+// and have a status class of "error" or "5xx". Requests whose context was canceled aren't recorded:
+// the caller gave up on them, like on the slower of two hedged requests, so they didn't fail.
+func (m *Metrics) Transport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+			return resp, err
+		}
+
+		class := "error"
+		if err == nil {
+			class = StatusClass(resp.StatusCode)
+		}
+		m.Record(
+			req.Context(),
+			time.Since(start),
+			err != nil || resp.StatusCode >= 500,
+			attribute.String("http.host", req.URL.Host),
+			attribute.String("http.status_class", class),
+		)
+		return resp, err
+	})
+}
+
+// StatusClass returns the class of an HTTP status code, like "2xx" for 200.
+func StatusClass(code int) string {
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// roundTripper adapts a function to an http.RoundTripper.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.RoundTrip.
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// statusWriter records the status code written to an http.ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader.
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher, so handlers can stream responses through a statusWriter.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
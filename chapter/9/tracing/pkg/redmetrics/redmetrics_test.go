@@ -0,0 +1,189 @@
+package redmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/metrictest"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		desc       string
+		status     int
+		wantClass  string
+		wantErrors int
+	}{
+		{desc: "OK", status: http.StatusOK, wantClass: "2xx"},
+		{desc: "Status not written", wantClass: "2xx"},
+		{desc: "Not found", status: http.StatusNotFound, wantClass: "4xx"},
+		{desc: "Unavailable", status: http.StatusServiceUnavailable, wantClass: "5xx", wantErrors: 1},
+	}
+
+	for _, test := range tests {
+		provider := metrictest.NewMeterProvider()
+		red, err := New(provider.Meter("redmetrics_test"), "test/request")
+		if err != nil {
+			t.Fatalf("TestHandler(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		h := red.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.status != 0 {
+				w.WriteHeader(test.status)
+			}
+		}), attribute.String("http.route", "/hello"))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+		counts := map[string]int{}
+		for _, m := range metrictest.AsStructs(provider.MeasurementBatches) {
+			counts[m.Name]++
+			if got := m.Labels["http.status_class"].AsString(); got != test.wantClass {
+				t.Errorf("TestHandler(%s): got %s with http.status_class %q, want %q", test.desc, m.Name, got, test.wantClass)
+			}
+			if got := m.Labels["http.route"].AsString(); got != "/hello" {
+				t.Errorf("TestHandler(%s): got %s with http.route %q, want %q", test.desc, m.Name, got, "/hello")
+			}
+		}
+		if counts["test/request_counts"] != 1 {
+			t.Errorf("TestHandler(%s): got %d test/request_counts, want 1", test.desc, counts["test/request_counts"])
+		}
+		if counts["test/request_latency"] != 1 {
+			t.Errorf("TestHandler(%s): got %d test/request_latency, want 1", test.desc, counts["test/request_latency"])
+		}
+		if counts["test/request_errors"] != test.wantErrors {
+			t.Errorf("TestHandler(%s): got %d test/request_errors, want %d", test.desc, counts["test/request_errors"], test.wantErrors)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, err := strconv.Atoi(r.URL.Query().Get("code"))
+		if err != nil {
+			code = http.StatusOK
+		}
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		desc       string
+		ctx        context.Context
+		url        string
+		wantHost   string
+		wantClass  string
+		wantCount  int
+		wantErrors int
+	}{
+		{desc: "OK", ctx: context.Background(), url: srv.URL, wantHost: host, wantClass: "2xx", wantCount: 1},
+		{desc: "Not found", ctx: context.Background(), url: srv.URL + "?code=404", wantHost: host, wantClass: "4xx", wantCount: 1},
+		{desc: "Unavailable", ctx: context.Background(), url: srv.URL + "?code=503", wantHost: host, wantClass: "5xx", wantCount: 1, wantErrors: 1},
+		// Port 0 can't be connected to.
+		{desc: "No response", ctx: context.Background(), url: "http://127.0.0.1:0", wantHost: "127.0.0.1:0", wantClass: "error", wantCount: 1, wantErrors: 1},
+		{desc: "Canceled", ctx: canceled, url: srv.URL},
+	}
+
+	for _, test := range tests {
+		provider := metrictest.NewMeterProvider()
+		red, err := New(provider.Meter("redmetrics_test"), "test/request")
+		if err != nil {
+			t.Fatalf("TestTransport(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		client := &http.Client{Transport: red.Transport(http.DefaultTransport)}
+		req, err := http.NewRequestWithContext(test.ctx, http.MethodGet, test.url, nil)
+		if err != nil {
+			t.Fatalf("TestTransport(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+
+		counts := map[string]int{}
+		for _, m := range metrictest.AsStructs(provider.MeasurementBatches) {
+			counts[m.Name]++
+			if got := m.Labels["http.status_class"].AsString(); got != test.wantClass {
+				t.Errorf("TestTransport(%s): got %s with http.status_class %q, want %q", test.desc, m.Name, got, test.wantClass)
+			}
+			if got := m.Labels["http.host"].AsString(); got != test.wantHost {
+				t.Errorf("TestTransport(%s): got %s with http.host %q, want %q", test.desc, m.Name, got, test.wantHost)
+			}
+		}
+		if counts["test/request_counts"] != test.wantCount {
+			t.Errorf("TestTransport(%s): got %d test/request_counts, want %d", test.desc, counts["test/request_counts"], test.wantCount)
+		}
+		if counts["test/request_latency"] != test.wantCount {
+			t.Errorf("TestTransport(%s): got %d test/request_latency, want %d", test.desc, counts["test/request_latency"], test.wantCount)
+		}
+		if counts["test/request_errors"] != test.wantErrors {
+			t.Errorf("TestTransport(%s): got %d test/request_errors, want %d", test.desc, counts["test/request_errors"], test.wantErrors)
+		}
+	}
+}
+
+func TestFunc(t *testing.T) {
+	tests := []struct {
+		desc       string
+		err        error
+		wantErrors int
+	}{
+		{desc: "Success"},
+		{desc: "Failure", err: errors.New("failed"), wantErrors: 1},
+	}
+
+	for _, test := range tests {
+		provider := metrictest.NewMeterProvider()
+		red, err := New(provider.Meter("redmetrics_test"), "test/op")
+		if err != nil {
+			t.Fatalf("TestFunc(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		err = red.Func(context.Background(), func(context.Context) error { return test.err }, attribute.String("op", "test"))
+		if err != test.err {
+			t.Errorf("TestFunc(%s): got err == %v, want err == %v", test.desc, err, test.err)
+		}
+
+		counts := map[string]int{}
+		for _, m := range metrictest.AsStructs(provider.MeasurementBatches) {
+			counts[m.Name]++
+			if got := m.Labels["op"].AsString(); got != "test" {
+				t.Errorf("TestFunc(%s): got %s with op %q, want %q", test.desc, m.Name, got, "test")
+			}
+		}
+		if counts["test/op_counts"] != 1 {
+			t.Errorf("TestFunc(%s): got %d test/op_counts, want 1", test.desc, counts["test/op_counts"])
+		}
+		if counts["test/op_latency"] != 1 {
+			t.Errorf("TestFunc(%s): got %d test/op_latency, want 1", test.desc, counts["test/op_latency"])
+		}
+		if counts["test/op_errors"] != test.wantErrors {
+			t.Errorf("TestFunc(%s): got %d test/op_errors, want %d", test.desc, counts["test/op_errors"], test.wantErrors)
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{code: 200, want: "2xx"},
+		{code: 204, want: "2xx"},
+		{code: 301, want: "3xx"},
+		{code: 404, want: "4xx"},
+		{code: 503, want: "5xx"},
+	}
+
+	for _, test := range tests {
+		if got := StatusClass(test.code); got != test.want {
+			t.Errorf("TestStatusClass(%d): got %q, want %q", test.code, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Package spanlink links the spans of repeated tries of an operation, like retried or hedged requests.
+
+A retry isn't a child of the attempt before it, and a hedge isn't a child of the request it races, so
+parenting them under each other draws the wrong picture. Each try is instead a child of the operation's
+span, and links to the try it follows, with the demo.link.relation attribute on the link saying how:
+
+	retries := spanlink.NewChain(spanlink.Retry)
+	for attempt := 0; ; attempt++ {
+		ctx, span := retries.Start(ctx, tracer, "Attempt")
+		err := send(ctx)
+		span.End()
+		...
+	}
+
+Trace viewers show links next to the span, so the tries can be followed from any of them.
+*/
+package spanlink
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The relations of a try to the one it links to.
+const (
+	// Retry is the relation of a try sent again after the one it links to failed.
+	Retry = "retry"
+	// Hedge is the relation of a try sent alongside the one it links to, because it was slow.
+	Hedge = "hedge"
+	// Run is the relation of a trace to the span of the load run it is part of, which isn't a try but
+	// anchors every trace of the run.
+	Run = "run"
+)
+
+// RelationKey is the attribute of a link that says how the span is related to the linked span.
+const RelationKey = attribute.Key("demo.link.relation")
+
+// To returns a link to sc with relation, and attrs.
+func To(sc trace.SpanContext, relation string, attrs ...attribute.KeyValue) trace.Link {
+	return trace.Link{
+		SpanContext: sc,
+		Attributes:  append([]attribute.KeyValue{RelationKey.String(relation)}, attrs...),
+	}
+}
+
+// Chain starts the spans of successive tries of an operation, each linked to the span of the try before
+// it. It is safe for concurrent use.
+type Chain struct {
+	relation string
+
+	mu   sync.Mutex
+	prev trace.SpanContext
+}
+
+// NewChain returns a Chain whose links have relation, like Retry.
+func NewChain(relation string) *Chain {
+	return &Chain{relation: relation}
+}
+
+// Start starts a span like tracer.Start, linked to the span this Chain started before, if any.
+func (c *Chain) Start(ctx context.Context, tracer trace.Tracer, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.prev.IsValid() {
+		opts = append(opts, trace.WithLinks(To(c.prev, c.relation)))
+	}
+	ctx, span := tracer.Start(ctx, name, opts...)
+	c.prev = span.SpanContext()
+	return ctx, span
+}
@@ -0,0 +1,62 @@
+package spanlink
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestChain(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("spanlink_test")
+
+	ctx, parent := tracer.Start(context.Background(), "Request")
+	retries := NewChain(Retry)
+	for i := 0; i < 3; i++ {
+		_, span := retries.Start(ctx, tracer, "Attempt")
+		span.End()
+	}
+	parent.End()
+
+	var attempts []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == "Attempt" {
+			attempts = append(attempts, s)
+		}
+	}
+	if len(attempts) != 3 {
+		t.Fatalf("TestChain: got %d attempt spans, want 3", len(attempts))
+	}
+
+	for i, s := range attempts {
+		// Tries are children of the operation, not of each other.
+		if got := s.Parent().SpanID(); got != parent.SpanContext().SpanID() {
+			t.Errorf("TestChain(attempt %d): got parent %s, want %s", i, got, parent.SpanContext().SpanID())
+		}
+		links := s.Links()
+		if i == 0 {
+			if len(links) != 0 {
+				t.Errorf("TestChain(attempt 0): got %d links, want 0", len(links))
+			}
+			continue
+		}
+		if len(links) != 1 {
+			t.Errorf("TestChain(attempt %d): got %d links, want 1", i, len(links))
+			continue
+		}
+		if got, want := links[0].SpanContext.SpanID(), attempts[i-1].SpanContext().SpanID(); got != want {
+			t.Errorf("TestChain(attempt %d): got link to %s, want %s", i, got, want)
+		}
+		var relation string
+		for _, kv := range links[0].Attributes {
+			if kv.Key == RelationKey {
+				relation = kv.Value.AsString()
+			}
+		}
+		if relation != Retry {
+			t.Errorf("TestChain(attempt %d): got %s %q, want %q", i, RelationKey, relation, Retry)
+		}
+	}
+}
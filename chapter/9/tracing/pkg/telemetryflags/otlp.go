@@ -0,0 +1,328 @@
+package telemetryflags
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/env"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Flags related to the connection to the OTLP collector.
+var (
+	// OTLPHeaders are sent with every OTLP export, see Headers.
+	OTLPHeaders = FlagSet.String("otlp-headers", env.Or("OTEL_EXPORTER_OTLP_HEADERS", ""), "Headers sent with every OTLP export, like "+
+		"'api-key=secret,x-team=demo'. Values are URL encoded. Defaults to env variable 'OTEL_EXPORTER_OTLP_HEADERS'.",
+	)
+	// OTLPInsecure makes the connection plaintext, unless a certificate is set, see TLSConfig.
+	OTLPInsecure = FlagSet.Bool("otlp-insecure", env.Bool("OTEL_EXPORTER_OTLP_INSECURE", true), "If true, the OTLP exporters use a plaintext "+
+		"connection unless -otlp-ca-cert is set. Defaults to env variable 'OTEL_EXPORTER_OTLP_INSECURE'.",
+	)
+	// OTLPCACert is a PEM file with the CA certificates that verify the collector.
+	OTLPCACert = FlagSet.String("otlp-ca-cert", env.Or("OTEL_EXPORTER_OTLP_CERTIFICATE", ""), "A PEM file with CA certificates used to verify "+
+		"the collector, in addition to the system pool. Setting this enables TLS. Defaults to env variable 'OTEL_EXPORTER_OTLP_CERTIFICATE'.",
+	)
+	// OTLPClientCert is a PEM client certificate presented to the collector for mutual TLS.
+	OTLPClientCert = FlagSet.String("otlp-client-cert", env.Or("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", ""), "A PEM client certificate "+
+		"presented to the collector for mutual TLS. The file is reloaded when it changes. Must be set with -otlp-client-key. "+
+		"Defaults to env variable 'OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE'.",
+	)
+	// OTLPClientKey is the PEM private key of OTLPClientCert.
+	OTLPClientKey = FlagSet.String("otlp-client-key", env.Or("OTEL_EXPORTER_OTLP_CLIENT_KEY", ""), "The PEM private key for -otlp-client-cert. "+
+		"Defaults to env variable 'OTEL_EXPORTER_OTLP_CLIENT_KEY'.",
+	)
+	// OTLPConnectAttempts is the number of times to try connecting to the collector at startup.
+	OTLPConnectAttempts = FlagSet.Int("otlp-connect-attempts", 5, "The number of times to try connecting to the OTLP gRPC collector at "+
+		"startup before giving up.",
+	)
+	// OTLPConnectTimeout bounds each attempt to connect to the collector.
+	OTLPConnectTimeout = FlagSet.Duration("otlp-connect-timeout", 5*time.Second, "How long each attempt to connect to the OTLP gRPC "+
+		"collector may take.",
+	)
+	// OTLPNonBlocking connects to the collector in the background, in place of waiting for it at startup.
+	OTLPNonBlocking = FlagSet.Bool("otlp-nonblocking", false, "If true, startup doesn't wait for the OTLP gRPC collector. The connection "+
+		"is made in the background and spans exported before it is up are lost.",
+	)
+	// MetricsInterval is how often metrics are pushed to the collector.
+	MetricsInterval = FlagSet.Duration("metrics-interval", env.Millis("OTEL_METRIC_EXPORT_INTERVAL", 2*time.Second), "How often metrics "+
+		"are collected and exported. Defaults to env variable 'OTEL_METRIC_EXPORT_INTERVAL' in milliseconds.",
+	)
+)
+
+// maxConnectBackoff is the longest we wait between attempts to connect to the collector.
+const maxConnectBackoff = 30 * time.Second
+
+// checkOTLP adds the problems with the flags for the connection to the OTLP collector to p.
+func checkOTLP(p *validate.Problems) {
+	_, err := Headers()
+	p.Add(err)
+	if (*OTLPClientCert == "") != (*OTLPClientKey == "") {
+		p.Addf("-otlp-client-cert and -otlp-client-key must be set together")
+	}
+	if *OTLPConnectAttempts < 1 {
+		p.Addf("-otlp-connect-attempts must be at least 1, was %d", *OTLPConnectAttempts)
+	}
+	p.Positive("otlp-connect-timeout", *OTLPConnectTimeout)
+	p.Positive("metrics-interval", *MetricsInterval)
+}
+
+// TLSConfig returns the *tls.Config used to connect to the OTLP collector, or nil if the
+// connection should be plaintext. Certificates from -otlp-ca-cert are added to the system pool
+// so that collectors with publicly signed certificates keep working. If -otlp-client-cert is set,
+// the certificate is presented to the collector for mutual TLS.
+func TLSConfig() (*tls.Config, error) {
+	if (*OTLPClientCert == "") != (*OTLPClientKey == "") {
+		return nil, fmt.Errorf("-otlp-client-cert and -otlp-client-key must be set together")
+	}
+	if *OTLPInsecure && *OTLPCACert == "" && *OTLPClientCert == "" {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		// The system pool isn't available on every platform, so fall back to an empty one.
+		pool = x509.NewCertPool()
+	}
+	if *OTLPCACert != "" {
+		b, err := os.ReadFile(*OTLPCACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificate: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", *OTLPCACert)
+		}
+	}
+
+	conf := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if *OTLPClientCert != "" {
+		r, err := newCertReloader(*OTLPClientCert, *OTLPClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		conf.GetClientCertificate = r.GetClientCertificate
+	}
+	return conf, nil
+}
+
+// certReloader provides a client certificate that is reloaded from disk when the certificate
+// file changes. The new certificate is used on the next TLS handshake, which for gRPC happens when
+// the connection to the collector is re-established.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// newCertReloader creates a certReloader and does the initial load of the key pair.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the key pair if the certificate file has changed since the last load.
+func (r *certReloader) reload() error {
+	fi, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil && fi.ModTime().Equal(r.modTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = fi.ModTime()
+	return nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate. If a rotated certificate
+// can't be loaded, the error goes to the OpenTelemetry error handler and the previous certificate is used.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if err := r.reload(); err != nil {
+		otel.Handle(fmt.Errorf("could not reload OTLP client certificate %s, using previous certificate: %w", r.certFile, err))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+// Headers returns the headers of -otlp-headers.
+func Headers() (map[string]string, error) {
+	headers, err := parseHeaders(*OTLPHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("-otlp-headers: %w", err)
+	}
+	return headers, nil
+}
+
+// parseHeaders parses headers in the OTEL_EXPORTER_OTLP_HEADERS format, a comma separated list of
+// key=value pairs with URL encoded values, like "x-honeycomb-team=abc123,x-scope=a%20b".
+// Keys are lower cased, as gRPC metadata keys must be.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) != 2 || key == "" {
+			// Don't include the pair in the error, the value is likely a secret.
+			return nil, fmt.Errorf("header %q is not in key=value format", key)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("header %q has an invalid URL encoded value", key)
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// TraceExporter creates an OTLP exporter that sends spans over gRPC to the collector at addr. If tlsConf is
+// nil, the connection is plaintext. Unless -otlp-nonblocking is set, this waits for the collector to be
+// reachable, retrying with backoff up to -otlp-connect-attempts times.
+func TraceExporter(ctx context.Context, log *zap.Logger, addr string, tlsConf *tls.Config, headers map[string]string) (sdktrace.SpanExporter, error) {
+	target, dialOpts, err := OTLPTarget(addr)
+	if err != nil {
+		return nil, fmt.Errorf("-otlp-discovery: %w", err)
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(target),
+		otlptracegrpc.WithDialOption(dialOpts...),
+	}
+	if len(headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(headers))
+	}
+	if tlsConf != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConf)))
+	} else {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	if *OTLPNonBlocking {
+		log.Info("connecting to OTLP collector in the background", zap.String("addr", addr))
+		return otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+	}
+
+	opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithBlock()))
+	return connectWithBackoff(ctx, log, addr, func(ctx context.Context) (sdktrace.SpanExporter, error) {
+		// A client can't be restarted after a failed Start(), so each attempt gets a new one.
+		return otlptrace.New(ctx, otlptracegrpc.NewClient(opts...))
+	})
+}
+
+// connectWithBackoff calls connect until it succeeds or -otlp-connect-attempts is reached. Each attempt
+// is given -otlp-connect-timeout and the wait between attempts doubles up to maxConnectBackoff.
+func connectWithBackoff(ctx context.Context, log *zap.Logger, addr string, connect func(context.Context) (sdktrace.SpanExporter, error)) (sdktrace.SpanExporter, error) {
+	if *OTLPConnectAttempts < 1 {
+		return nil, fmt.Errorf("-otlp-connect-attempts must be at least 1, was %d", *OTLPConnectAttempts)
+	}
+
+	var err error
+	delay := time.Second
+	for attempt := 1; attempt <= *OTLPConnectAttempts; attempt++ {
+		log.Info(
+			"connecting to OTLP collector",
+			zap.String("addr", addr),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", *OTLPConnectAttempts),
+		)
+
+		attemptCtx, cancel := context.WithTimeout(ctx, *OTLPConnectTimeout)
+		var exp sdktrace.SpanExporter
+		exp, err = connect(attemptCtx)
+		cancel()
+		if err == nil {
+			return exp, nil
+		}
+		if attempt == *OTLPConnectAttempts {
+			break
+		}
+
+		log.Warn(
+			"could not connect to OTLP collector",
+			zap.String("addr", addr),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", delay),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxConnectBackoff {
+			delay = maxConnectBackoff
+		}
+	}
+	return nil, fmt.Errorf("could not connect to OTLP collector at %s after %d attempts: %w", addr, *OTLPConnectAttempts, err)
+}
+
+// MetricExporter creates an OTLP exporter that sends metrics over gRPC to the collector at -otlp-endpoint,
+// with the TLS and headers spans are sent with. The connection is made in the background, as the trace
+// exporter has already waited for the collector.
+func MetricExporter(ctx context.Context) (*otlpmetric.Exporter, error) {
+	tlsConf, err := TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	headers, err := Headers()
+	if err != nil {
+		return nil, err
+	}
+	target, dialOpts, err := OTLPTarget(*OTLPEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("-otlp-discovery: %w", err)
+	}
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(target),
+		otlpmetricgrpc.WithDialOption(dialOpts...),
+	}
+	if len(headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
+	}
+	if tlsConf != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConf)))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	exp, err := otlpmetric.New(ctx, otlpmetricgrpc.NewClient(opts...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP metric exporter: %w", err)
+	}
+	return exp, nil
+}
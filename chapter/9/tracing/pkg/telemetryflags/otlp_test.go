@@ -0,0 +1,234 @@
+package telemetryflags
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		desc    string
+		s       string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			desc: "Empty",
+			s:    "",
+			want: map[string]string{},
+		},
+		{
+			desc: "Multiple headers with spaces",
+			s:    "api-key=secret, x-team = demo ,",
+			want: map[string]string{"api-key": "secret", "x-team": "demo"},
+		},
+		{
+			desc: "Keys are lower cased and values decoded",
+			s:    "X-Scope=a%20b%3Dc",
+			want: map[string]string{"x-scope": "a b=c"},
+		},
+		{
+			desc: "Value may contain an equals sign",
+			s:    "authorization=Basic dXNlcjpwYXNz==",
+			want: map[string]string{"authorization": "Basic dXNlcjpwYXNz=="},
+		},
+		{
+			desc:    "Missing value",
+			s:       "api-key",
+			wantErr: true,
+		},
+		{
+			desc:    "Missing key",
+			s:       "=secret",
+			wantErr: true,
+		},
+		{
+			desc:    "Bad encoding",
+			s:       "api-key=%zz",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseHeaders(test.s)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParseHeaders(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParseHeaders(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestParseHeaders(%s): got %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+// writeKeyPair writes a new self-signed certificate for cn and its key to certFile and keyFile.
+func writeKeyPair(t *testing.T, cn, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %s", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commonName returns the subject common name of the leaf of cert.
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %s", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeKeyPair(t, "first", certFile, keyFile)
+
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("TestCertReloader: newCertReloader: %s", err)
+	}
+	cert, err := r.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatalf("TestCertReloader: GetClientCertificate: %s", err)
+	}
+	if got := commonName(t, cert); got != "first" {
+		t.Fatalf("TestCertReloader: got certificate %q, want %q", got, "first")
+	}
+
+	// Rotate the key pair. The modification time is moved so the change is seen on file systems with a
+	// coarse clock.
+	writeKeyPair(t, "second", certFile, keyFile)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	cert, err = r.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatalf("TestCertReloader: GetClientCertificate after rotation: %s", err)
+	}
+	if got := commonName(t, cert); got != "second" {
+		t.Fatalf("TestCertReloader: got certificate %q after rotation, want %q", got, "second")
+	}
+
+	// A rotation that can't be loaded keeps the previous certificate.
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	cert, err = r.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatalf("TestCertReloader: GetClientCertificate after a bad rotation: %s", err)
+	}
+	if got := commonName(t, cert); got != "second" {
+		t.Errorf("TestCertReloader: got certificate %q after a bad rotation, want %q", got, "second")
+	}
+}
+
+func TestConnectWithBackoff(t *testing.T) {
+	errConnect := errors.New("connection refused")
+
+	tests := []struct {
+		desc      string
+		attempts  int
+		failures  int
+		cancel    bool
+		wantCalls int
+		wantErr   error
+	}{
+		{desc: "Connects on the first attempt", attempts: 3, wantCalls: 1},
+		{desc: "Connects after a failure", attempts: 3, failures: 1, wantCalls: 2},
+		{desc: "Gives up after the last attempt", attempts: 2, failures: 2, wantCalls: 2, wantErr: errConnect},
+		{desc: "Stops waiting when cancelled", attempts: 3, failures: 3, cancel: true, wantCalls: 1, wantErr: context.Canceled},
+	}
+
+	oldAttempts, oldTimeout := *OTLPConnectAttempts, *OTLPConnectTimeout
+	defer func() { *OTLPConnectAttempts, *OTLPConnectTimeout = oldAttempts, oldTimeout }()
+	*OTLPConnectTimeout = time.Minute
+
+	for _, test := range tests {
+		*OTLPConnectAttempts = test.attempts
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.cancel {
+			cancel()
+		}
+		calls := 0
+		connect := func(ctx context.Context) (sdktrace.SpanExporter, error) {
+			calls++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("TestConnectWithBackoff(%s): attempt %d has no deadline", test.desc, calls)
+			}
+			if calls <= test.failures {
+				return nil, errConnect
+			}
+			return tracetest.NewNoopExporter(), nil
+		}
+
+		exp, err := connectWithBackoff(ctx, zap.NewNop(), "collector:4317", connect)
+		cancel()
+		switch {
+		case test.wantErr == nil && err != nil:
+			t.Errorf("TestConnectWithBackoff(%s): got err == %s, want err == nil", test.desc, err)
+		case test.wantErr != nil && !errors.Is(err, test.wantErr):
+			t.Errorf("TestConnectWithBackoff(%s): got err == %v, want %v", test.desc, err, test.wantErr)
+		case test.wantErr == nil && exp == nil:
+			t.Errorf("TestConnectWithBackoff(%s): got a nil exporter", test.desc)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("TestConnectWithBackoff(%s): got %d connection attempts, want %d", test.desc, calls, test.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package telemetryflags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/env"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Flags related to sampling.
+var (
+	// SamplerName is the sampler that decides which traces are recorded, see Sampler.
+	SamplerName = FlagSet.String("sampler", env.Or("OTEL_TRACES_SAMPLER", "parentbased_always_on"), "The sampler that decides which "+
+		"traces are recorded. Valid values are: 'always_on', 'always_off', 'traceidratio', 'parentbased_always_on', "+
+		"'parentbased_always_off' and 'parentbased_traceidratio'. The client also has 'jaeger_remote', 'ratelimiting' and 'adaptive'. "+
+		"Defaults to env variable 'OTEL_TRACES_SAMPLER'.",
+	)
+	// SamplerArg is the argument of SamplerName, like the ratio of the traceidratio samplers.
+	SamplerArg = FlagSet.String("sampler-arg", env.Or("OTEL_TRACES_SAMPLER_ARG", "1.0"), "The ratio of traces sampled by the traceidratio "+
+		"samplers, between 0 and 1, the traces per second for 'ratelimiting' or the lowest ratio for 'adaptive'. For 'jaeger_remote' it "+
+		"is like 'endpoint=http://localhost:5778/sampling,pollingIntervalMs=5000'. Defaults to env variable 'OTEL_TRACES_SAMPLER_ARG'.",
+	)
+)
+
+// Sampler returns the sdktrace.Sampler named by -sampler, one of those defined for OTEL_TRACES_SAMPLER, using
+// -sampler-arg as the ratio for the ratio based samplers.
+func Sampler() (sdktrace.Sampler, error) {
+	switch strings.ToLower(*SamplerName) {
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio", "ratio":
+		ratio, err := SamplerRatio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		ratio, err := SamplerRatio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	}
+	return nil, fmt.Errorf("-sampler=%s is not a valid value", *SamplerName)
+}
+
+// IsRatioSampler reports if -sampler is one whose -sampler-arg is a ratio.
+func IsRatioSampler() bool {
+	switch strings.ToLower(*SamplerName) {
+	case "traceidratio", "ratio", "parentbased_traceidratio":
+		return true
+	}
+	return false
+}
+
+// SamplerRatio returns -sampler-arg as a ratio between 0 and 1.
+func SamplerRatio() (float64, error) {
+	ratio, err := strconv.ParseFloat(*SamplerArg, 64)
+	if err != nil {
+		return 0, fmt.Errorf("-sampler-arg=%s is not a valid ratio: %w", *SamplerArg, err)
+	}
+	if ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("-sampler-arg=%s must be between 0 and 1", *SamplerArg)
+	}
+	return ratio, nil
+}
@@ -0,0 +1,187 @@
+/*
+Package telemetryflags defines the flags that configure telemetry and are shared by every command of the
+demo, so the client and server are pointed at a collector and logged the same way:
+
+	cmd.PersistentFlags().AddGoFlagSet(telemetryflags.FlagSet)
+
+Like the commands' own flags, each defaults to an environment variable, if it has one.
+*/
+package telemetryflags
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/buildinfo"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/detectors"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/discovery"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/env"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/k8sinfo"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/otelenv"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/grpc"
+)
+
+// FlagSet holds the shared flags.
+var FlagSet = flag.NewFlagSet("telemetry", flag.ContinueOnError)
+
+var (
+	// OTLPEndpoint is the host:port of the OTLP collector.
+	OTLPEndpoint = FlagSet.String("otlp-endpoint", env.Or("OTEL_EXPORTER_OTLP_ENDPOINT", "0.0.0.0:4317"), "The host:port of the OTLP collector "+
+		"telemetry is exported to. Defaults to env variable 'OTEL_EXPORTER_OTLP_ENDPOINT'.",
+	)
+	// OTLPDiscovery is how the addresses of the OTLP collector are found, see discovery.Target.
+	OTLPDiscovery = FlagSet.String("otlp-discovery", env.Or("OTEL_EXPORTER_OTLP_DISCOVERY", "static"), "How the addresses of the OTLP collector "+
+		"are found: 'static' dials -otlp-endpoint as it is, 'dns' balances over the addresses of its host, like a Kubernetes headless "+
+		"service, and 'srv' balances over the targets of the SRV records -otlp-endpoint names. Records are looked up again every "+
+		"-otlp-discovery-interval. Only the gRPC exporters use it. Defaults to env variable 'OTEL_EXPORTER_OTLP_DISCOVERY'.",
+	)
+	// OTLPDiscoveryInterval is how often the records of -otlp-discovery are looked up again.
+	OTLPDiscoveryInterval = FlagSet.Duration("otlp-discovery-interval", env.Duration("OTEL_EXPORTER_OTLP_DISCOVERY_INTERVAL", 30*time.Second),
+		"How often the records of -otlp-discovery=dns or srv are looked up again, so collectors that are added or removed are "+
+			"balanced over. Defaults to env variable 'OTEL_EXPORTER_OTLP_DISCOVERY_INTERVAL'.",
+	)
+	// Propagators is the comma separated list of propagators, see propagators.New.
+	Propagators = FlagSet.String("propagators", env.Or("OTEL_PROPAGATORS", "tracecontext,baggage"), "A comma separated list of the formats "+
+		"trace context and baggage are propagated in: 'tracecontext', 'baggage', 'b3', 'b3multi', 'jaeger', 'xray', "+
+		"'correlationid', 'mesh' or 'none'. Defaults to env variable 'OTEL_PROPAGATORS'.",
+	)
+	// ShutdownTimeout bounds how long flushing telemetry may take on exit.
+	ShutdownTimeout = FlagSet.Duration("shutdown-timeout", 5*time.Second, "How long to wait for spans and metrics to be flushed to the exporters when exiting.")
+	// LogLevel is the lowest level of messages that are logged.
+	LogLevel = FlagSet.String("log-level", env.Or("LOG_LEVEL", "info"), "The lowest level of messages that are logged: 'debug', 'info', 'warn' or 'error'. "+
+		"Defaults to env variable 'LOG_LEVEL'.",
+	)
+	// DryRun prints spans to stdout in place of exporting telemetry, so no collector is needed.
+	DryRun = FlagSet.Bool("dry-run", env.Bool("DRY_RUN", false), "If true, spans are printed to stdout in place of being exported, and "+
+		"metrics and logs aren't exported, so no collector is needed. Defaults to env variable 'DRY_RUN'.",
+	)
+	// SDKDisabled installs no-op tracer and meter providers in place of the SDK.
+	SDKDisabled = FlagSet.Bool("sdk-disabled", env.Bool("OTEL_SDK_DISABLED", false), "If true, no-op tracer and meter providers are installed, "+
+		"so the demo runs without the overhead of telemetry, as a baseline to compare with. Trace context and baggage are still propagated. "+
+		"Defaults to env variable 'OTEL_SDK_DISABLED'.",
+	)
+	// EnvOnly sets up telemetry from the standard OTEL_* environment variables alone, see otelenv.
+	EnvOnly = FlagSet.Bool("otel-env-only", env.Bool("OTEL_ENV_ONLY", false), "If true, the tracer and meter providers are set up "+
+		"from the standard OTEL_* environment variables alone, like those the OpenTelemetry Operator injects, and the flags that "+
+		"configure telemetry are ignored. Defaults to env variable 'OTEL_ENV_ONLY'.",
+	)
+	// ResourceDetectors is the comma separated list of cloud resource detectors, see detectors.New.
+	ResourceDetectors = FlagSet.String("resource-detectors", env.Or("OTEL_RESOURCE_DETECTORS", "none"), "A comma separated list of the cloud "+
+		"platforms whose metadata, like the region and instance, is added to telemetry: 'ec2', 'ecs', 'eks', 'gcp', 'azure' or 'none'. "+
+		"Defaults to env variable 'OTEL_RESOURCE_DETECTORS'.",
+	)
+	// ResourceDetectionTimeout bounds how long the resource detectors may take at startup.
+	ResourceDetectionTimeout = FlagSet.Duration("resource-detection-timeout", env.Duration("OTEL_RESOURCE_DETECTION_TIMEOUT", 2*time.Second),
+		"How long -resource-detectors may take to query the platforms' metadata services at startup. "+
+			"Defaults to env variable 'OTEL_RESOURCE_DETECTION_TIMEOUT'.",
+	)
+	// LogFormat is how log entries are encoded.
+	LogFormat = FlagSet.String("log-format", env.Or("LOG_FORMAT", "json"), "How log entries are encoded: 'json' for log collectors or 'console' for "+
+		"people reading them in a terminal. Defaults to env variable 'LOG_FORMAT'.",
+	)
+)
+
+// logLevels are the valid values of -log-level, as zap spells them.
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "dpanic": true, "panic": true, "fatal": true}
+
+// Check adds the problems with the shared flags, and the environment variables they default to, to p.
+func Check(p *validate.Problems) {
+	p.Env(FlagSet)
+	if *EnvOnly {
+		// The SDK reads OTEL_EXPORTER_OTLP_ENDPOINT itself, as a URL.
+		_, err := otelenv.Load(os.Getenv)
+		p.Add(err)
+		p.Positive("shutdown-timeout", *ShutdownTimeout)
+		checkLogging(p)
+		return
+	}
+	// Unlike the OTLP exporters' own environment variable, the collector isn't given as a URL.
+	switch strings.ToLower(*OTLPDiscovery) {
+	case "static", "dns":
+		p.HostPort("otlp-endpoint", *OTLPEndpoint)
+	case "srv":
+		// The SRV records give the ports.
+		if strings.Contains(*OTLPEndpoint, ":") {
+			p.Addf("-otlp-endpoint=%s is not a valid value with -otlp-discovery=srv, use the name of the SRV records, like "+
+				"_otlp._tcp.otel-collector", *OTLPEndpoint)
+		}
+	default:
+		p.Addf("-otlp-discovery=%s is not a valid value, use 'static', 'dns' or 'srv'", *OTLPDiscovery)
+	}
+	p.Positive("otlp-discovery-interval", *OTLPDiscoveryInterval)
+	if _, err := propagators.New(*Propagators); err != nil {
+		p.Add(fmt.Errorf("-propagators=%s is not a valid value: %w", *Propagators, err))
+	}
+	p.Positive("shutdown-timeout", *ShutdownTimeout)
+	if _, err := detectors.New(*ResourceDetectors); err != nil {
+		p.Add(fmt.Errorf("-resource-detectors=%s is not a valid value: %w", *ResourceDetectors, err))
+	}
+	p.Positive("resource-detection-timeout", *ResourceDetectionTimeout)
+	checkOTLP(p)
+	if IsRatioSampler() {
+		_, err := SamplerRatio()
+		p.Add(err)
+	}
+	checkLogging(p)
+}
+
+// checkLogging adds the problems with the logging flags to p.
+func checkLogging(p *validate.Problems) {
+	if !logLevels[strings.ToLower(*LogLevel)] {
+		p.Addf("-log-level=%s is not a valid value, use 'debug', 'info', 'warn' or 'error'", *LogLevel)
+	}
+	switch strings.ToLower(*LogFormat) {
+	case "json", "console":
+	default:
+		p.Addf("-log-format=%s is not a valid value, use 'json' or 'console'", *LogFormat)
+	}
+}
+
+// OTLPTarget returns the gRPC dial target of the OTLP collector at endpoint, and the dial options that find its
+// addresses, as -otlp-discovery says.
+func OTLPTarget(endpoint string) (string, []grpc.DialOption, error) {
+	return discovery.Target(*OTLPDiscovery, endpoint, *OTLPDiscoveryInterval)
+}
+
+// Resource returns the resource that describes the service serviceName to trace, metric and log backends,
+// with the attributes of the platforms in -resource-detectors.
+func Resource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	dets, err := detectors.New(*ResourceDetectors)
+	if err != nil {
+		return nil, fmt.Errorf("-resource-detectors=%s is not a valid value: %w", *ResourceDetectors, err)
+	}
+	// Detectors for a platform the demo isn't on can wait for a metadata service that never answers.
+	ctx, cancel := context.WithTimeout(ctx, *ResourceDetectionTimeout)
+	defer cancel()
+
+	res, err := resource.New(ctx,
+		// First, so the attributes that follow take precedence.
+		resource.WithDetectors(dets...),
+		resource.WithProcess(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(
+			// the service name used to display traces in backends
+			semconv.ServiceNameKey.String(serviceName),
+		),
+		// service.version and the commit and date of the build, to tell which code produced the telemetry.
+		resource.WithAttributes(buildinfo.Get().Attributes()...),
+		// The pod, namespace, node and container, when running in Kubernetes.
+		resource.WithAttributes(k8sinfo.Get().Attributes()...),
+		// Last, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence, like those the OpenTelemetry
+		// Operator injects, or a name that tells apart servers that call each other with -downstream.
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,141 @@
+/*
+Package tracestate reads and writes a vendor's entry in the W3C tracestate of a trace. Unlike span
+attributes, the tracestate is propagated with the trace context, so an entry set by the client is seen by
+every service the trace passes through.
+
+The demo keeps its state in one entry, under Vendor, whose value is a list of fields like "st:debug":
+
+	ts, err := tracestate.SetField(parent.TraceState(), tracestate.Vendor, "st", "debug")
+	if err != nil {
+		// Do something
+	}
+	tier := tracestate.GetField(ts, tracestate.Vendor, "st")
+
+As W3C Trace Context requires, setting an entry moves it to the front of the tracestate, and entries of
+other vendors are dropped to keep the tracestate within MaxMembers and MaxLength: first entries longer
+than 128 characters, then the right-most entries.
+*/
+package tracestate
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Vendor is the key of the demo's entry.
+	Vendor = "demo"
+	// TierField is the field of the demo's entry with the sampling tier of the trace, which
+	// forcesample.Sampler sets.
+	TierField = "st"
+	// MaxMembers is the most entries a tracestate may have.
+	MaxMembers = 32
+	// MaxLength is the longest a tracestate may be before entries are dropped.
+	MaxLength = 512
+
+	// largeMember is the length above which entries are dropped first when a tracestate is too long.
+	largeMember = 128
+)
+
+// Get returns the value of vendor's entry in ts, or "" if there is none.
+func Get(ts trace.TraceState, vendor string) string {
+	return ts.Get(vendor)
+}
+
+// Set returns ts with vendor's entry set to value and moved to the front, dropping entries of other
+// vendors if the tracestate would be too large. If the entry isn't valid, ts is returned with an error.
+func Set(ts trace.TraceState, vendor, value string) (trace.TraceState, error) {
+	updated := ts.Delete(vendor)
+	// Make room for the entry, as the entries to the right are the least recently updated.
+	for updated.Len() >= MaxMembers {
+		updated = updated.Delete(memberKey(lastMember(updated, "")))
+	}
+	updated, err := updated.Insert(vendor, value)
+	if err != nil {
+		return ts, fmt.Errorf("%s=%s is not a valid tracestate entry: %w", vendor, value, err)
+	}
+	return truncate(updated, vendor), nil
+}
+
+// GetField returns the value of field in vendor's entry in ts, or "" if there is none.
+func GetField(ts trace.TraceState, vendor, field string) string {
+	for _, f := range strings.Split(ts.Get(vendor), ";") {
+		if k, v, ok := cut(f, ":"); ok && k == field {
+			return v
+		}
+	}
+	return ""
+}
+
+// SetField returns ts with field set to value in vendor's entry, which is moved to the front like Set.
+// The entry's other fields are kept in their order, and a new field is added last.
+func SetField(ts trace.TraceState, vendor, field, value string) (trace.TraceState, error) {
+	if field == "" || strings.ContainsAny(field, ":;") || strings.ContainsAny(value, ":;") {
+		return ts, fmt.Errorf("%s:%s is not a valid tracestate field", field, value)
+	}
+	var fields []string
+	found := false
+	if entry := ts.Get(vendor); entry != "" {
+		for _, f := range strings.Split(entry, ";") {
+			if k, _, _ := cut(f, ":"); k == field {
+				f = field + ":" + value
+				found = true
+			}
+			fields = append(fields, f)
+		}
+	}
+	if !found {
+		fields = append(fields, field+":"+value)
+	}
+	return Set(ts, vendor, strings.Join(fields, ";"))
+}
+
+// truncate drops entries other than keep's until ts is at most MaxLength long: first entries longer
+// than largeMember, then the right-most entries.
+func truncate(ts trace.TraceState, keep string) trace.TraceState {
+	for len(ts.String()) > MaxLength {
+		drop := ""
+		members := strings.Split(ts.String(), ",")
+		for i := len(members) - 1; i >= 0; i-- {
+			if len(members[i]) > largeMember && memberKey(members[i]) != keep {
+				drop = memberKey(members[i])
+				break
+			}
+		}
+		if drop == "" {
+			drop = memberKey(lastMember(ts, keep))
+		}
+		if drop == "" {
+			return ts
+		}
+		ts = ts.Delete(drop)
+	}
+	return ts
+}
+
+// lastMember returns the right-most entry of ts, like "key=value", that isn't keep's.
+func lastMember(ts trace.TraceState, keep string) string {
+	members := strings.Split(ts.String(), ",")
+	for i := len(members) - 1; i >= 0; i-- {
+		if members[i] != "" && memberKey(members[i]) != keep {
+			return members[i]
+		}
+	}
+	return ""
+}
+
+// memberKey returns the key of an entry like "key=value".
+func memberKey(member string) string {
+	k, _, _ := cut(member, "=")
+	return k
+}
+
+// cut is strings.Cut, which this module's Go version doesn't have.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
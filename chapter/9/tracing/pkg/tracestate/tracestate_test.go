@@ -0,0 +1,154 @@
+package tracestate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSet(t *testing.T) {
+	var many []string
+	for i := 0; i < MaxMembers; i++ {
+		many = append(many, fmt.Sprintf("k%d=v", i))
+	}
+	large := "big=" + strings.Repeat("x", 200)
+	medium := strings.Repeat("y", 100)
+
+	tests := []struct {
+		desc    string
+		ts      string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc:  "Empty",
+			value: "st:debug",
+			want:  "demo=st:debug",
+		},
+		{
+			desc:  "New entry goes first",
+			ts:    "a=1,b=2",
+			value: "st:debug",
+			want:  "demo=st:debug,a=1,b=2",
+		},
+		{
+			desc:  "Updated entry moves first",
+			ts:    "a=1,demo=st:free,b=2",
+			value: "st:debug",
+			want:  "demo=st:debug,a=1,b=2",
+		},
+		{
+			desc:  "Right-most entry dropped past MaxMembers",
+			ts:    strings.Join(many, ","),
+			value: "st:debug",
+			want:  "demo=st:debug," + strings.Join(many[:MaxMembers-1], ","),
+		},
+		{
+			desc:  "Large entry dropped first past MaxLength",
+			ts:    "a=" + medium + "," + large + ",b=" + medium + ",c=" + medium,
+			value: "st:debug",
+			want:  "demo=st:debug,a=" + medium + ",b=" + medium + ",c=" + medium,
+		},
+		{
+			desc:  "Right-most entries dropped past MaxLength",
+			ts:    "a=" + medium + ",b=" + medium + ",c=" + medium + ",d=" + medium + ",e=" + medium,
+			value: "st:debug",
+			want:  "demo=st:debug,a=" + medium + ",b=" + medium + ",c=" + medium + ",d=" + medium,
+		},
+		{
+			desc:    "Invalid value",
+			ts:      "a=1",
+			value:   "st=debug",
+			want:    "a=1",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		ts, err := trace.ParseTraceState(test.ts)
+		if err != nil {
+			t.Fatalf("TestSet(%s): bad tracestate: %s", test.desc, err)
+		}
+
+		got, err := Set(ts, Vendor, test.value)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestSet(%s): got err == nil, want err != nil", test.desc)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestSet(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		if got.String() != test.want {
+			t.Errorf("TestSet(%s): got %q, want %q", test.desc, got.String(), test.want)
+		}
+		if len(got.String()) > MaxLength || got.Len() > MaxMembers {
+			t.Errorf("TestSet(%s): got %d entries and length %d, want at most %d and %d", test.desc, got.Len(), len(got.String()), MaxMembers, MaxLength)
+		}
+	}
+}
+
+func TestSetField(t *testing.T) {
+	tests := []struct {
+		desc    string
+		ts      string
+		field   string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc:  "New entry",
+			ts:    "a=1",
+			field: "st",
+			value: "debug",
+			want:  "demo=st:debug,a=1",
+		},
+		{
+			desc:  "Field updated in place",
+			ts:    "a=1,demo=st:free;v:2",
+			field: "st",
+			value: "debug",
+			want:  "demo=st:debug;v:2,a=1",
+		},
+		{
+			desc:  "Field added last",
+			ts:    "demo=v:2",
+			field: "st",
+			value: "debug",
+			want:  "demo=v:2;st:debug",
+		},
+		{
+			desc:    "Invalid field value",
+			ts:      "demo=v:2",
+			field:   "st",
+			value:   "a;b",
+			want:    "demo=v:2",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		ts, err := trace.ParseTraceState(test.ts)
+		if err != nil {
+			t.Fatalf("TestSetField(%s): bad tracestate: %s", test.desc, err)
+		}
+
+		got, err := SetField(ts, Vendor, test.field, test.value)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestSetField(%s): got err == nil, want err != nil", test.desc)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestSetField(%s): got err == %s, want err == nil", test.desc, err)
+		}
+		if got.String() != test.want {
+			t.Errorf("TestSetField(%s): got %q, want %q", test.desc, got.String(), test.want)
+		}
+		if err == nil {
+			if v := GetField(got, Vendor, test.field); v != test.value {
+				t.Errorf("TestSetField(%s): got field %q, want %q", test.desc, v, test.value)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Package validate checks the configuration of the demo's commands at startup, collecting every problem so
+they are reported together instead of one per attempt to start:
+
+	var p validate.Problems
+	p.Env(flags)
+	p.HostPort("otlp-endpoint", *otlpEndpoint)
+	p.Ratio("max-error-rate", *maxErrorRate)
+	if err := p.Err(); err != nil {
+		...
+	}
+*/
+package validate
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Problems collects what is wrong with a configuration. The zero value has no problems.
+type Problems struct {
+	list []string
+}
+
+// Add adds err as a problem, unless it is nil.
+func (p *Problems) Add(err error) {
+	if err != nil {
+		p.list = append(p.list, err.Error())
+	}
+}
+
+// Addf adds a problem described like fmt.Sprintf.
+func (p *Problems) Addf(format string, args ...interface{}) {
+	p.list = append(p.list, fmt.Sprintf(format, args...))
+}
+
+// HostPort adds a problem if value, the value of -name, isn't a host:port, like collector:4317.
+func (p *Problems) HostPort(name, value string) {
+	if strings.Contains(value, "://") {
+		p.Addf("-%s=%s must be a host:port without a scheme, like %s", name, value, value[strings.Index(value, "://")+3:])
+		return
+	}
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		p.Addf("-%s=%s must be a host:port, like collector:4317", name, value)
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		p.Addf("-%s=%s has an invalid port %q", name, value, port)
+	}
+}
+
+// URL adds a problem if value, the value of -name, isn't an absolute http or https URL.
+func (p *Problems) URL(name, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.Addf("-%s=%s must be an http or https URL, like http://localhost:9411/api/v2/spans", name, value)
+	}
+}
+
+// Ratio adds a problem if value, the value of -name, isn't between 0 and 1.
+func (p *Problems) Ratio(name string, value float64) {
+	if value < 0 || value > 1 {
+		p.Addf("-%s=%v must be between 0 and 1, like 0.25 for 25%%", name, value)
+	}
+}
+
+// NonNegative adds a problem if value, the value of -name, is negative.
+func (p *Problems) NonNegative(name string, value time.Duration) {
+	if value < 0 {
+		p.Addf("-%s=%s cannot be negative", name, value)
+	}
+}
+
+// Positive adds a problem if value, the value of -name, isn't positive.
+func (p *Problems) Positive(name string, value time.Duration) {
+	if value <= 0 {
+		p.Addf("-%s=%s must be positive", name, value)
+	}
+}
+
+// envVarRE finds the environment variable a flag defaults to in its usage, which by convention ends
+// with "Defaults to env variable 'NAME'", followed by " in milliseconds" for durations set in milliseconds.
+var envVarRE = regexp.MustCompile(`Defaults to env variable '([^']+)'( in milliseconds)?`)
+
+// Env adds a problem for each environment variable a flag of fs defaults to that is set to a value the
+// flag can't have. Such values are otherwise ignored, and the flag's default used without a word.
+func (p *Problems) Env(fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		m := envVarRE.FindStringSubmatch(f.Usage)
+		if m == nil {
+			return
+		}
+		v, ok := os.LookupEnv(m[1])
+		if !ok {
+			return
+		}
+		if err := parseAs(f, v, m[2] != ""); err != nil {
+			p.Addf("env variable %s=%s is not a valid value of -%s, whose default %s was used instead", m[1], v, f.Name, f.DefValue)
+		}
+	})
+}
+
+// parseAs returns an error if v can't be parsed as a value of f. Durations in milliseconds are integers.
+func parseAs(f *flag.Flag, v string, millis bool) error {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return nil
+	}
+	var err error
+	switch getter.Get().(type) {
+	case bool:
+		_, err = strconv.ParseBool(v)
+	case int:
+		_, err = strconv.Atoi(v)
+	case float64:
+		_, err = strconv.ParseFloat(v, 64)
+	case time.Duration:
+		if millis {
+			_, err = strconv.Atoi(v)
+		} else {
+			_, err = time.ParseDuration(v)
+		}
+	}
+	return err
+}
+
+// Err returns an *Error with the problems, or nil if there are none.
+func (p *Problems) Err() error {
+	if len(p.list) == 0 {
+		return nil
+	}
+	return &Error{Problems: p.list}
+}
+
+// Error is the error of a configuration with problems.
+type Error struct {
+	// Problems describes each problem, with what to change.
+	Problems []string
+}
+
+// Error implements error.Error.
+func (e *Error) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+	return fmt.Sprintf("%d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// Print writes err to w, with each problem on its own line if it is an *Error.
+func Print(w io.Writer, err error) {
+	var verr *Error
+	if !errors.As(err, &verr) {
+		fmt.Fprintf(w, "invalid configuration: %s\n", err)
+		return
+	}
+	fmt.Fprintln(w, "invalid configuration:")
+	for _, problem := range verr.Problems {
+		fmt.Fprintf(w, "  - %s\n", problem)
+	}
+}
@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"errors"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestProblems(t *testing.T) {
+	tests := []struct {
+		desc  string
+		check func(p *Problems)
+		want  int
+	}{
+		{
+			desc: "Valid",
+			check: func(p *Problems) {
+				p.HostPort("otlp-endpoint", "collector:4317")
+				p.URL("zipkin-endpoint", "http://localhost:9411/api/v2/spans")
+				p.Ratio("max-error-rate", 0.01)
+				p.NonNegative("duration", 0)
+				p.Positive("shutdown-timeout", time.Second)
+				p.Add(nil)
+			},
+		},
+		{
+			desc: "Host:port with a scheme",
+			check: func(p *Problems) {
+				p.HostPort("otlp-endpoint", "http://collector:4317")
+			},
+			want: 1,
+		},
+		{
+			desc: "Host without a port",
+			check: func(p *Problems) {
+				p.HostPort("otlp-endpoint", "collector")
+			},
+			want: 1,
+		},
+		{
+			desc: "Invalid port",
+			check: func(p *Problems) {
+				p.HostPort("otlp-endpoint", "collector:http")
+			},
+			want: 1,
+		},
+		{
+			desc: "Everything wrong",
+			check: func(p *Problems) {
+				p.URL("zipkin-endpoint", "localhost:9411")
+				p.Ratio("max-error-rate", 5)
+				p.NonNegative("duration", -time.Second)
+				p.Positive("shutdown-timeout", 0)
+				p.Add(errors.New("-concurrency must be at least 1, was 0"))
+				p.Addf("-traffic-model=%s is not a valid value", "open")
+			},
+			want: 6,
+		},
+	}
+
+	for _, test := range tests {
+		var p Problems
+		test.check(&p)
+		err := p.Err()
+		if test.want == 0 {
+			if err != nil {
+				t.Errorf("TestProblems(%s): got err == %s, want err == nil", test.desc, err)
+			}
+			continue
+		}
+		var verr *Error
+		if !errors.As(err, &verr) {
+			t.Errorf("TestProblems(%s): got err == %v, want *Error", test.desc, err)
+			continue
+		}
+		if len(verr.Problems) != test.want {
+			t.Errorf("TestProblems(%s): got problems %q, want %d", test.desc, verr.Problems, test.want)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("TEST_CONCURRENCY", "ten")
+	t.Setenv("TEST_RATE", "2.5")
+	t.Setenv("TEST_TIMEOUT", "5")
+	t.Setenv("TEST_DELAY", "5")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("concurrency", 1, "Workers. Defaults to env variable 'TEST_CONCURRENCY'.")
+	fs.Float64("rate", 0, "Requests per second. Defaults to env variable 'TEST_RATE'.")
+	fs.Duration("timeout", time.Second, "How long to wait. Defaults to env variable 'TEST_TIMEOUT'.")
+	fs.Duration("delay", time.Second, "How long to wait. Defaults to env variable 'TEST_DELAY' in milliseconds.")
+	fs.String("name", "", "A name without an environment variable.")
+
+	var p Problems
+	p.Env(fs)
+	// TEST_CONCURRENCY isn't a number and TEST_TIMEOUT has no unit.
+	if len(p.list) != 2 {
+		t.Errorf("TestEnv: got problems %q, want 2", p.list)
+	}
+}
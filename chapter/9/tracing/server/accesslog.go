@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// accessLog wraps next so every request it serves is logged once it is answered, with the trace and
+// span IDs of its span so the entry and the trace can be found from each other. It must be wrapped by
+// otelhttp, so the request's span is in its context.
+func accessLog(next http.Handler, log *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		WithCorrelation(trace.SpanFromContext(r.Context()), log).Info(
+			"access",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("proto", r.Proto),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+			zap.Int("status", rw.status),
+			zap.Int64("bytes", rw.written),
+			zap.Duration("latency", time.Since(start)),
+		)
+	})
+}
+
+// accessLogWriter records the status and body size written to an http.ResponseWriter.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader.
+func (w *accessLogWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.Write.
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, so handlers can stream responses through an accessLogWriter.
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// baggageSpanProcessor copies members of the baggage propagated with a request onto every span started
+// for it, like the server's own request span and the spans of its queries, so traces can be searched by
+// business context like the user.id the client set.
+type baggageSpanProcessor struct {
+	keys []string
+}
+
+// newBaggageSpanProcessor returns a baggageSpanProcessor that copies the members in keys, a comma
+// separated list.
+func newBaggageSpanProcessor(keys string) baggageSpanProcessor {
+	var p baggageSpanProcessor
+	for _, k := range strings.Split(keys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			p.keys = append(p.keys, k)
+		}
+	}
+	return p
+}
+
+// OnStart implements sdktrace.SpanProcessor.OnStart.
+func (p baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	b := baggage.FromContext(parent)
+	for _, k := range p.keys {
+		if m := b.Member(k); m.Key() != "" {
+			s.SetAttributes(attribute.String(k, m.Value()))
+		}
+	}
+}
+
+// OnEnd implements sdktrace.SpanProcessor.OnEnd.
+func (p baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown implements sdktrace.SpanProcessor.Shutdown.
+func (p baggageSpanProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush implements sdktrace.SpanProcessor.ForceFlush.
+func (p baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errQueryFailed is returned by queries -db-error-rate fails.
+var errQueryFailed = errors.New("simulated database error")
+
+// fakeDB is a simulated database, so traces have a database tier without running one. Queries don't
+// read or write anything: they take latency plus up to jitter, fail with the probability errorRate,
+// and each creates a client span with the db.* semantic convention attributes.
+type fakeDB struct {
+	name      string
+	latency   time.Duration
+	jitter    time.Duration
+	errorRate float64
+}
+
+// newFakeDB returns the fakeDB set by the -db-* flags, or nil if -db is false.
+func newFakeDB() (*fakeDB, error) {
+	if !*dbEnabled {
+		return nil, nil
+	}
+	if *dbLatency < 0 || *dbLatencyJitter < 0 {
+		return nil, fmt.Errorf("-db-latency and -db-latency-jitter cannot be negative")
+	}
+	if *dbErrorRate < 0 || *dbErrorRate > 1 {
+		return nil, fmt.Errorf("-db-error-rate=%v is not a valid value", *dbErrorRate)
+	}
+	return &fakeDB{
+		name:      "demo",
+		latency:   *dbLatency,
+		jitter:    *dbLatencyJitter,
+		errorRate: *dbErrorRate,
+	}, nil
+}
+
+// query runs statement, an operation like SELECT on table, in a child span of the span in ctx named
+// like "SELECT greetings".
+func (db *fakeDB) query(ctx context.Context, operation, table, statement string) error {
+	ctx, span := otel.Tracer("demo-server-tracer").Start(
+		ctx,
+		operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemOtherSQL,
+			semconv.DBNameKey.String(db.name),
+			semconv.DBOperationKey.String(operation),
+			semconv.DBSQLTableKey.String(table),
+			semconv.DBStatementKey.String(statement),
+			semconv.NetPeerNameKey.String("fake-db"),
+		),
+	)
+	defer span.End()
+
+	latency := db.latency
+	if db.jitter > 0 {
+		latency += time.Duration(rand.Int63n(int64(db.jitter)))
+	}
+	select {
+	case <-ctx.Done():
+		span.SetStatus(codes.Error, ctx.Err().Error())
+		return ctx.Err()
+	case <-time.After(latency):
+	}
+	if rand.Float64() < db.errorRate {
+		span.RecordError(errQueryFailed)
+		span.SetStatus(codes.Error, errQueryFailed.Error())
+		return errQueryFailed
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/deadline"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// depthHeader is the number of services a request has passed through, so a loop of services that call
+// each other downstream stops at -downstream-max-depth.
+const depthHeader = "X-Demo-Depth"
+
+// downstreamClient sends requests to the -downstream services. Its transport creates a span for each
+// request and propagates the trace context in its headers.
+var downstreamClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
+// parseDownstreams parses a comma separated list of the URLs of downstream services.
+func parseDownstreams(s string) ([]string, error) {
+	var urls []string
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("-downstream: %q is not an http or https URL", raw)
+		}
+		urls = append(urls, raw)
+	}
+	return urls, nil
+}
+
+// callDownstreams returns a handler that calls each of downstreams in parallel, then next. If a call fails,
+// the request fails with a 502 status and next isn't called. Requests that have passed through maxDepth
+// services are passed straight to next.
+func callDownstreams(next http.Handler, downstreams []string, maxDepth int, log *zap.Logger) http.Handler {
+	if len(downstreams) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		depth, _ := strconv.Atoi(req.Header.Get(depthHeader))
+		if depth >= maxDepth {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		errs := make([]error, len(downstreams))
+		var wg sync.WaitGroup
+		for i, u := range downstreams {
+			wg.Add(1)
+			go func(i int, u string) {
+				defer wg.Done()
+				errs[i] = callDownstream(ctx, u, depth+1)
+			}(i, u)
+		}
+		wg.Wait()
+
+		span := trace.SpanFromContext(ctx)
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "downstream call failed")
+			WithCorrelation(span, log).Warn("downstream call failed", zap.String("url", downstreams[i]), zap.Error(err))
+			http.Error(w, "downstream call failed", http.StatusBadGateway)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// callDownstream sends a GET request to u in the trace of ctx, with depth in the depthHeader. Requests
+// that get no response or a 5xx status fail.
+func callDownstream(ctx context.Context, u string, depth int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request to %s: %w", u, err)
+	}
+	req.Header.Set(depthHeader, strconv.Itoa(depth))
+	// Downstream services get what is left of the caller's deadline.
+	deadline.Inject(ctx, req.Header)
+	res, err := downstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// Reading the whole body lets the connection be reused.
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("%s returned status %d", u, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// faults are the faults injected into a request, set by the -fault-* flags and overridden by the
+// request's fault_* query parameters, like /hello?fault_error_rate=0.5&fault_status=503.
+type faults struct {
+	// latency is added to a request with the probability latencyRate.
+	latency     time.Duration
+	latencyRate float64
+	// errorRate is the probability a request fails with errorStatus.
+	errorRate   float64
+	errorStatus int
+	// dribbleRate is the probability the response body is written slowly, a byte every dribbleInterval.
+	dribbleRate     float64
+	dribbleInterval time.Duration
+}
+
+// faultsFromFlags returns the faults set by the -fault-* flags.
+func faultsFromFlags() (faults, error) {
+	f := faults{
+		latency:         *faultLatency,
+		latencyRate:     *faultLatencyRate,
+		errorRate:       *faultErrorRate,
+		errorStatus:     *faultStatus,
+		dribbleRate:     *faultDribbleRate,
+		dribbleInterval: *faultDribbleInterval,
+	}
+	if err := f.validate(); err != nil {
+		return faults{}, fmt.Errorf("invalid -fault-* flags: %w", err)
+	}
+	return f, nil
+}
+
+// validate returns an error if f has a rate outside [0, 1], a negative duration or an errorStatus that
+// isn't an error.
+func (f faults) validate() error {
+	for _, rate := range []float64{f.latencyRate, f.errorRate, f.dribbleRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("rates must be between 0 and 1, got %v", rate)
+		}
+	}
+	if f.latency < 0 || f.dribbleInterval < 0 {
+		return fmt.Errorf("durations cannot be negative")
+	}
+	if f.errorStatus < 400 || f.errorStatus > 599 {
+		return fmt.Errorf("status %d is not an error status", f.errorStatus)
+	}
+	return nil
+}
+
+// withQuery returns f with the faults set by the fault_* parameters of q.
+func (f faults) withQuery(q url.Values) (faults, error) {
+	durations := map[string]*time.Duration{
+		"fault_latency":          &f.latency,
+		"fault_dribble_interval": &f.dribbleInterval,
+	}
+	for name, d := range durations {
+		if v := q.Get(name); v != "" {
+			var err error
+			if *d, err = time.ParseDuration(v); err != nil {
+				return faults{}, fmt.Errorf("%s=%s is not a valid duration", name, v)
+			}
+		}
+	}
+	rates := map[string]*float64{
+		"fault_latency_rate": &f.latencyRate,
+		"fault_error_rate":   &f.errorRate,
+		"fault_dribble_rate": &f.dribbleRate,
+	}
+	for name, r := range rates {
+		if v := q.Get(name); v != "" {
+			var err error
+			if *r, err = strconv.ParseFloat(v, 64); err != nil {
+				return faults{}, fmt.Errorf("%s=%s is not a valid rate", name, v)
+			}
+		}
+	}
+	if v := q.Get("fault_status"); v != "" {
+		var err error
+		if f.errorStatus, err = strconv.Atoi(v); err != nil {
+			return faults{}, fmt.Errorf("fault_status=%s is not a valid status", v)
+		}
+	}
+	if err := f.validate(); err != nil {
+		return faults{}, err
+	}
+	return f, nil
+}
+
+// injectFaults returns a handler that injects faults into requests before passing them to next, with
+// defaults unless the request's query parameters override them. Each injected fault is a "fault injected"
+// event on the request's span, with the fault's type in demo.fault.
+func injectFaults(next http.Handler, defaults faults, log *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		f, err := defaults.withQuery(req.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		span := trace.SpanFromContext(req.Context())
+		reqLog := WithCorrelation(span, log)
+
+		if f.latency > 0 && rand.Float64() < f.latencyRate {
+			span.AddEvent("fault injected", trace.WithAttributes(
+				attribute.String("demo.fault", "latency"),
+				attribute.Int64("demo.fault.latency_ms", f.latency.Milliseconds()),
+			))
+			reqLog.Debug("injecting latency", zap.Duration("latency", f.latency))
+			select {
+			case <-req.Context().Done():
+				return
+			case <-time.After(f.latency):
+			}
+		}
+		if rand.Float64() < f.errorRate {
+			span.AddEvent("fault injected", trace.WithAttributes(
+				attribute.String("demo.fault", "error"),
+				attribute.Int("http.status_code", f.errorStatus),
+			))
+			reqLog.Debug("injecting error", zap.Int("status", f.errorStatus))
+			http.Error(w, "injected fault", f.errorStatus)
+			return
+		}
+		if rand.Float64() < f.dribbleRate {
+			span.AddEvent("fault injected", trace.WithAttributes(
+				attribute.String("demo.fault", "dribble"),
+				attribute.Int64("demo.fault.dribble_interval_ms", f.dribbleInterval.Milliseconds()),
+			))
+			reqLog.Debug("dribbling response", zap.Duration("interval", f.dribbleInterval))
+			w = &dribbleWriter{ResponseWriter: w, ctx: req.Context(), interval: f.dribbleInterval}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// dribbleWriter is a http.ResponseWriter that writes the body a byte at a time, flushing each byte and
+// waiting interval before the next, like a server on a congested link.
+type dribbleWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	interval time.Duration
+}
+
+// Write implements http.ResponseWriter.Write.
+func (d *dribbleWriter) Write(b []byte) (int, error) {
+	flusher, _ := d.ResponseWriter.(http.Flusher)
+	for i := range b {
+		if _, err := d.ResponseWriter.Write(b[i : i+1]); err != nil {
+			return i, err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-d.ctx.Done():
+			return i + 1, d.ctx.Err()
+		case <-time.After(d.interval):
+		}
+	}
+	return len(b), nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFaultsWithQuery(t *testing.T) {
+	defaults := faults{
+		latency:         time.Second,
+		latencyRate:     1,
+		errorStatus:     500,
+		dribbleInterval: 100 * time.Millisecond,
+	}
+
+	tests := []struct {
+		desc    string
+		query   string
+		want    faults
+		wantErr bool
+	}{
+		{
+			desc:  "No parameters",
+			query: "",
+			want:  defaults,
+		},
+		{
+			desc:  "Parameters override defaults",
+			query: "fault_latency=250ms&fault_latency_rate=0.5&fault_error_rate=0.1&fault_status=503&fault_dribble_rate=1&fault_dribble_interval=1s",
+			want: faults{
+				latency:         250 * time.Millisecond,
+				latencyRate:     0.5,
+				errorRate:       0.1,
+				errorStatus:     503,
+				dribbleRate:     1,
+				dribbleInterval: time.Second,
+			},
+		},
+		{
+			desc:    "Bad duration",
+			query:   "fault_latency=soon",
+			wantErr: true,
+		},
+		{
+			desc:    "Rate above 1",
+			query:   "fault_error_rate=2",
+			wantErr: true,
+		},
+		{
+			desc:    "Status that isn't an error",
+			query:   "fault_status=200",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		q, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("TestFaultsWithQuery(%s): bad test query: %s", test.desc, err)
+		}
+		got, err := defaults.withQuery(q)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestFaultsWithQuery(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestFaultsWithQuery(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if got != test.want {
+			t.Errorf("TestFaultsWithQuery(%s): got %+v, want %+v", test.desc, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/tracestate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The baggage members that toggle how the server answers, which the client sets with flags like
+// -baggage=demo.variant=b,demo.tier=premium.
+const (
+	// variantKey picks the greeting: "b" answers "Hello, World!" instead of "Hello World".
+	variantKey = "demo.variant"
+	// tierKey picks the latency: "premium" requests take a tenth of the simulated latency.
+	tierKey = "demo.tier"
+)
+
+// features is how the server answers a request, from the baggage propagated with it. It shows how
+// business context set by a caller can drive the behavior of the services it calls, and the differences
+// can be seen in traces by the demo.variant and demo.tier attributes of the server's spans.
+type features struct {
+	variant string
+	tier    string
+}
+
+// featuresFromContext returns the features of the request whose baggage is in ctx.
+func featuresFromContext(ctx context.Context) features {
+	b := baggage.FromContext(ctx)
+	f := features{variant: "a", tier: "free"}
+	if v := b.Member(variantKey).Value(); v == "b" {
+		f.variant = v
+	}
+	if t := b.Member(tierKey).Value(); t == "premium" {
+		f.tier = t
+	}
+	return f
+}
+
+// greeting returns the greeting for name.
+func (f features) greeting(name string) string {
+	if f.variant == "b" {
+		return "Hello, " + name + "!"
+	}
+	return "Hello " + name
+}
+
+// latency returns the latency to simulate instead of d.
+func (f features) latency(d time.Duration) time.Duration {
+	if f.tier == "premium" {
+		return d / 10
+	}
+	return d
+}
+
+// samplingTier returns the demo.sampling_tier attribute of span, from the tracestate the client's sampler
+// set, if it has one. The tracestate is propagated with the trace, so the tier is known on every hop.
+func samplingTier(span trace.Span) []attribute.KeyValue {
+	tier := tracestate.GetField(span.SpanContext().TraceState(), tracestate.Vendor, tracestate.TierField)
+	if tier == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String("demo.sampling_tier", tier)}
+}
+
+// attributes returns the span attributes that record the features.
+func (f features) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(variantKey, f.variant),
+		attribute.String(tierKey, f.tier),
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestFeaturesFromContext(t *testing.T) {
+	tests := []struct {
+		desc         string
+		baggage      string
+		wantGreeting string
+		wantLatency  time.Duration
+	}{
+		{
+			desc:         "No baggage",
+			wantGreeting: "Hello World",
+			wantLatency:  time.Second,
+		},
+		{
+			desc:         "Variant b and premium tier",
+			baggage:      "demo.variant=b,demo.tier=premium",
+			wantGreeting: "Hello, World!",
+			wantLatency:  100 * time.Millisecond,
+		},
+		{
+			desc:         "Unknown values",
+			baggage:      "demo.variant=z,demo.tier=gold",
+			wantGreeting: "Hello World",
+			wantLatency:  time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := baggage.Parse(test.baggage)
+		if err != nil {
+			t.Fatalf("TestFeaturesFromContext(%s): bad baggage: %s", test.desc, err)
+		}
+		f := featuresFromContext(baggage.ContextWithBaggage(context.Background(), b))
+
+		if got := f.greeting("World"); got != test.wantGreeting {
+			t.Errorf("TestFeaturesFromContext(%s): got greeting %q, want %q", test.desc, got, test.wantGreeting)
+		}
+		if got := f.latency(time.Second); got != test.wantLatency {
+			t.Errorf("TestFeaturesFromContext(%s): got latency %v, want %v", test.desc, got, test.wantLatency)
+		}
+	}
+}
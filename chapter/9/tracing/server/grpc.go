@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/deadline"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/hello"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// greeterServer is the gRPC counterpart of the /hello handler.
+type greeterServer struct {
+	hello.UnimplementedGreeterServer
+	log *zap.Logger
+	db  *fakeDB
+}
+
+// SayHello implements hello.GreeterServer.SayHello. Like /hello, it answers after a random delay and, with
+// -db, queries the simulated database, and its baggage toggles its features.
+func (g *greeterServer) SayHello(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	f := featuresFromContext(ctx)
+	sleep := f.latency(randomLatency())
+	select {
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-time.After(sleep):
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(f.attributes()...)
+	span.SetAttributes(samplingTier(span)...)
+	span.SetAttributes(deadline.Remaining(ctx)...)
+	span.SetAttributes(propagators.MeshAttributes(ctx, nil)...)
+	if g.db != nil {
+		if err := queryGreeting(ctx, g.db); err != nil {
+			WithCorrelation(span, g.log).Error("query failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, "query failed")
+		}
+	}
+	WithCorrelation(span, g.log).Debug("request handled", zap.Duration("sleep", sleep))
+	return wrapperspb.String(f.greeting(in.GetValue())), nil
+}
+
+// SayHelloStream implements hello.GreeterServer.SayHelloStream. It answers each name like SayHello, after
+// its own random delay, until the client closes its side of the stream.
+func (g *greeterServer) SayHelloStream(stream hello.Greeter_SayHelloStreamServer) error {
+	ctx := stream.Context()
+	f := featuresFromContext(ctx)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(f.attributes()...)
+	span.SetAttributes(samplingTier(span)...)
+	span.SetAttributes(deadline.Remaining(ctx)...)
+	span.SetAttributes(propagators.MeshAttributes(ctx, nil)...)
+	for n := 0; ; n++ {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			WithCorrelation(span, g.log).Debug("stream handled", zap.Int("messages", n))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(f.latency(randomLatency())):
+		}
+		if err := stream.Send(wrapperspb.String(f.greeting(in.GetValue()))); err != nil {
+			return err
+		}
+	}
+}
+
+// newGRPCServer returns a server of the Greeter service. The otelgrpc interceptors continue the trace
+// propagated in each call's metadata, so calls are in the same traces as the client's spans.
+func newGRPCServer(log *zap.Logger, db *fakeDB) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
+	)
+	hello.RegisterGreeterServer(s, &greeterServer{log: log, db: db})
+	return s
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// readiness is whether the server should be sent requests. It is served at /readyz, so a Kubernetes
+// readiness probe only routes requests to the server once it can trace them, and stops while it shuts down.
+type readiness struct {
+	ready int32
+}
+
+// set sets whether the server is ready.
+func (r *readiness) set(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&r.ready, v)
+}
+
+// isReady returns whether the server is ready.
+func (r *readiness) isReady() bool {
+	return atomic.LoadInt32(&r.ready) == 1
+}
+
+// ServeHTTP implements http.Handler. It responds 200 if the server is ready and 503 if it isn't.
+func (r *readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !r.isReady() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// handleHealthz responds 200 while the server is running, for a Kubernetes liveness probe.
+func handleHealthz(w http.ResponseWriter, req *http.Request) {
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		desc       string
+		ready      bool
+		wantStatus int
+	}{
+		{desc: "Starting", ready: false, wantStatus: http.StatusServiceUnavailable},
+		{desc: "Ready", ready: true, wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := &readiness{}
+		r.set(test.ready)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != test.wantStatus {
+			t.Errorf("TestReadiness(%s): got status %d, want %d", test.desc, rec.Code, test.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLogger creates the logger for operational messages at the -log-level, encoded as set by -log-format
+// like the client's, so the logs of both can be collected and searched the same way.
+func newLogger() (*zap.Logger, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return nil, fmt.Errorf("-log-level=%s is not a valid value", *logLevel)
+	}
+
+	config := zap.NewProductionConfig()
+	switch strings.ToLower(*logFormat) {
+	case "json":
+	case "console":
+		config.Encoding = "console"
+		config.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	default:
+		return nil, fmt.Errorf("-log-format=%s is not a valid value", *logFormat)
+	}
+	config.Level = zap.NewAtomicLevelAt(level)
+	config.EncoderConfig.TimeKey = "time"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	return config.Build(zap.Fields(zap.String("service", serviceName)))
+}
+
+// WithCorrelation adds span and trace IDs to a zap logger to enable better correlation between traces and logs.
+// The IDs are formatted like the client's, so the entries of both for a request can be found by one ID.
+func WithCorrelation(span trace.Span, log *zap.Logger) *zap.Logger {
+	return log.With(
+		zap.String("span_id", convertTraceID(span.SpanContext().SpanID().String())),
+		zap.String("trace_id", convertTraceID(span.SpanContext().TraceID().String())),
+	)
+}
+
+// convertTraceID returns the low 64 bits of a hex encoded ID as a decimal string, as the client logs them.
+func convertTraceID(id string) string {
+	if len(id) < 16 {
+		return ""
+	}
+	if len(id) > 16 {
+		id = id[16:]
+	}
+	intValue, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatUint(intValue, 10)
+}
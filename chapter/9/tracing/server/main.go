@@ -1,132 +1,513 @@
-package main
+package server
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/deadline"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/env"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/forcesample"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/otelenv"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/promexport"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/propagators"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
 )
 
-var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+// serviceName is the name the server is known by in trace backends.
+const serviceName = "demo-server"
 
-// main initializes tracing provider and listens to requests at /hello returning "Hello World!" with
-// randomized latency.
-func main() {
-	shutdown := initTraceProvider()
+// Flags are the server's own flags, which the tracedemo command parses with telemetryflags.FlagSet.
+var Flags = flag.NewFlagSet("server", flag.ContinueOnError)
+
+// The flags shared by every command, which the server is configured with like its own.
+var (
+	otlpEndpoint    = telemetryflags.OTLPEndpoint
+	propagatorNames = telemetryflags.Propagators
+	shutdownTimeout = telemetryflags.ShutdownTimeout
+	logLevel        = telemetryflags.LogLevel
+	logFormat       = telemetryflags.LogFormat
+	dryRun          = telemetryflags.DryRun
+	sdkDisabled     = telemetryflags.SDKDisabled
+	otelEnvOnly     = telemetryflags.EnvOnly
+)
+
+// Flags related to serving requests.
+var (
+	listenAddr = Flags.String("listen-addr", env.Or("LISTEN_ADDR", ":7080"), "The address the server listens on for requests. "+
+		"Defaults to env variable 'LISTEN_ADDR'.",
+	)
+	grpcListenAddr = Flags.String("grpc-listen-addr", env.Or("GRPC_LISTEN_ADDR", ":7081"), "The address the server listens on for gRPC calls "+
+		"of the Greeter service. If empty, gRPC isn't served. Defaults to env variable 'GRPC_LISTEN_ADDR'.",
+	)
+	downstreams = Flags.String("downstream", env.Or("DOWNSTREAM_ENDPOINTS", ""), "A comma separated list of the URLs of services, like other "+
+		"instances of this server, that are called in parallel for each request before it is answered. Defaults to env variable 'DOWNSTREAM_ENDPOINTS'.",
+	)
+	downstreamMaxDepth = Flags.Int("downstream-max-depth", env.Int("DOWNSTREAM_MAX_DEPTH", 3), "The most services a request passes through "+
+		"before -downstream services are no longer called, which stops services that call each other. Defaults to env variable 'DOWNSTREAM_MAX_DEPTH'.",
+	)
+	rateLimitRPS = Flags.Float64("rate-limit", env.Float("RATE_LIMIT", 0), "If set, the most requests a second the server handles. More "+
+		"are rejected with a 429 status. Defaults to env variable 'RATE_LIMIT'.",
+	)
+	rateLimitBurst = Flags.Int("rate-limit-burst", env.Int("RATE_LIMIT_BURST", 10), "With -rate-limit, the most requests handled at once "+
+		"after a quiet period. Defaults to env variable 'RATE_LIMIT_BURST'.",
+	)
+	accessLogEnabled = Flags.Bool("access-log", env.Bool("ACCESS_LOG", true), "If true, each request is logged once it is answered, "+
+		"with its status, latency and the IDs of its trace. Defaults to env variable 'ACCESS_LOG'.",
+	)
+	drainTimeout = Flags.Duration("drain-timeout", env.Duration("DRAIN_TIMEOUT", 15*time.Second), "When the server is stopped with SIGINT or "+
+		"SIGTERM, how long in-flight requests have to finish before their connections are closed. Defaults to env variable 'DRAIN_TIMEOUT'.",
+	)
+)
+
+// Flags related to the simulated database.
+var (
+	dbEnabled = Flags.Bool("db", env.Bool("SIMULATED_DB", false), "If true, each request runs queries against a simulated database, "+
+		"each with a span. Defaults to env variable 'SIMULATED_DB'.",
+	)
+	dbLatency = Flags.Duration("db-latency", env.Duration("DB_LATENCY", 20*time.Millisecond), "The least time a query takes. "+
+		"Defaults to env variable 'DB_LATENCY'.",
+	)
+	dbLatencyJitter = Flags.Duration("db-latency-jitter", env.Duration("DB_LATENCY_JITTER", 30*time.Millisecond), "The most time added at random "+
+		"to -db-latency. Defaults to env variable 'DB_LATENCY_JITTER'.",
+	)
+	dbErrorRate = Flags.Float64("db-error-rate", env.Float("DB_ERROR_RATE", 0), "The probability, from 0 to 1, a query fails. "+
+		"Defaults to env variable 'DB_ERROR_RATE'.",
+	)
+)
+
+// Flags related to injecting faults, which requests can override with fault_* query parameters of the
+// same names, like /hello?fault_error_rate=0.5.
+var (
+	faultLatency = Flags.Duration("fault-latency", env.Duration("FAULT_LATENCY", 0), "Latency added to requests, with the probability "+
+		"-fault-latency-rate. Defaults to env variable 'FAULT_LATENCY'.",
+	)
+	faultLatencyRate = Flags.Float64("fault-latency-rate", env.Float("FAULT_LATENCY_RATE", 1), "The probability, from 0 to 1, a request gets "+
+		"-fault-latency. Defaults to env variable 'FAULT_LATENCY_RATE'.",
+	)
+	faultErrorRate = Flags.Float64("fault-error-rate", env.Float("FAULT_ERROR_RATE", 0), "The probability, from 0 to 1, a request fails with "+
+		"-fault-status. Defaults to env variable 'FAULT_ERROR_RATE'.",
+	)
+	faultStatus = Flags.Int("fault-status", env.Int("FAULT_STATUS", http.StatusInternalServerError), "The status of requests failed by "+
+		"-fault-error-rate. Defaults to env variable 'FAULT_STATUS'.",
+	)
+	faultDribbleRate = Flags.Float64("fault-dribble-rate", env.Float("FAULT_DRIBBLE_RATE", 0), "The probability, from 0 to 1, a response body "+
+		"is written a byte at a time. Defaults to env variable 'FAULT_DRIBBLE_RATE'.",
+	)
+	faultDribbleInterval = Flags.Duration("fault-dribble-interval", env.Duration("FAULT_DRIBBLE_INTERVAL", 100*time.Millisecond), "The wait "+
+		"between the bytes of a dribbled response. Defaults to env variable 'FAULT_DRIBBLE_INTERVAL'.",
+	)
+)
+
+// metricsExporter is the backend the server's metrics are exported to.
+var metricsExporter = Flags.String("metrics-exporter", env.Or("OTEL_METRICS_EXPORTER", "otlp"), "The backend metrics are exported to: "+
+	"'otlp', which pushes them to the OTLP gRPC collector at -otlp-endpoint every -metrics-interval, 'prometheus', which serves them at "+
+	"/metrics on -listen-addr, or 'none'. Defaults to env variable 'OTEL_METRICS_EXPORTER'.",
+)
+
+// baggageSpanAttributes lists the baggage members copied onto the server's spans.
+var baggageSpanAttributes = Flags.String("baggage-span-attributes", env.Or("BAGGAGE_SPAN_ATTRIBUTES", "user.id,session.id,request.origin,correlation.id"),
+	"A comma separated list of the baggage members, propagated with requests, that are copied onto the server's spans as attributes. "+
+		"Defaults to env variable 'BAGGAGE_SPAN_ATTRIBUTES'.",
+)
+
+// Main initializes tracing provider and listens to requests at /hello returning "Hello World!" with
+// randomized latency. Flags and telemetryflags.FlagSet must be parsed first.
+func Main() {
+	if err := Validate(); err != nil {
+		// Every problem is reported at once, before the logger, which may be what is misconfigured.
+		validate.Print(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger, err := newLogger()
+	if err != nil {
+		// There is no logger to report this with yet.
+		fmt.Fprintf(os.Stderr, "failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	// Errors from exporting, like a lost connection to the collector, are reported here.
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error("OpenTelemetry error", zap.Error(err))
+	}))
+
+	// The handlers are served on their own mux rather than http.DefaultServeMux, so no package can add
+	// handlers to the server behind its back.
+	mux := http.NewServeMux()
+	shutdown, err := initTraceAndMetricsProvider(context.Background(), logger, mux)
+	if err != nil {
+		logger.Fatal("failed to initialize telemetry", zap.Error(err))
+	}
 	defer shutdown()
 
-	// create a handler wrapped in OpenTelemetry instrumentation
-	handler := handleRequestWithRandomSleep()
-	wrappedHandler := otelhttp.NewHandler(handler, "/hello")
+	instruments, err := NewServerInstruments(global.Meter("demo-server-meter"))
+	if err != nil {
+		logger.Fatal("failed to create request metrics", zap.Error(err))
+	}
+
+	defaultFaults, err := faultsFromFlags()
+	if err != nil {
+		logger.Fatal("invalid faults", zap.Error(err))
+	}
+
+	downstreamURLs, err := parseDownstreams(*downstreams)
+	if err != nil {
+		logger.Fatal("invalid downstream services", zap.Error(err))
+	}
 
-	// serve up the wrapped handler
-	http.Handle("/hello", wrappedHandler)
-	http.ListenAndServe(":7080", nil)
+	db, err := newFakeDB()
+	if err != nil {
+		logger.Fatal("invalid simulated database", zap.Error(err))
+	}
+
+	// create a handler wrapped in OpenTelemetry instrumentation and request metrics. Faults are injected
+	// inside them, so injected errors and latency show up in both.
+	handler := callDownstreams(handleRequestWithRandomSleep(logger, db), downstreamURLs, *downstreamMaxDepth, logger)
+	handler = injectFaults(handler, defaultFaults, logger)
+	if *rateLimitRPS > 0 {
+		handler = rateLimit(handler, newTokenBucket(*rateLimitRPS, *rateLimitBurst, time.Now()), instruments, logger)
+	}
+	// The deadline the caller propagated applies to everything that follows, even a rejected request.
+	handler = deadline.Handler(handler)
+	handler = instruments.Handler(handler, "/hello")
+	if *accessLogEnabled {
+		handler = accessLog(handler, logger)
+	}
+	// A request with forcesample.Header is sampled whatever -sampler decides, like the client samples it.
+	wrappedHandler := forcesample.Handler(otelhttp.NewHandler(handler, "/hello"))
+
+	// Both servers report failing to serve here, which shuts down the other.
+	serveErr := make(chan error, 2)
+
+	var grpcServer *grpc.Server
+	if *grpcListenAddr != "" {
+		grpcLis, err := net.Listen("tcp", *grpcListenAddr)
+		if err != nil {
+			logger.Fatal("failed to listen for gRPC calls", zap.Error(err))
+		}
+		grpcServer = newGRPCServer(logger, db)
+		go func() {
+			logger.Info("serving gRPC requests", zap.String("addr", *grpcListenAddr))
+			if err := grpcServer.Serve(grpcLis); err != nil {
+				serveErr <- fmt.Errorf("gRPC server failed: %w", err)
+			}
+		}()
+	}
+
+	// The probe handlers aren't instrumented, so probes don't fill the trace backend.
+	ready := &readiness{}
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.Handle("/readyz", ready)
+
+	// serve up the wrapped handler, with HTTP/2 without TLS (h2c) as well as HTTP/1.1
+	mux.Handle("/hello", wrappedHandler)
+	h2s := &http2.Server{}
+	srv := &http.Server{Handler: h2c.NewHandler(mux, h2s)}
+	// Shutting down srv then also tells HTTP/2 clients to stop sending requests.
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		logger.Fatal("failed to configure HTTP/2", zap.Error(err))
+	}
+	lis, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		logger.Fatal("failed to listen", zap.Error(err))
+	}
+	go func() {
+		if err := srv.Serve(lis); err != http.ErrServerClosed {
+			serveErr <- fmt.Errorf("server failed: %w", err)
+		}
+	}()
+	// Telemetry is initialized and the listener is up, so requests can be handled and traced.
+	ready.set(true)
+	logger.Info("serving requests", zap.String("addr", *listenAddr))
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	var reason string
+	select {
+	case sig := <-sigs:
+		reason = sig.String()
+	case err := <-serveErr:
+		logger.Error("stopped serving", zap.Error(err))
+		reason = "server failed"
+	}
+	// The deferred shutdown then flushes the telemetry, including the Shutdown span.
+	drain(logger, ready, srv, grpcServer, reason, *drainTimeout)
 }
 
 // handleRequestWithRandomSleep registers a request handler that will randomly sleep to induce artificial request latency.
-func handleRequestWithRandomSleep() http.HandlerFunc {
+// If db isn't nil, the handler then looks up the greeting and counts the visit in it.
+func handleRequestWithRandomSleep(log *zap.Logger, db *fakeDB) http.HandlerFunc {
 	commonLabels := []attribute.KeyValue{
 		attribute.String("server-attribute", "foo"),
 	}
 
 	return func(w http.ResponseWriter, req *http.Request) {
-		//  random sleep to simulate latency
-		var sleep int64
-		switch modulus := time.Now().Unix() % 5; modulus {
-		case 0:
-			sleep = rng.Int63n(2000)
-		case 1:
-			sleep = rng.Int63n(15)
-		case 2:
-			sleep = rng.Int63n(917)
-		case 3:
-			sleep = rng.Int63n(87)
-		case 4:
-			sleep = rng.Int63n(1173)
-		}
-		time.Sleep(time.Duration(sleep) * time.Millisecond)
 		ctx := req.Context()
+		f := featuresFromContext(ctx)
 		span := trace.SpanFromContext(ctx)
+		span.SetAttributes(propagators.MeshAttributes(ctx, req.Header)...)
+		//  random sleep to simulate latency, unless the caller's deadline passes first
+		sleep := f.latency(randomLatency())
+		select {
+		case <-ctx.Done():
+			span.SetStatus(codes.Error, ctx.Err().Error())
+			http.Error(w, ctx.Err().Error(), http.StatusGatewayTimeout)
+			return
+		case <-time.After(sleep):
+		}
 		span.SetAttributes(commonLabels...)
-		w.Write([]byte("Hello World"))
+		span.SetAttributes(f.attributes()...)
+		span.SetAttributes(samplingTier(span)...)
+		if db != nil {
+			if err := queryGreeting(ctx, db); err != nil {
+				WithCorrelation(span, log).Error("query failed", zap.Error(err))
+				http.Error(w, "query failed", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Write([]byte(f.greeting("World")))
+		WithCorrelation(span, log).Debug("request handled", zap.Duration("sleep", sleep))
 	}
 }
 
-// initTraceProvider initializes an OTLP exporter, and configures the corresponding trace provider.
-func initTraceProvider() func() {
-	ctx := context.Background()
+// randomLatency returns a random latency to simulate, whose range changes every second so the latency
+// over time has peaks. It is called from concurrent handlers, so it uses the package level math/rand
+// functions, which are safe for concurrent use, rather than a shared rand.Rand.
+func randomLatency() time.Duration {
+	var sleep int64
+	switch modulus := time.Now().Unix() % 5; modulus {
+	case 0:
+		sleep = rand.Int63n(2000)
+	case 1:
+		sleep = rand.Int63n(15)
+	case 2:
+		sleep = rand.Int63n(917)
+	case 3:
+		sleep = rand.Int63n(87)
+	case 4:
+		sleep = rand.Int63n(1173)
+	}
+	return time.Duration(sleep) * time.Millisecond
+}
 
-	otelAgentAddr, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if !ok {
-		otelAgentAddr = "0.0.0.0:4317"
+// queryGreeting runs the queries of a request against db, stopping at the first that fails.
+func queryGreeting(ctx context.Context, db *fakeDB) error {
+	if err := db.query(ctx, "SELECT", "greetings", "SELECT text FROM greetings WHERE lang = ?"); err != nil {
+		return err
 	}
+	return db.query(ctx, "UPDATE", "visits", "UPDATE visits SET count = count + 1 WHERE page = ?")
+}
 
-	closeTraces := initTracer(ctx, otelAgentAddr)
+// initTraceAndMetricsProvider initializes an OTLP exporter, and configures the corresponding trace and
+// metric providers. The returned func flushes and shuts them down. With -dry-run, spans are printed to
+// stdout and metrics aren't exported. With -sdk-disabled, no-op providers are installed instead, and with
+// -otel-env-only, ones set up by the standard environment variables. Connecting to the collector is logged to
+// log, and Prometheus metrics are served on mux.
+func initTraceAndMetricsProvider(ctx context.Context, log *zap.Logger, mux *http.ServeMux) (func(), error) {
+	if *sdkDisabled {
+		return initNoopProviders()
+	}
+	if *otelEnvOnly {
+		return initEnvProviders(ctx)
+	}
+	res, err := telemetryflags.Resource(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	closeMetrics := func(context.Context) {}
+	if !*dryRun {
+		if closeMetrics, err = initMetrics(ctx, res, mux); err != nil {
+			return nil, err
+		}
+	}
+	closeTraces, err := initTracer(ctx, log, res, *otlpEndpoint)
+	if err != nil {
+		closeMetrics(ctx)
+		return nil, err
+	}
 
 	return func() {
-		doneCtx, cancel := context.WithTimeout(ctx, time.Second)
+		doneCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
 		defer cancel()
 		// pushes any last exports to the receiver
 		closeTraces(doneCtx)
+		closeMetrics(doneCtx)
+	}, nil
+}
+
+// initNoopProviders installs no-op tracer and meter providers, so requests are served without the
+// overhead of recording telemetry. Trace context and baggage are still propagated in the -propagators
+// formats, so baggage still sets the features of a request.
+func initNoopProviders() (func(), error) {
+	prop, err := propagators.New(*propagatorNames)
+	if err != nil {
+		return nil, fmt.Errorf("-propagators=%s is not a valid value: %w", *propagatorNames, err)
 	}
+	otel.SetTextMapPropagator(prop)
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	global.SetMeterProvider(metric.NewNoopMeterProvider())
+	return func() {}, nil
 }
 
-// initTracer initializes an OTLP trace exporter and registers the trace provider with the global context
-func initTracer(ctx context.Context, otelAgentAddr string) func(context.Context) {
-	traceClient := otlptracegrpc.NewClient(
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(otelAgentAddr),
-		otlptracegrpc.WithDialOption(grpc.WithBlock()))
-	traceExp, err := otlptrace.New(ctx, traceClient)
-	handleErr(err, "Failed to create the collector trace exporter")
-
-	res, err := resource.New(ctx,
-		resource.WithFromEnv(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-		resource.WithHost(),
-		resource.WithAttributes(
-			// the service name used to display traces in backends
-			semconv.ServiceNameKey.String("demo-server"),
-		),
-	)
-	handleErr(err, "failed to create resource")
+// initEnvProviders installs tracer and meter providers set up by the standard OTEL_* environment variables
+// alone, see otelenv.
+func initEnvProviders(ctx context.Context) (func(), error) {
+	c, err := otelenv.Load(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	closeProviders, err := otelenv.Setup(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		doneCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		closeProviders(doneCtx)
+	}, nil
+}
+
+// initTracer initializes an OTLP trace exporter and registers the trace provider, sampling as -sampler says
+// except for requests forced to be sampled, with the global context
+func initTracer(ctx context.Context, log *zap.Logger, res *resource.Resource, otelAgentAddr string) (func(context.Context), error) {
+	prop, err := propagators.New(*propagatorNames)
+	if err != nil {
+		return nil, fmt.Errorf("-propagators=%s is not a valid value: %w", *propagatorNames, err)
+	}
+	sampler, err := telemetryflags.Sampler()
+	if err != nil {
+		return nil, err
+	}
+	traceExp, err := newTraceExporter(ctx, log, otelAgentAddr)
+	if err != nil {
+		return nil, err
+	}
 
 	bsp := sdktrace.NewBatchSpanProcessor(traceExp)
 	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(forcesample.New(sampler)),
 		sdktrace.WithResource(res),
+		// Before the batch span processor, so the attributes are set before spans are exported.
+		sdktrace.WithSpanProcessor(newBaggageSpanProcessor(*baggageSpanAttributes)),
 		sdktrace.WithSpanProcessor(bsp),
 	)
 
-	// set global propagator to tracecontext (the default is no-op).
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// set the global propagator to the -propagators formats (the default is no-op).
+	otel.SetTextMapPropagator(prop)
 	otel.SetTracerProvider(tracerProvider)
 
 	return func(doneCtx context.Context) {
-		if err := traceExp.Shutdown(doneCtx); err != nil {
+		// Shutting down the provider flushes the batch span processor and shuts down the exporter.
+		if err := tracerProvider.Shutdown(doneCtx); err != nil {
 			otel.Handle(err)
 		}
+	}, nil
+}
+
+// newTraceExporter returns an OTLP exporter for the collector at otelAgentAddr, connecting with the TLS,
+// headers and backoff of telemetryflags.TraceExporter, or, with -dry-run, one that prints spans to stdout.
+func newTraceExporter(ctx context.Context, log *zap.Logger, otelAgentAddr string) (sdktrace.SpanExporter, error) {
+	if *dryRun {
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+	tlsConf, err := telemetryflags.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	headers, err := telemetryflags.Headers()
+	if err != nil {
+		return nil, err
+	}
+	traceExp, err := telemetryflags.TraceExporter(ctx, log, otelAgentAddr, tlsConf, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the collector trace exporter: %w", err)
+	}
+	return traceExp, nil
+}
+
+// initMetrics sets up the metrics exporter selected by -metrics-exporter and registers the meter provider
+// with the global context.
+func initMetrics(ctx context.Context, res *resource.Resource, mux *http.ServeMux) (func(context.Context), error) {
+	switch strings.ToLower(*metricsExporter) {
+	case "none":
+		return func(context.Context) {}, nil
+	case "otlp":
+		return initOTLPMetrics(ctx, res)
+	case "prometheus":
+		return initPrometheusMetrics(res, mux)
+	}
+	return nil, fmt.Errorf("-metrics-exporter=%s is not a valid value", *metricsExporter)
+}
+
+// initOTLPMetrics initializes a metrics pusher, pushing every -metrics-interval, and registers the metrics
+// provider with the global context
+func initOTLPMetrics(ctx context.Context, res *resource.Resource) (func(context.Context), error) {
+	metricExp, err := telemetryflags.MetricExporter(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	pusher := controller.New(
+		processor.NewFactory(
+			simple.NewWithHistogramDistribution(),
+			metricExp,
+		),
+		controller.WithExporter(metricExp),
+		controller.WithCollectPeriod(*telemetryflags.MetricsInterval),
+		controller.WithResource(res),
+	)
+	global.SetMeterProvider(pusher)
+
+	if err := pusher.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start metric pusher: %w", err)
+	}
+
+	return func(doneCtx context.Context) {
+		// pushes any last exports to the receiver
+		if err := pusher.Stop(doneCtx); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
 }
 
-func handleErr(err error, message string) {
+// initPrometheusMetrics registers the metrics provider with a Prometheus registry set up like the client's, and
+// serves it at /metrics on mux, next to the probes, so it isn't traced either.
+func initPrometheusMetrics(res *resource.Resource, mux *http.ServeMux) (func(context.Context), error) {
+	// The exporter's sums are cumulative from when it starts, which is the time of their _created samples.
+	created := time.Now()
+	registry, err := promexport.NewRegistry(res, promexport.LatencyBuckets)
 	if err != nil {
-		log.Fatalf("%s: %v", message, err)
+		return nil, err
 	}
+	mux.Handle("/metrics", promexport.Handler(registry, created))
+	return func(context.Context) {}, nil
 }
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/redmetrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+// ServerInstruments are the metrics of the requests the server handles. The RED metrics are the same
+// redmetrics the client records, so the two sides of each request can be compared.
+type ServerInstruments struct {
+	// RED records the rate, errors and duration of requests.
+	RED *redmetrics.Metrics
+	// InFlight is the number of requests being handled.
+	InFlight metric.Int64UpDownCounter
+	// ResponseSize records the size of response bodies in bytes.
+	ResponseSize metric.Int64Histogram
+	// RateLimited counts the requests rejected by the rate limit.
+	RateLimited metric.Int64Counter
+}
+
+// NewServerInstruments takes a meter and builds the instruments that measure the requests the server handles.
+func NewServerInstruments(meter metric.Meter) (ServerInstruments, error) {
+	red, err := redmetrics.New(meter, "demo_server/request")
+	if err != nil {
+		return ServerInstruments{}, err
+	}
+	inFlight, err := meter.NewInt64UpDownCounter(
+		"demo_server/requests_in_flight",
+		metric.WithDescription("The number of requests being handled"),
+	)
+	if err != nil {
+		return ServerInstruments{}, err
+	}
+	responseSize, err := meter.NewInt64Histogram(
+		"demo_server/response_size",
+		metric.WithDescription("The size of response bodies in bytes"),
+		metric.WithUnit(unit.Bytes),
+	)
+	if err != nil {
+		return ServerInstruments{}, err
+	}
+	rateLimited, err := meter.NewInt64Counter(
+		"demo_server/rate_limited",
+		metric.WithDescription("The number of requests rejected by the rate limit"),
+	)
+	if err != nil {
+		return ServerInstruments{}, err
+	}
+	return ServerInstruments{RED: red, InFlight: inFlight, ResponseSize: responseSize, RateLimited: rateLimited}, nil
+}
+
+// Handler wraps h so that every request it serves for route is recorded in the instruments.
+func (i ServerInstruments) Handler(h http.Handler, route string) http.Handler {
+	attrs := []attribute.KeyValue{attribute.String("http.route", route)}
+	measured := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		i.InFlight.Add(ctx, 1, attrs...)
+		defer i.InFlight.Add(ctx, -1, attrs...)
+
+		cw := &countingWriter{ResponseWriter: w}
+		h.ServeHTTP(cw, r)
+		i.ResponseSize.Record(ctx, cw.written, attrs...)
+	})
+	return i.RED.Handler(measured, attrs...)
+}
+
+// countingWriter counts the bytes of the body written to an http.ResponseWriter.
+type countingWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+// Write implements http.ResponseWriter.Write.
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, so handlers can stream responses through a countingWriter.
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// tokenBucket is a token bucket rate limiter. It holds up to burst tokens and is refilled with rate
+// tokens a second, and each request takes a token.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full tokenBucket that allows rate requests a second, with bursts of up to burst.
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// take takes a token at now, if there is one. If there isn't, it returns how long until there is.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// rateLimit wraps next so requests that find bucket empty are rejected with a 429 status and a
+// Retry-After header. Each rejection is a "rate limited" event on the request's span and is counted
+// in the demo_server/rate_limited metric.
+func rateLimit(next http.Handler, bucket *tokenBucket, instruments ServerInstruments, log *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := bucket.take(time.Now())
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+		span.AddEvent("rate limited", trace.WithAttributes(
+			attribute.Float64("demo.rate_limit", bucket.rate),
+			attribute.Int64("demo.rate_limit.retry_after_ms", wait.Milliseconds()),
+		))
+		instruments.RateLimited.Add(ctx, 1, attribute.String("http.route", r.URL.Path))
+		WithCorrelation(span, log).Debug("request rate limited", zap.Duration("retry_after", wait))
+
+		// Retry-After is in whole seconds, so round up to not invite retries that are rejected again.
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc     string
+		rate     float64
+		burst    int
+		takes    []time.Duration
+		want     []bool
+		wantWait time.Duration
+	}{
+		{
+			desc:  "Burst",
+			rate:  1,
+			burst: 3,
+			takes: []time.Duration{0, 0, 0, 0},
+			want:  []bool{true, true, true, false},
+			// The bucket is empty, so the next token is a second away.
+			wantWait: time.Second,
+		},
+		{
+			desc:     "Refilled",
+			rate:     10,
+			burst:    1,
+			takes:    []time.Duration{0, 50 * time.Millisecond, 100 * time.Millisecond},
+			want:     []bool{true, false, true},
+			wantWait: 0,
+		},
+		{
+			desc:     "Not refilled past burst",
+			rate:     10,
+			burst:    2,
+			takes:    []time.Duration{time.Hour, time.Hour, time.Hour},
+			want:     []bool{true, true, false},
+			wantWait: 100 * time.Millisecond,
+		},
+	}
+
+	for _, test := range tests {
+		b := newTokenBucket(test.rate, test.burst, start)
+		var wait time.Duration
+		for i, at := range test.takes {
+			var got bool
+			got, wait = b.take(start.Add(at))
+			if got != test.want[i] {
+				t.Errorf("TestTokenBucket(%s): take %d: got %v, want %v", test.desc, i, got, test.want[i])
+			}
+		}
+		if wait != test.wantWait {
+			t.Errorf("TestTokenBucket(%s): got wait %v, want %v", test.desc, wait, test.wantWait)
+		}
+	}
+}
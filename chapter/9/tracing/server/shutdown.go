@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// drain gracefully shuts down srv and grpcServer, which may be nil, recording it in a "Shutdown" span.
+// ready is cleared first so readiness probes fail, then the servers stop accepting connections and
+// in-flight requests have until timeout to finish, after which their connections are closed.
+func drain(log *zap.Logger, ready *readiness, srv *http.Server, grpcServer *grpc.Server, reason string, timeout time.Duration) {
+	// The context isn't derived from one that may already be done, like the signal's.
+	ctx, span := otel.Tracer("demo-server-tracer").Start(
+		context.Background(),
+		"Shutdown",
+		trace.WithAttributes(
+			attribute.String("demo.shutdown.reason", reason),
+			attribute.Int64("demo.shutdown.drain_timeout_ms", timeout.Milliseconds()),
+		),
+	)
+	defer span.End()
+	log.Info("shutting down", zap.String("reason", reason), zap.Duration("drain_timeout", timeout))
+
+	ready.set(false)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				span.AddEvent("grpc server drained")
+			case <-ctx.Done():
+				grpcServer.Stop()
+				span.AddEvent("grpc server stopped", trace.WithAttributes(attribute.Bool("demo.shutdown.drained", false)))
+				log.Warn("gRPC calls were still in flight after the drain timeout")
+			}
+		}()
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "in-flight requests were not drained")
+		log.Warn("requests were still in flight after the drain timeout", zap.Error(err))
+	} else {
+		span.AddEvent("http server drained")
+	}
+	wg.Wait()
+
+	span.SetAttributes(attribute.Int64("demo.shutdown.drain_ms", time.Since(start).Milliseconds()))
+	log.Info("servers stopped", zap.Duration("drain", time.Since(start)))
+}
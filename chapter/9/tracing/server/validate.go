@@ -0,0 +1,51 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
+)
+
+// Validate checks the addresses, durations and ratios set by Flags and telemetryflags.FlagSet, and the
+// environment variables they default to, and returns a *validate.Error with every problem found, or nil.
+// Main calls it first, so a misconfigured server exits before it is ready rather than serving with a default
+// in place of a value it couldn't parse.
+func Validate() error {
+	var p validate.Problems
+	telemetryflags.Check(&p)
+	p.Env(Flags)
+
+	if !*otelEnvOnly {
+		_, err := telemetryflags.Sampler()
+		p.Add(err)
+		switch strings.ToLower(*metricsExporter) {
+		case "otlp", "prometheus", "none":
+		default:
+			p.Addf("-metrics-exporter=%s is not a valid value, use 'otlp', 'prometheus' or 'none'", *metricsExporter)
+		}
+	}
+
+	p.HostPort("listen-addr", *listenAddr)
+	if *grpcListenAddr != "" {
+		p.HostPort("grpc-listen-addr", *grpcListenAddr)
+	}
+	p.NonNegative("drain-timeout", *drainTimeout)
+	_, err := parseDownstreams(*downstreams)
+	p.Add(err)
+	if *downstreamMaxDepth < 0 {
+		p.Addf("-downstream-max-depth cannot be negative, was %d", *downstreamMaxDepth)
+	}
+	if *rateLimitRPS < 0 || (*rateLimitRPS > 0 && *rateLimitBurst < 1) {
+		p.Addf("-rate-limit cannot be negative and -rate-limit-burst must be at least 1")
+	}
+
+	// The database and fault flags are checked even when they're off, as requests can turn faults on.
+	p.NonNegative("db-latency", *dbLatency)
+	p.NonNegative("db-latency-jitter", *dbLatencyJitter)
+	p.Ratio("db-error-rate", *dbErrorRate)
+	_, err = faultsFromFlags()
+	p.Add(err)
+
+	return p.Err()
+}
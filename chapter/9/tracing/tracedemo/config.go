@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// Flags related to the config file.
+var (
+	configFile  string
+	printConfig bool
+)
+
+// notInConfig are the flags that aren't settings, so they are neither read from nor printed as config.
+var notInConfig = map[string]bool{"config": true, "print-config": true, "profile": true, "validate-only": true, "help": true}
+
+// envVarRE finds the environment variable a flag defaults to in its usage, which by convention ends
+// with "Defaults to env variable 'NAME'".
+var envVarRE = regexp.MustCompile(`Defaults to env variable '([^']+)'`)
+
+// loadConfig sets the flags of cmd from the config file at path, a YAML, JSON or TOML file read by viper.
+// See applyConfig for how it is laid out.
+func loadConfig(cmd *cobra.Command, path string) error {
+	if path == "" {
+		return nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	if err := applyConfig(cmd.Flags(), v.AllSettings(), cmd.Name()); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyConfig sets flags from settings, keyed by flag name. Settings at the top level apply to every
+// command, and those in the section named after the command only to it, taking precedence:
+//
+//	otlp-endpoint: otel-collector:4317
+//	client:
+//	  rate: 20
+//	  server-endpoint: http://demo-server:7080/hello
+//
+// Flags set on the command line, or by the environment variable they default to, keep their value.
+// Lists can be YAML lists, which are joined with commas. Sections of other commands are ignored.
+func applyConfig(flags *pflag.FlagSet, settings map[string]interface{}, section string) error {
+	for name, value := range sectionValues(settings, section) {
+		f := flags.Lookup(name)
+		if f == nil || notInConfig[name] {
+			return fmt.Errorf("%s is not a setting of %s", name, section)
+		}
+		if f.Changed || envSet(f) {
+			continue
+		}
+		if err := flags.Set(name, configValue(value)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// sectionValues returns the top level settings merged with those in section.
+func sectionValues(settings map[string]interface{}, section string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for name, value := range settings {
+		if _, ok := value.(map[string]interface{}); !ok {
+			values[name] = value
+		}
+	}
+	if s, ok := settings[section].(map[string]interface{}); ok {
+		for name, value := range s {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// watchConfig watches the config file at path and passes changes to the settings of cmd that reloadable
+// lists to reload, while the command runs. Like at startup, settings set on the command line, which
+// fromCommandLine lists, or by environment variables are left alone.
+func watchConfig(cmd *cobra.Command, path string, fromCommandLine, reloadable map[string]bool, reload func(map[string]string) error) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+	v.OnConfigChange(func(fsnotify.Event) {
+		changes := map[string]string{}
+		for name, value := range sectionValues(v.AllSettings(), cmd.Name()) {
+			f := cmd.Flags().Lookup(name)
+			if f == nil || !reloadable[name] || fromCommandLine[name] || envSet(f) {
+				continue
+			}
+			if s := configValue(value); s != f.Value.String() {
+				changes[name] = s
+			}
+		}
+		if len(changes) > 0 {
+			// reload reports its own errors, and the command keeps running with the settings it has.
+			reload(changes)
+		}
+	})
+	v.WatchConfig()
+	return nil
+}
+
+// changedFlags returns the names of the flags that are set.
+func changedFlags(flags *pflag.FlagSet) map[string]bool {
+	changed := map[string]bool{}
+	flags.Visit(func(f *pflag.Flag) {
+		changed[f.Name] = true
+	})
+	return changed
+}
+
+// envSet reports if the environment variable f defaults to is set.
+func envSet(f *pflag.Flag) bool {
+	m := envVarRE.FindStringSubmatch(f.Usage)
+	if m == nil {
+		return false
+	}
+	_, ok := os.LookupEnv(m[1])
+	return ok
+}
+
+// configValue returns a setting's value as a flag value.
+func configValue(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	items := make([]string, len(list))
+	for i, item := range list {
+		items[i] = fmt.Sprint(item)
+	}
+	return strings.Join(items, ",")
+}
+
+// writeConfig writes the settings cmd runs with, from flags, environment variables and the config file,
+// as a config file that applyConfig reads back.
+func writeConfig(w io.Writer, cmd *cobra.Command) error {
+	config := map[string]interface{}{}
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		if !notInConfig[f.Name] {
+			config[f.Name] = f.Value.String()
+		}
+	})
+	section := map[string]interface{}{}
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if !notInConfig[f.Name] {
+			section[f.Name] = f.Value.String()
+		}
+	})
+	config[cmd.Name()] = section
+
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
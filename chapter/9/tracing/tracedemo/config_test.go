@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyConfig(t *testing.T) {
+	tests := []struct {
+		desc     string
+		args     []string
+		env      map[string]string
+		settings map[string]interface{}
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			desc:     "Top level",
+			settings: map[string]interface{}{"rate": 20, "log-level": "debug"},
+			want:     map[string]string{"rate": "20", "log-level": "debug"},
+		},
+		{
+			desc: "Section takes precedence",
+			settings: map[string]interface{}{
+				"log-level": "debug",
+				"client":    map[string]interface{}{"log-level": "warn"},
+				"server":    map[string]interface{}{"log-level": "error", "listen-addr": ":7080"},
+			},
+			want: map[string]string{"log-level": "warn"},
+		},
+		{
+			desc:     "Flag takes precedence",
+			args:     []string{"--rate=5"},
+			settings: map[string]interface{}{"rate": 20},
+			want:     map[string]string{"rate": "5"},
+		},
+		{
+			desc:     "Environment variable takes precedence",
+			env:      map[string]string{"TEST_LOG_LEVEL": "warn"},
+			settings: map[string]interface{}{"log-level": "debug", "rate": 20},
+			want:     map[string]string{"log-level": "info", "rate": "20"},
+		},
+		{
+			desc:     "List",
+			settings: map[string]interface{}{"propagators": []interface{}{"tracecontext", "baggage", "b3"}},
+			want:     map[string]string{"propagators": "tracecontext,baggage,b3"},
+		},
+		{
+			desc:     "Unknown setting",
+			settings: map[string]interface{}{"rat": 20},
+			wantErr:  true,
+		},
+		{
+			desc:     "Invalid value",
+			settings: map[string]interface{}{"rate": "fast"},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		for k, v := range test.env {
+			t.Setenv(k, v)
+		}
+		flags := pflag.NewFlagSet("client", pflag.ContinueOnError)
+		flags.Float64("rate", 0, "The requests sent per second.")
+		flags.String("log-level", "info", "The lowest level logged. Defaults to env variable 'TEST_LOG_LEVEL'.")
+		flags.String("propagators", "tracecontext", "The propagators.")
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("TestApplyConfig(%s): could not parse flags: %s", test.desc, err)
+		}
+
+		err := applyConfig(flags, test.settings, "client")
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestApplyConfig(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestApplyConfig(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		for name, want := range test.want {
+			if got := flags.Lookup(name).Value.String(); got != want {
+				t.Errorf("TestApplyConfig(%s): got %s=%s, want %s", test.desc, name, got, want)
+			}
+		}
+	}
+}
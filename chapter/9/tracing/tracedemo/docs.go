@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/buildinfo"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+	"github.com/spf13/pflag"
+)
+
+// newDocsCmd returns the docs command, which writes a man page or Markdown file for each command of root.
+// Shell completions come from cobra's own completion command.
+func newDocsCmd(root *cobra.Command) *cobra.Command {
+	var dir, format string
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Write man pages or Markdown for every command",
+		Example: "  tracedemo docs --dir=/usr/local/share/man/man1\n" +
+			"  tracedemo docs --format=markdown --dir=docs",
+		Args: cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			switch format {
+			case "man":
+				header := &doc.GenManHeader{Title: "TRACEDEMO", Section: "1", Source: "tracedemo " + buildinfo.Get().Version}
+				return doc.GenManTree(root, header, dir)
+			case "markdown":
+				return doc.GenMarkdownTree(root, dir)
+			}
+			return fmt.Errorf("--format=%s is not a valid value, use 'man' or 'markdown'", format)
+		},
+	}
+	cmd.Flags().StringVar(&dir, "dir", ".", "The directory the files are written to, which is created if needed.")
+	cmd.Flags().StringVar(&format, "format", "man", "What to write: 'man' for man pages or 'markdown'.")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"man", "markdown"}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.MarkFlagDirname("dir")
+	return cmd
+}
+
+// flagValues are the values shells complete for flags that take one of a few values.
+var flagValues = map[string][]string{
+	"profile":              {"dev", "staging", "prod"},
+	"log-level":            {"debug", "info", "warn", "error"},
+	"log-format":           {"json", "console"},
+	"propagators":          {"tracecontext", "baggage", "b3", "b3multi", "jaeger", "xray", "correlationid", "mesh", "none"},
+	"resource-detectors":   {"ec2", "ecs", "eks", "gcp", "azure", "none"},
+	"otlp-discovery":       {"static", "dns", "srv"},
+	"exporter":             {"otlp", "otlpgrpc", "otlphttp", "stdout", "zipkin", "file"},
+	"metrics-exporter":     {"otlp", "prometheus", "pushgateway", "statsd", "dogstatsd", "none"},
+	"logs-exporter":        {"otlp", "none"},
+	"traffic-model":        {"closed", "poisson"},
+	"request-distribution": {"constant", "uniform", "exponential"},
+	"http-version":         {"auto", "1.1", "2", "h2c"},
+	"id-generator":         {"random", "xray"},
+	"retry-on":             {"5xx", "connection"},
+	"protocol":             {"http", "grpc"},
+	"sampler": {
+		"always_on", "always_off", "traceidratio", "parentbased_always_on", "parentbased_always_off",
+		"parentbased_traceidratio", "adaptive", "jaeger_remote", "parentbased_jaeger_remote", "ratelimiting",
+		"parentbased_ratelimiting",
+	},
+}
+
+// fileExtensions are the extensions shells complete for flags that take a file of a certain kind. Other
+// flags complete any file.
+var fileExtensions = map[string][]string{
+	"config":                {"yaml", "yml", "json", "toml"},
+	"scenario":              {"yaml", "yml", "json"},
+	"server-endpoints-file": {"yaml", "yml"},
+	"sampling-rules":        {"yaml", "yml"},
+}
+
+// registerCompletions registers the completions of the flags cmd defines, for itself or its subcommands,
+// that are in flagValues or fileExtensions.
+func registerCompletions(cmd *cobra.Command) {
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if values, ok := flagValues[f.Name]; ok {
+			cmd.RegisterFlagCompletionFunc(f.Name, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+		}
+		if exts, ok := fileExtensions[f.Name]; ok {
+			if f.Annotations == nil {
+				f.Annotations = map[string][]string{}
+			}
+			f.Annotations[cobra.BashCompFilenameExt] = exts
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDocs(t *testing.T) {
+	tests := []struct {
+		desc   string
+		format string
+		want   []string
+	}{
+		{
+			desc:   "Man pages",
+			format: "man",
+			want:   []string{"tracedemo.1", "tracedemo-server.1", "tracedemo-loadgen.1"},
+		},
+		{
+			desc:   "Markdown",
+			format: "markdown",
+			want:   []string{"tracedemo.md", "tracedemo_client.md", "tracedemo_verify.md"},
+		},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		root := newRootCmd()
+		root.SetArgs([]string{"docs", "--format=" + test.format, "--dir=" + dir})
+		if err := root.Execute(); err != nil {
+			t.Errorf("TestDocs(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		}
+		for _, name := range test.want {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("TestDocs(%s): %s wasn't written: %s", test.desc, name, err)
+			}
+		}
+	}
+}
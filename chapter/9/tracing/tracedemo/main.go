@@ -0,0 +1,198 @@
+/*
+Command tracedemo runs the parts of the tracing demo: the client that sends traced requests, the server
+that answers them, and load generation and verification built on the client.
+
+	tracedemo server
+	tracedemo client --server-endpoint=http://localhost:7080/hello
+	tracedemo loadgen --rate=200 --duration=5m
+	tracedemo verify
+
+The telemetry flags, like --otlp-endpoint and --log-level, are shared by every command. Flags are also
+accepted with one dash, like -rate=200, as the client and server took them before they were commands.
+
+Settings can also be kept in a config file given with --config, see applyConfig. Flags take precedence
+over the environment variables they default to, which take precedence over the config file, and that
+over the profile given with --profile, a bundle of settings for an environment like dev or prod.
+--print-config prints the settings a command would run with, as a config file, without running it.
+While the client runs, changes to the config file's settings in client.Reloadable, like the rate, are
+applied without restarting it.
+
+Each command checks its settings before it starts, and exits listing every problem found if there are any.
+--validate-only does only that, so a config file or deployment can be checked without running anything.
+
+	tracedemo completion bash > /etc/bash_completion.d/tracedemo
+	tracedemo docs --dir=/usr/local/share/man/man1
+
+completion writes the shell completions of the commands and flags, and docs their man pages.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/client"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/buildinfo"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/telemetryflags"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/pkg/validate"
+	"github.com/PacktPublishing/Go-for-DevOps/chapter/9/tracing/demo/server"
+	"github.com/spf13/cobra"
+)
+
+// validateOnly makes commands check their settings and exit, see run.
+var validateOnly bool
+
+// errInvalid is returned by commands run with --validate-only whose settings have problems, which are
+// printed before it.
+var errInvalid = errors.New("the configuration is not valid")
+
+func main() {
+	root := newRootCmd()
+	root.SetArgs(oneDashFlags(os.Args[1:]))
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCmd returns the tracedemo command and its subcommands.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:   "tracedemo",
+		Short: "Distributed tracing demo with OpenTelemetry",
+		Long: "tracedemo runs a client and server instrumented with OpenTelemetry, whose traces, metrics and logs " +
+			"are exported to an OTLP collector.",
+		// Setting Version adds the --version flag.
+		Version:      buildinfo.Get().String(),
+		SilenceUsage: true,
+		// The docs only change with the commands, not the date they are generated.
+		DisableAutoGenTag: true,
+	}
+	root.PersistentFlags().AddGoFlagSet(telemetryflags.FlagSet)
+	root.PersistentFlags().StringVar(&configFile, "config", os.Getenv("TRACEDEMO_CONFIG"), "A YAML, JSON or TOML file of settings, keyed by flag name, "+
+		"that flags and environment variables take precedence over. Defaults to env variable 'TRACEDEMO_CONFIG'.",
+	)
+	root.PersistentFlags().BoolVar(&printConfig, "print-config", false, "If true, print the settings the command would run with as a config file, and exit.")
+	root.PersistentFlags().StringVar(&profile, "profile", os.Getenv("TRACEDEMO_PROFILE"), "Settings for an environment, that flags, environment "+
+		"variables and the config file take precedence over: 'dev' prints spans with every trace sampled and logs for a terminal, 'staging' "+
+		"and 'prod' export to the collector sampling half and a tenth of traces. Defaults to env variable 'TRACEDEMO_PROFILE'.",
+	)
+	root.PersistentFlags().BoolVar(&validateOnly, "validate-only", false, "If true, check the settings the command would run with, print any "+
+		"problems, and exit with code 1 if there are any.",
+	)
+
+	serverCmd := &cobra.Command{
+		Use:   "server",
+		Short: "Serve /hello over HTTP and the Greeter service over gRPC",
+		Args:  cobra.NoArgs,
+		RunE:  run(nil, server.Validate, server.Main, nil),
+	}
+	serverCmd.Flags().AddGoFlagSet(server.Flags)
+	registerCompletions(serverCmd)
+
+	root.AddCommand(
+		serverCmd,
+		newClientCmd("client", "Send traced requests to the server until interrupted", nil),
+		newClientCmd(
+			"loadgen",
+			"Load test the server, with requests started at random times at --rate for --duration",
+			// Open loop traffic, so a slow server shows up in the latencies instead of slowing the load down.
+			map[string]string{
+				"traffic-model": "poisson",
+				"rate":          "50",
+				"duration":      "1m",
+				"log-sampling":  "true",
+			},
+		),
+		newClientCmd(
+			"verify",
+			"Send one request, traced regardless of sampling, and exit with code 1 if it fails",
+			map[string]string{
+				"requests":       "1",
+				"debug-trace":    "true",
+				"max-error-rate": "0",
+			},
+		),
+	)
+	root.AddCommand(newDocsCmd(root))
+	registerCompletions(root)
+	return root
+}
+
+// newClientCmd returns a command that runs the client with presets, flag values used unless the flag is
+// set on the command line or in the config file. Presets take precedence over environment variables.
+func newClientCmd(use, short string, presets map[string]string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE:  run(presets, client.Validate, client.Main, client.Reload),
+	}
+	cmd.Flags().AddGoFlagSet(client.Flags)
+	registerCompletions(cmd)
+	return cmd
+}
+
+// run returns the RunE of a command that calls start once its flags are set from the command line, the
+// config file, presets and the --profile, or prints them with --print-config, or checks them with check for
+// --validate-only. If reload is set, changes to the config file are passed to it while the command runs,
+// see watchConfig.
+func run(presets map[string]string, check func() error, start func(), reload func(map[string]string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, _ []string) error {
+		fromCommandLine := changedFlags(cmd.Flags())
+		if err := loadConfig(cmd, configFile); err != nil {
+			return err
+		}
+		for name, value := range presets {
+			if cmd.Flags().Changed(name) {
+				continue
+			}
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return err
+			}
+		}
+		// The command's presets, like verify's, apply in any environment.
+		if err := applyProfile(cmd.Flags(), profile); err != nil {
+			return err
+		}
+		if printConfig {
+			return writeConfig(cmd.OutOrStdout(), cmd)
+		}
+		if validateOnly {
+			if err := check(); err != nil {
+				validate.Print(cmd.ErrOrStderr(), err)
+				return errInvalid
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "the configuration is valid")
+			return nil
+		}
+		if reload != nil && configFile != "" {
+			if err := watchConfig(cmd, configFile, fromCommandLine, client.Reloadable, reload); err != nil {
+				return err
+			}
+		}
+		start()
+		return nil
+	}
+}
+
+// oneDashFlags returns args with flags given with one dash, like -rate=5, given with two. Single letters,
+// like -h, and negative numbers are left alone. Nothing after a "--" is changed.
+func oneDashFlags(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(out, args[i:]...)
+		}
+		if len(arg) > 2 && arg[0] == '-' && isLetter(arg[1]) && !strings.HasPrefix(arg, "--") {
+			arg = "-" + arg
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+func isLetter(b byte) bool {
+	return ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
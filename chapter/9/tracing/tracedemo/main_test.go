@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestOneDashFlags(t *testing.T) {
+	tests := []struct {
+		desc string
+		args []string
+		want []string
+	}{
+		{
+			desc: "One dash",
+			args: []string{"client", "-rate=5", "-server-endpoint", "http://server:7080/hello"},
+			want: []string{"client", "--rate=5", "--server-endpoint", "http://server:7080/hello"},
+		},
+		{
+			desc: "Two dashes",
+			args: []string{"client", "--rate=5"},
+			want: []string{"client", "--rate=5"},
+		},
+		{
+			desc: "Shorthand and negative numbers",
+			args: []string{"client", "-h", "--span-link-count-limit", "-10"},
+			want: []string{"client", "-h", "--span-link-count-limit", "-10"},
+		},
+		{
+			desc: "After --",
+			args: []string{"client", "--", "-rate=5"},
+			want: []string{"client", "--", "-rate=5"},
+		},
+	}
+
+	for _, test := range tests {
+		got := oneDashFlags(test.args)
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestOneDashFlags(%s): -want/+got:\n%s", test.desc, diff)
+		}
+	}
+}
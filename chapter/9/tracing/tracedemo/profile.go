@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// profile is the name of the --profile settings a command runs with.
+var profile string
+
+// profiles are the settings --profile selects, keyed by flag name, for the environments the demo runs in.
+// Settings for flags a command doesn't have, like the client's exporter for the server, are skipped.
+var profiles = map[string]map[string]string{
+	// Everything is traced and printed, with no collector needed.
+	"dev": {
+		"dry-run":    "true",
+		"sampler":    "always_on",
+		"log-level":  "debug",
+		"log-format": "console",
+	},
+	"staging": {
+		"exporter":    "otlp",
+		"sampler":     "parentbased_traceidratio",
+		"sampler-arg": "0.5",
+		"log-level":   "info",
+		"log-format":  "json",
+	},
+	// A fraction of traces, and of repeated log messages, so telemetry costs don't grow with traffic.
+	"prod": {
+		"exporter":     "otlp",
+		"sampler":      "parentbased_traceidratio",
+		"sampler-arg":  "0.1",
+		"log-level":    "info",
+		"log-format":   "json",
+		"log-sampling": "true",
+	},
+}
+
+// applyProfile sets flags from the settings of the profile name, if it is set. Like the config file,
+// a profile doesn't change flags that are set, or whose environment variable is set.
+func applyProfile(flags *pflag.FlagSet, name string) error {
+	if name == "" {
+		return nil
+	}
+	settings, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("--profile=%s is not a valid value, use %s", name, profileNames())
+	}
+	for setting, value := range settings {
+		f := flags.Lookup(setting)
+		if f == nil || f.Changed || envSet(f) {
+			continue
+		}
+		if err := flags.Set(setting, value); err != nil {
+			return fmt.Errorf("--profile=%s: %s: %w", name, setting, err)
+		}
+	}
+	return nil
+}
+
+// profileNames returns the names of the profiles, quoted and in order.
+func profileNames() string {
+	var names []string
+	for name := range profiles {
+		names = append(names, "'"+name+"'")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyProfile(t *testing.T) {
+	tests := []struct {
+		desc    string
+		args    []string
+		env     map[string]string
+		profile string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			desc: "No profile",
+			want: map[string]string{"sampler": "parentbased_always_on", "log-format": "json"},
+		},
+		{
+			desc:    "Dev",
+			profile: "dev",
+			want:    map[string]string{"sampler": "always_on", "log-format": "console", "dry-run": "true"},
+		},
+		{
+			desc:    "Flag takes precedence",
+			args:    []string{"--sampler-arg=0.2"},
+			profile: "prod",
+			want:    map[string]string{"sampler": "parentbased_traceidratio", "sampler-arg": "0.2"},
+		},
+		{
+			desc:    "Environment variable takes precedence",
+			env:     map[string]string{"TEST_LOG_FORMAT": "console"},
+			profile: "prod",
+			want:    map[string]string{"log-format": "json", "sampler-arg": "0.1"},
+		},
+		{
+			desc:    "Unknown profile",
+			profile: "qa",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		for k, v := range test.env {
+			t.Setenv(k, v)
+		}
+		flags := pflag.NewFlagSet("client", pflag.ContinueOnError)
+		flags.String("sampler", "parentbased_always_on", "The sampler.")
+		flags.String("sampler-arg", "1.0", "The sampler's argument.")
+		flags.Bool("dry-run", false, "If true, spans are printed.")
+		flags.String("log-format", "json", "How log entries are encoded. Defaults to env variable 'TEST_LOG_FORMAT'.")
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("TestApplyProfile(%s): could not parse flags: %s", test.desc, err)
+		}
+
+		err := applyProfile(flags, test.profile)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestApplyProfile(%s): got err == nil, want err != nil", test.desc)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestApplyProfile(%s): got err == %s, want err == nil", test.desc, err)
+			continue
+		case err != nil:
+			continue
+		}
+		for name, want := range test.want {
+			if got := flags.Lookup(name).Value.String(); got != want {
+				t.Errorf("TestApplyProfile(%s): got %s=%s, want %s", test.desc, name, got, want)
+			}
+		}
+	}
+}